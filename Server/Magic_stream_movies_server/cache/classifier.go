@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/classifier"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+)
+
+// Classifier wraps a SentimentClassifier and reuses the rankings the LLM already gave.
+// The key of a classification is a hash of everything the answer depends on: the provider and model,
+// the text of the active prompt template, the rankings offered and the review, so changing any of them
+// asks the LLM again. Only the answers of the LLM are stored, the rankings of the local fallback are not.
+type Classifier struct {
+	inner     classifier.SentimentClassifier
+	store     repository.ClassificationRepository
+	templates classifier.TemplateSource
+	// Provider and model of the LLM, part of the key
+	model   string
+	ttl     time.Duration
+	counter *Counter
+}
+
+func NewClassifier(inner classifier.SentimentClassifier, store repository.ClassificationRepository, templates classifier.TemplateSource, model string, ttl time.Duration, counter *Counter) *Classifier {
+	counter.enabled.Store(true)
+
+	return &Classifier{inner: inner, store: store, templates: templates, model: model, ttl: ttl, counter: counter}
+}
+
+func (c *Classifier) Classify(ctx context.Context, review string, rankings []models.Ranking) (classifier.Result, error) {
+	template, err := c.templates.FindActive(ctx)
+
+	if err != nil {
+		// The classifier reports the error, or falls back
+		return c.inner.Classify(ctx, review, rankings)
+	}
+
+	key := classificationKey(c.model, template.Template, rankings, review)
+
+	if result, ok := c.find(ctx, key, rankings); ok {
+		c.counter.hit()
+		return result, nil
+	}
+
+	c.counter.miss()
+
+	result, err := c.inner.Classify(ctx, review, rankings)
+
+	if err != nil {
+		return result, err
+	}
+
+	// A version activated while the LLM was asked must not be stored under the key of the previous one
+	if result.Raw_response != "" && result.Prompt_version == template.Version {
+		now := time.Now()
+
+		// The ranking is already chosen, storing it does not depend on the caller still waiting
+		err := c.store.Save(context.WithoutCancel(ctx), models.Classification{
+			Key:            key,
+			Ranking:        result.Ranking,
+			Classifier:     result.Classifier,
+			Prompt_version: result.Prompt_version,
+			Model:          result.Model,
+			Raw_response:   result.Raw_response,
+			Created_at:     now,
+			Expires_at:     now.Add(c.ttl),
+		})
+
+		if err != nil {
+			log.Println("Failed to store the classification in the cache:", err)
+		}
+	}
+
+	return result, nil
+}
+
+// find returns the stored classification with the current version of its ranking.
+// A failing store is treated as a miss, the LLM is asked instead.
+func (c *Classifier) find(ctx context.Context, key string, rankings []models.Ranking) (classifier.Result, bool) {
+	cached, err := c.store.Find(ctx, key)
+
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Println("Failed to read the classification cache:", err)
+		}
+
+		return classifier.Result{}, false
+	}
+
+	for _, ranking := range rankings {
+		if ranking.Ranking_name == cached.Ranking.Ranking_name && !ranking.Not_ranked {
+			return classifier.Result{
+				Ranking:        ranking,
+				Classifier:     cached.Classifier,
+				Prompt_version: cached.Prompt_version,
+				Model:          cached.Model,
+				Raw_response:   cached.Raw_response,
+				Cached:         true,
+			}, true
+		}
+	}
+
+	return classifier.Result{}, false
+}
+
+// classificationKey hashes the inputs of a classification. The rankings are sorted by name so their order
+// does not matter, runs of spaces and line breaks in the review are collapsed, its case is kept.
+func classificationKey(model string, template string, rankings []models.Ranking, review string) string {
+	offered := make([]string, 0, len(rankings))
+
+	for _, ranking := range rankings {
+		if !ranking.Not_ranked {
+			offered = append(offered, ranking.Ranking_name+"="+strconv.Itoa(ranking.Ranking_value))
+		}
+	}
+
+	slices.Sort(offered)
+
+	// The parts are separated by control characters, which the reviews and templates do not use
+	hash := sha256.Sum256([]byte(strings.Join([]string{
+		model,
+		template,
+		strings.Join(offered, "\x00"),
+		strings.Join(strings.Fields(review), " "),
+	}, "\x01")))
+
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+)
+
+// RankingRepository wraps a RankingRepository and keeps the result of FindAll in the process for ttl,
+// so ranking every review does not scan the rankings collection. Insert, Update and Delete drop the cached
+// rankings at once, the changes made by another server instance are seen when the ttl expires.
+type RankingRepository struct {
+	repository.RankingRepository
+	ttl     time.Duration
+	counter *Counter
+
+	mu       sync.Mutex
+	rankings []models.Ranking
+	expires  time.Time
+	// Incremented by every invalidation, a FindAll started before one does not cache what it read
+	generation uint64
+}
+
+func NewRankingRepository(rankings repository.RankingRepository, ttl time.Duration, counter *Counter) *RankingRepository {
+	counter.enabled.Store(true)
+
+	return &RankingRepository{RankingRepository: rankings, ttl: ttl, counter: counter}
+}
+
+func (r *RankingRepository) FindAll(ctx context.Context) ([]models.Ranking, error) {
+	r.mu.Lock()
+
+	if r.rankings != nil && time.Now().Before(r.expires) {
+		// The callers get a copy, they may modify the slice
+		rankings := slices.Clone(r.rankings)
+		r.mu.Unlock()
+
+		r.counter.hit()
+		return rankings, nil
+	}
+
+	generation := r.generation
+	r.mu.Unlock()
+
+	r.counter.miss()
+
+	rankings, err := r.RankingRepository.FindAll(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if generation == r.generation {
+		r.rankings = slices.Clone(rankings)
+		r.expires = time.Now().Add(r.ttl)
+	}
+
+	return rankings, nil
+}
+
+func (r *RankingRepository) Insert(ctx context.Context, ranking models.Ranking) error {
+	defer r.Invalidate()
+
+	return r.RankingRepository.Insert(ctx, ranking)
+}
+
+func (r *RankingRepository) Update(ctx context.Context, name string, ranking models.Ranking) error {
+	defer r.Invalidate()
+
+	return r.RankingRepository.Update(ctx, name, ranking)
+}
+
+func (r *RankingRepository) Delete(ctx context.Context, name string) error {
+	defer r.Invalidate()
+
+	return r.RankingRepository.Delete(ctx, name)
+}
+
+// Invalidate drops the cached rankings, the next FindAll reads the collection.
+// It also runs after a failed write, which may have been applied before the error.
+func (r *RankingRepository) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rankings = nil
+	r.generation++
+	r.counter.invalidate()
+}
@@ -0,0 +1,59 @@
+package cache
+
+import "sync/atomic"
+
+// Counter counts the lookups of a cache, it is safe for concurrent use.
+// It is enabled by the constructor of the cache using it, a cache turned off in the config leaves it at zero.
+type Counter struct {
+	enabled       atomic.Bool
+	hits          atomic.Int64
+	misses        atomic.Int64
+	invalidations atomic.Int64
+}
+
+func (c *Counter) hit()        { c.hits.Add(1) }
+func (c *Counter) miss()       { c.misses.Add(1) }
+func (c *Counter) invalidate() { c.invalidations.Add(1) }
+
+// CounterSnapshot is the value of a Counter at one moment, Hit_ratio is 0 before the first lookup
+type CounterSnapshot struct {
+	Enabled       bool    `json:"enabled"`
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	Invalidations int64   `json:"invalidations"`
+	Hit_ratio     float64 `json:"hit_ratio"`
+}
+
+// Snapshot reads the counters
+func (c *Counter) Snapshot() CounterSnapshot {
+	snapshot := CounterSnapshot{
+		Enabled:       c.enabled.Load(),
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+
+	if lookups := snapshot.Hits + snapshot.Misses; lookups > 0 {
+		snapshot.Hit_ratio = float64(snapshot.Hits) / float64(lookups)
+	}
+
+	return snapshot
+}
+
+// Stats groups the counters of the caches of the server, since its start
+type Stats struct {
+	// In-process cache of the rankings collection
+	Rankings Counter
+	// Classification cache, a miss is a review sent to the classifier
+	Classifications Counter
+}
+
+// StatsSnapshot is the body of GET /cachestats
+type StatsSnapshot struct {
+	Rankings        CounterSnapshot `json:"rankings"`
+	Classifications CounterSnapshot `json:"classifications"`
+}
+
+func (s *Stats) Snapshot() StatsSnapshot {
+	return StatsSnapshot{Rankings: s.Rankings.Snapshot(), Classifications: s.Classifications.Snapshot()}
+}
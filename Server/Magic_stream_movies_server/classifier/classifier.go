@@ -0,0 +1,166 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// Providers selected by the LLM_PROVIDER setting
+const (
+	ProviderOpenAI           = "openai"
+	ProviderOpenAICompatible = "openai_compatible"
+	ProviderLocal            = "local"
+	ProviderFake             = "fake"
+)
+
+// Values of the CLASSIFIER_FALLBACK setting
+const (
+	FallbackLocal = "local"
+	FallbackNone  = "none"
+)
+
+// ErrNoRankings is returned when the rankings collection has no ranking a review could be given
+var ErrNoRankings = errors.New("no ranking to classify the review with")
+
+// Result is the ranking given to a review and the name of the classifier that chose it.
+// The prompt template version, model and raw answer are only set when an LLM chose the ranking,
+// Cached when its answer was reused from the classification cache.
+type Result struct {
+	Ranking        models.Ranking
+	Classifier     string
+	Prompt_version int
+	Model          string
+	Raw_response   string
+	Cached         bool
+}
+
+// Audit returns what is recorded with the ranking of the movie
+func (r Result) Audit() models.RankingAudit {
+	return models.RankingAudit{
+		Classifier:     r.Classifier,
+		Prompt_version: r.Prompt_version,
+		Model:          r.Model,
+		Raw_response:   r.Raw_response,
+		Cached:         r.Cached,
+		Ranked_at:      time.Now(),
+	}
+}
+
+// SentimentClassifier ranks an admin review with one of the rankings of the catalogue
+type SentimentClassifier interface {
+	// Classify returns the ranking matching the sentiment of the review, always one of the given rankings
+	Classify(ctx context.Context, review string, rankings []models.Ranking) (Result, error)
+}
+
+// New returns the classifier of the configured provider, the LLM providers rank the reviews with the active
+// template of templates, or BASE_PROMPT_TEMPLATE when templates is nil. Unless CLASSIFIER_FALLBACK is "none",
+// the local classifier, trained with the reviews of the given movies, takes over when the provider fails.
+func New(cfg *config.Config, training []models.Movie, templates TemplateSource) (SentimentClassifier, error) {
+	local := NewLocalClassifier()
+	local.Train(training)
+
+	if cfg.LLM_provider == ProviderLocal {
+		return local, nil
+	}
+
+	if cfg.LLM_provider == ProviderOpenAI && cfg.OpenAI_API_key == "" && cfg.Classifier_fallback == FallbackLocal {
+		log.Println("Warning: OPENAI_API_KEY is not set, the reviews are ranked by the local classifier")
+		return local, nil
+	}
+
+	if templates == nil {
+		templates = StaticTemplate(cfg.Base_prompt_template)
+	}
+
+	primary, err := newProvider(cfg, templates)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Classifier_fallback == FallbackNone {
+		return primary, nil
+	}
+
+	return NewFallbackClassifier(primary, local), nil
+}
+
+// Model used by the OpenAI client when LLM_MODEL is empty
+const defaultOpenAIModel = "gpt-3.5-turbo"
+
+// ModelName returns the model the configured provider asks, empty for the providers without a model
+func ModelName(cfg *config.Config) string {
+	switch cfg.LLM_provider {
+	case ProviderOpenAI:
+		if cfg.LLM_model == "" {
+			return defaultOpenAIModel
+		}
+
+		return cfg.LLM_model
+	case ProviderOpenAICompatible:
+		return cfg.LLM_model
+	}
+
+	return ""
+}
+
+// newProvider returns the classifier of a remote or fake provider
+func newProvider(cfg *config.Config, templates TemplateSource) (SentimentClassifier, error) {
+	switch cfg.LLM_provider {
+	case ProviderOpenAI:
+		modelName := ModelName(cfg)
+
+		model, err := openai.New(openai.WithToken(cfg.OpenAI_API_key), openai.WithModel(modelName))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return NewLLMClassifier(ProviderOpenAI, modelName, model, templates, llmOptions(cfg)), nil
+
+	case ProviderOpenAICompatible:
+		// Local servers usually ignore the key but the client refuses to start without one
+		token := cfg.OpenAI_API_key
+
+		if token == "" {
+			token = "unused"
+		}
+
+		model, err := openai.New(openai.WithBaseURL(cfg.LLM_base_url), openai.WithModel(cfg.LLM_model), openai.WithToken(token))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return NewLLMClassifier(ProviderOpenAICompatible, cfg.LLM_model, model, templates, llmOptions(cfg)), nil
+
+	case ProviderFake:
+		return NewFakeClassifier(), nil
+	}
+
+	return nil, fmt.Errorf("unknown LLM provider %q", cfg.LLM_provider)
+}
+
+func llmOptions(cfg *config.Config) LLMOptions {
+	return LLMOptions{JSON_mode: cfg.LLM_json_mode, Max_retries: cfg.LLM_max_retries}
+}
+
+// candidates returns the rankings a review can be given, every ranking except "not ranked"
+func candidates(rankings []models.Ranking) []models.Ranking {
+	var result []models.Ranking
+
+	for _, ranking := range rankings {
+		if !ranking.Not_ranked {
+			result = append(result, ranking)
+		}
+	}
+
+	return result
+}
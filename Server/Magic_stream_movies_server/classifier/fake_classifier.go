@@ -0,0 +1,41 @@
+package classifier
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// FakeClassifier is a deterministic classifier without any network call, for tests and offline development.
+// It returns the ranking whose name appears in the review, e.g. "An Excellent movie" gets Excellent,
+// and otherwise the ranking with the lowest value.
+type FakeClassifier struct{}
+
+func NewFakeClassifier() *FakeClassifier {
+	return &FakeClassifier{}
+}
+
+func (FakeClassifier) Classify(ctx context.Context, review string, rankings []models.Ranking) (Result, error) {
+	offered := candidates(rankings)
+
+	if len(offered) == 0 {
+		return Result{}, ErrNoRankings
+	}
+
+	// Same answer whatever the order of the rankings collection
+	slices.SortFunc(offered, func(a, b models.Ranking) int {
+		return a.Ranking_value - b.Ranking_value
+	})
+
+	lowered := strings.ToLower(review)
+
+	for _, ranking := range offered {
+		if strings.Contains(lowered, strings.ToLower(ranking.Ranking_name)) {
+			return Result{Ranking: ranking, Classifier: ProviderFake}, nil
+		}
+	}
+
+	return Result{Ranking: offered[0], Classifier: ProviderFake}, nil
+}
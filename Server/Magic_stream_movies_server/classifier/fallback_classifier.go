@@ -0,0 +1,48 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// learner is a classifier improving with every ranked review
+type learner interface {
+	Learn(review string, ranking models.Ranking)
+}
+
+// FallbackClassifier asks the primary classifier and, when it fails (API down, missing key, timeout),
+// the fallback one. The result tells which of them ranked the review.
+// A fallback able to learn, like the local classifier, learns the rankings given by the primary.
+type FallbackClassifier struct {
+	primary  SentimentClassifier
+	fallback SentimentClassifier
+}
+
+func NewFallbackClassifier(primary, fallback SentimentClassifier) *FallbackClassifier {
+	return &FallbackClassifier{primary: primary, fallback: fallback}
+}
+
+func (f *FallbackClassifier) Classify(ctx context.Context, review string, rankings []models.Ranking) (Result, error) {
+	result, err := f.primary.Classify(ctx, review, rankings)
+
+	if err == nil {
+		if learner, ok := f.fallback.(learner); ok {
+			learner.Learn(review, result.Ranking)
+		}
+
+		return result, nil
+	}
+
+	// The caller gave up, only a timeout of the primary falls back
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return result, err
+	}
+
+	log.Println("Review classifier failed, using the fallback:", err)
+
+	// The fallback runs even if the request context expired waiting for the primary
+	return f.fallback.Classify(context.WithoutCancel(ctx), review, rankings)
+}
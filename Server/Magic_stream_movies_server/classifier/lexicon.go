@@ -0,0 +1,91 @@
+package classifier
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Polarity of common review words, from -3 (very negative) to 3 (very positive)
+var lexicon = map[string]float64{
+	"masterpiece": 3, "excellent": 3, "outstanding": 3, "superb": 3, "brilliant": 3, "amazing": 3,
+	"wonderful": 3, "perfect": 3, "stunning": 3, "magnificent": 3, "flawless": 3, "unforgettable": 3,
+	"fantastic": 3, "extraordinary": 3, "breathtaking": 3, "phenomenal": 3, "sublime": 3,
+	"great": 2, "good": 2, "beautiful": 2, "moving": 2, "compelling": 2, "gripping": 2, "delightful": 2,
+	"enjoyable": 2, "impressive": 2, "charming": 2, "clever": 2, "strong": 2, "powerful": 2, "touching": 2,
+	"entertaining": 2, "recommend": 2, "recommended": 2, "love": 2, "loved": 2, "best": 2, "fun": 2,
+	"solid": 1, "nice": 1, "decent": 1, "fine": 0.5, "likable": 1, "pleasant": 1, "interesting": 1, "worth": 1,
+	"like": 1, "liked": 1, "engaging": 1, "funny": 1, "well": 1,
+	"average": -0.5, "mediocre": -1, "forgettable": -1, "predictable": -1, "slow": -1, "uneven": -1,
+	"flat": -1, "long": -0.5, "overlong": -1, "cliched": -1, "bland": -1, "dull": -2, "boring": -2,
+	"weak": -2, "bad": -2, "poor": -2, "disappointing": -2, "disappointment": -2, "messy": -2, "tedious": -2,
+	"confusing": -1, "silly": -1, "waste": -2, "wasted": -2, "annoying": -2, "lifeless": -2, "hate": -2,
+	"terrible": -3, "awful": -3, "horrible": -3, "worst": -3, "dreadful": -3, "unwatchable": -3,
+	"atrocious": -3, "abysmal": -3, "garbage": -3, "painful": -2, "pointless": -2, "disaster": -3,
+}
+
+// Words inverting the polarity of the next few words, "not good", "never boring"
+var negations = map[string]bool{
+	"not": true, "no": true, "never": true, "nothing": true, "hardly": true, "barely": true,
+	"isn't": true, "wasn't": true, "aren't": true, "don't": true, "doesn't": true, "didn't": true,
+	"can't": true, "cannot": true, "won't": true, "without": true, "nor": true,
+}
+
+// Words strengthening the next word, "very good", "truly awful"
+var intensifiers = map[string]float64{
+	"very": 1.5, "really": 1.5, "extremely": 1.8, "truly": 1.5, "incredibly": 1.8, "absolutely": 1.8,
+	"so": 1.3, "utterly": 1.8, "quite": 1.2, "too": 1.2, "somewhat": 0.7, "slightly": 0.6, "rather": 0.8,
+}
+
+// Number of words after a negation whose polarity is inverted
+const negationScope = 3
+
+// Normalisation constant, a single word of polarity 3 scores about 0.83
+const lexiconAlpha = 4
+
+// words lower-cases the text and splits it on everything that is not a letter, a digit or an apostrophe
+func words(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "’", "'")
+
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
+
+// lexiconScore returns the sentiment of the text between -1 (negative) and 1 (positive)
+func lexiconScore(text string) float64 {
+	sum := 0.0
+	negated := 0
+	intensity := 1.0
+
+	for _, word := range words(text) {
+		if negations[word] {
+			negated = negationScope
+			continue
+		}
+
+		if factor, ok := intensifiers[word]; ok {
+			intensity *= factor
+			continue
+		}
+
+		if polarity, ok := lexicon[word]; ok {
+			polarity *= intensity
+
+			if negated > 0 {
+				// "not bad" is mildly positive rather than as good as "good"
+				polarity *= -0.5
+			}
+
+			sum += polarity
+		}
+
+		intensity = 1
+
+		if negated > 0 {
+			negated--
+		}
+	}
+
+	return sum / math.Sqrt(sum*sum+lexiconAlpha)
+}
@@ -0,0 +1,110 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ErrUnknownRanking is returned when the model keeps answering something that is not one of the rankings
+var ErrUnknownRanking = errors.New("the model did not answer with one of the rankings")
+
+// LLMOptions tune how the model is asked
+type LLMOptions struct {
+	// Ask for a JSON object {"ranking": "..."}, for the providers supporting the JSON response format
+	JSON_mode bool
+	// Number of corrective prompts sent after an answer that is not one of the rankings
+	Max_retries int
+}
+
+// LLMClassifier asks a language model to name the ranking of the review.
+// Any langchaingo model works, the OpenAI client covers OpenAI and the servers compatible with its API.
+// The answer is normalised and matched against the rankings, an unknown answer is never returned.
+type LLMClassifier struct {
+	name      string
+	modelName string
+	model     llms.Model
+	templates TemplateSource
+	options   LLMOptions
+}
+
+// NewLLMClassifier reports its rankings under the given name and model name. Every review is ranked
+// with the active prompt template of templates, see renderPrompt for its placeholders.
+func NewLLMClassifier(name string, modelName string, model llms.Model, templates TemplateSource, options LLMOptions) *LLMClassifier {
+	return &LLMClassifier{name: name, modelName: modelName, model: model, templates: templates, options: options}
+}
+
+func (l *LLMClassifier) Classify(ctx context.Context, review string, rankings []models.Ranking) (Result, error) {
+	offered := candidates(rankings)
+
+	if len(offered) == 0 {
+		return Result{}, ErrNoRankings
+	}
+
+	names := make([]string, len(offered))
+
+	for i, ranking := range offered {
+		names[i] = ranking.Ranking_name
+	}
+
+	allowed := strings.Join(names, ",")
+
+	template, err := l.templates.FindActive(ctx)
+
+	if err != nil {
+		return Result{}, fmt.Errorf("loading the prompt template: %w", err)
+	}
+
+	// Replace the {rankings} placeholder with the list of sentiment names in the rankings collection
+	// and put the admin review in place of {review}, or after the prompt
+	prompt := renderPrompt(template.Template, allowed, review)
+
+	var callOptions []llms.CallOption
+
+	if l.options.JSON_mode {
+		// The JSON response format requires the prompt to mention JSON
+		prompt += "\n\nAnswer with a JSON object of the form {\"ranking\": \"<one of " + allowed + ">\"}."
+		callOptions = append(callOptions, llms.WithJSONMode())
+	}
+
+	messages := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, prompt)}
+
+	var answer string
+
+	for attempt := 0; attempt <= l.options.Max_retries; attempt++ {
+		response, err := l.model.GenerateContent(ctx, messages, callOptions...)
+
+		if err != nil {
+			return Result{}, err
+		}
+
+		if len(response.Choices) == 0 {
+			return Result{}, errors.New("the model returned no answer")
+		}
+
+		answer = response.Choices[0].Content
+
+		if ranking, ok := matchRanking(answer, offered); ok {
+			return Result{
+				Ranking:        ranking,
+				Classifier:     l.name,
+				Prompt_version: template.Version,
+				Model:          l.modelName,
+				Raw_response:   answer,
+			}, nil
+		}
+
+		// Show the model its answer and ask again for a valid ranking
+		messages = append(messages,
+			llms.TextParts(llms.ChatMessageTypeAI, answer),
+			llms.TextParts(llms.ChatMessageTypeHuman, "\""+strings.TrimSpace(answer)+"\" is not one of the allowed rankings. "+
+				"Answer with exactly one of "+allowed+" and nothing else."),
+		)
+	}
+
+	return Result{}, fmt.Errorf("%w after %d attempts, last answer %q", ErrUnknownRanking, l.options.Max_retries+1, answer)
+}
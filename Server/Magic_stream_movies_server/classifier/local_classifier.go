@@ -0,0 +1,70 @@
+package classifier
+
+import (
+	"context"
+	"math"
+	"slices"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// Names reported for the rankings given by the local classifier
+const (
+	ClassifierLexicon    = "lexicon"
+	ClassifierNaiveBayes = "naive_bayes"
+)
+
+// LocalClassifier ranks reviews in pure Go, without any network call.
+// Once it has learnt enough admin reviews of the catalogue it uses a naive Bayes model,
+// before that it scores the review with a sentiment lexicon and maps the score onto the rankings,
+// the lowest ranking value being the best.
+type LocalClassifier struct {
+	bayes *naiveBayes
+}
+
+func NewLocalClassifier() *LocalClassifier {
+	return &LocalClassifier{bayes: newNaiveBayes()}
+}
+
+// Train learns the admin reviews of the movies and their rankings, the unranked movies are skipped
+func (l *LocalClassifier) Train(movies []models.Movie) {
+	for _, movie := range movies {
+		l.Learn(movie.Admin_review, movie.Ranking)
+	}
+}
+
+// Learn adds one example to the naive Bayes model
+func (l *LocalClassifier) Learn(review string, ranking models.Ranking) {
+	if review == "" || ranking.Ranking_name == "" || ranking.Ranking_value == 0 || ranking.Not_ranked {
+		return
+	}
+
+	l.bayes.learn(review, ranking.Ranking_name)
+}
+
+func (l *LocalClassifier) Classify(ctx context.Context, review string, rankings []models.Ranking) (Result, error) {
+	offered := candidates(rankings)
+
+	if len(offered) == 0 {
+		return Result{}, ErrNoRankings
+	}
+
+	names := make([]string, len(offered))
+
+	for i, ranking := range offered {
+		names[i] = ranking.Ranking_name
+	}
+
+	if name, ok := l.bayes.predict(review, names); ok {
+		return Result{Ranking: offered[slices.Index(names, name)], Classifier: ClassifierNaiveBayes}, nil
+	}
+
+	// Best ranking first, the score 1 maps to the first ranking and -1 to the last one
+	slices.SortFunc(offered, func(a, b models.Ranking) int {
+		return a.Ranking_value - b.Ranking_value
+	})
+
+	position := int(math.Round((1 - lexiconScore(review)) / 2 * float64(len(offered)-1)))
+
+	return Result{Ranking: offered[position], Classifier: ClassifierLexicon}, nil
+}
@@ -0,0 +1,152 @@
+package classifier
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// matchRanking finds which of the offered rankings the model answered.
+// The answer may be a JSON object {"ranking": "..."}, a quoted string or free text:
+// it is normalised (case, punctuation, underscores) and matched exactly, then by the only ranking
+// named in the text, then by the closest name within a few typos. ok is false when none or several match,
+// and when the text outside the ranking name is negated ("Not Good" is not Good).
+func matchRanking(answer string, offered []models.Ranking) (models.Ranking, bool) {
+	answer = extractAnswer(answer)
+	normalized := normalizeAnswer(answer)
+
+	if normalized == "" {
+		return models.Ranking{}, false
+	}
+
+	for _, ranking := range offered {
+		if normalizeAnswer(ranking.Ranking_name) == normalized {
+			return ranking, true
+		}
+	}
+
+	// "The ranking is Excellent." names a single ranking
+	padded := " " + normalized + " "
+	found := -1
+
+	for i, ranking := range offered {
+		name := " " + normalizeAnswer(ranking.Ranking_name) + " "
+
+		if strings.Contains(padded, name) {
+			if found >= 0 || negated(strings.Replace(padded, name, " ", 1)) {
+				return models.Ranking{}, false
+			}
+			found = i
+		}
+	}
+
+	if found >= 0 {
+		return offered[found], true
+	}
+
+	// A negated answer names no ranking, even misspelt
+	if negated(normalized) {
+		return models.Ranking{}, false
+	}
+
+	// "Excelent", "Terible": the closest name, if it is close enough and the only one that close
+	best, bestDistance, tie := -1, 0, false
+
+	for i, ranking := range offered {
+		distance := levenshtein(normalized, normalizeAnswer(ranking.Ranking_name))
+
+		if best < 0 || distance < bestDistance {
+			best, bestDistance, tie = i, distance, false
+		} else if distance == bestDistance {
+			tie = true
+		}
+	}
+
+	if best >= 0 && !tie && bestDistance <= max(1, len(normalized)/4) {
+		return offered[best], true
+	}
+
+	return models.Ranking{}, false
+}
+
+// negated tells if a normalized text contains a negation word of the lexicon,
+// whose apostrophe normalizeAnswer dropped ("isnt")
+func negated(text string) bool {
+	return slices.ContainsFunc(strings.Fields(text), func(word string) bool {
+		contracted, ok := strings.CutSuffix(word, "nt")
+		return negations[word] || (ok && negations[contracted+"n't"])
+	})
+}
+
+// extractAnswer returns the ranking field of a JSON answer, or the answer itself
+func extractAnswer(answer string) string {
+	answer = strings.TrimSpace(answer)
+
+	// Some models wrap the JSON in a markdown code block
+	answer = strings.TrimPrefix(strings.TrimPrefix(answer, "```json"), "```")
+	answer = strings.TrimSpace(strings.TrimSuffix(answer, "```"))
+
+	var object map[string]any
+
+	if json.Unmarshal([]byte(answer), &object) == nil {
+		for _, key := range []string{"ranking", "ranking_name", "sentiment", "label"} {
+			if value, ok := object[key].(string); ok {
+				return value
+			}
+		}
+	}
+
+	var text string
+
+	if json.Unmarshal([]byte(answer), &text) == nil {
+		return text
+	}
+
+	return answer
+}
+
+// normalizeAnswer lower-cases the text, turns underscores and dashes into spaces,
+// drops the other punctuation and collapses the spaces
+func normalizeAnswer(text string) string {
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return unicode.ToLower(r)
+		case r == '_' || r == '-' || unicode.IsSpace(r):
+			return ' '
+		}
+		return -1
+	}, text)
+
+	return strings.Join(strings.Fields(mapped), " ")
+}
+
+// levenshtein returns the number of single letter edits between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
@@ -0,0 +1,59 @@
+package classifier
+
+import (
+	"testing"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+func TestMatchRanking(t *testing.T) {
+	offered := []models.Ranking{
+		{Ranking_value: 1, Ranking_name: "Excellent"},
+		{Ranking_value: 2, Ranking_name: "Good"},
+		{Ranking_value: 3, Ranking_name: "Okay"},
+		{Ranking_value: 4, Ranking_name: "Bad"},
+		{Ranking_value: 5, Ranking_name: "Terrible"},
+	}
+
+	tests := []struct {
+		answer string
+		want   string // empty when no ranking must match
+	}{
+		{answer: "Excellent", want: "Excellent"},
+		{answer: "  good. ", want: "Good"},
+		{answer: `{"ranking": "Bad"}`, want: "Bad"},
+		{answer: "```json\n{\"ranking\": \"Okay\"}\n```", want: "Okay"},
+		{answer: `"Terrible"`, want: "Terrible"},
+		{answer: "The ranking is Excellent.", want: "Excellent"},
+		{answer: "Excelent", want: "Excellent"},
+		{answer: "Terible", want: "Terrible"},
+		{answer: "Good or Bad", want: ""},
+		{answer: "Not Good", want: ""},
+		{answer: "not excellent at all", want: ""},
+		{answer: "It isn't bad", want: ""},
+		{answer: "Never terible", want: ""},
+		{answer: "Amazing", want: ""},
+		{answer: "", want: ""},
+	}
+
+	for _, test := range tests {
+		ranking, ok := matchRanking(test.answer, offered)
+
+		if ok != (test.want != "") || ranking.Ranking_name != test.want {
+			t.Errorf("matchRanking(%q) = %q, %v, want %q", test.answer, ranking.Ranking_name, ok, test.want)
+		}
+	}
+}
+
+func TestMatchRankingNamedWithANegation(t *testing.T) {
+	offered := []models.Ranking{
+		{Ranking_value: 1, Ranking_name: "Good"},
+		{Ranking_value: 2, Ranking_name: "Not_Great"},
+	}
+
+	for _, answer := range []string{"Not_Great", "The ranking is not great"} {
+		if ranking, ok := matchRanking(answer, offered); !ok || ranking.Ranking_name != "Not_Great" {
+			t.Errorf("matchRanking(%q) = %q, %v, want Not_Great", answer, ranking.Ranking_name, ok)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package classifier
+
+import (
+	"math"
+	"sync"
+)
+
+// Fewest examples of a ranking needed before the naive Bayes model predicts it
+const minExamplesPerRanking = 3
+
+// Frequent words carrying no sentiment, ignored by the model
+var stopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "of": true, "and": true, "or": true, "is": true, "it": true, "it's": true,
+	"was": true, "this": true, "that": true, "to": true, "in": true, "for": true, "with": true, "on": true,
+	"as": true, "at": true, "be": true, "by": true, "its": true, "film": true, "movie": true,
+}
+
+// features returns the words of the review the model learns from
+func features(review string) []string {
+	var result []string
+
+	for _, word := range words(review) {
+		if !stopWords[word] {
+			result = append(result, word)
+		}
+	}
+
+	return result
+}
+
+// naiveBayes is a multinomial naive Bayes model with Laplace smoothing,
+// learning which words the admin reviews of each ranking use
+type naiveBayes struct {
+	mu         sync.RWMutex
+	classes    map[string]*classCounts
+	vocabulary map[string]bool
+	documents  int
+}
+
+// classCounts are the counts of one ranking
+type classCounts struct {
+	documents int
+	words     map[string]int
+	total     int
+}
+
+func newNaiveBayes() *naiveBayes {
+	return &naiveBayes{classes: map[string]*classCounts{}, vocabulary: map[string]bool{}}
+}
+
+// learn adds the review as an example of the ranking
+func (nb *naiveBayes) learn(review, rankingName string) {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+
+	counts, ok := nb.classes[rankingName]
+
+	if !ok {
+		counts = &classCounts{words: map[string]int{}}
+		nb.classes[rankingName] = counts
+	}
+
+	counts.documents++
+	nb.documents++
+
+	for _, word := range features(review) {
+		counts.words[word]++
+		counts.total++
+		nb.vocabulary[word] = true
+	}
+}
+
+// predict returns the most probable of the given ranking names, only the rankings with enough examples compete.
+// ok is false when fewer than two rankings can be told apart or no word of the review was ever seen,
+// the model has not learnt enough yet.
+func (nb *naiveBayes) predict(review string, rankingNames []string) (best string, ok bool) {
+	nb.mu.RLock()
+	defer nb.mu.RUnlock()
+
+	var trained []string
+
+	for _, name := range rankingNames {
+		if counts, found := nb.classes[name]; found && counts.documents >= minExamplesPerRanking {
+			trained = append(trained, name)
+		}
+	}
+
+	if len(trained) < 2 {
+		return "", false
+	}
+
+	// Without any known word every ranking is as likely, the model has nothing to say about this review
+	var reviewWords []string
+
+	for _, word := range features(review) {
+		if nb.vocabulary[word] {
+			reviewWords = append(reviewWords, word)
+		}
+	}
+
+	if len(reviewWords) == 0 {
+		return "", false
+	}
+
+	vocabularySize := float64(len(nb.vocabulary))
+	bestScore := math.Inf(-1)
+
+	for _, name := range trained {
+		counts := nb.classes[name]
+
+		// log P(ranking) + sum of log P(word | ranking)
+		score := math.Log(float64(counts.documents) / float64(nb.documents))
+
+		for _, word := range reviewWords {
+			score += math.Log((float64(counts.words[word]) + 1) / (float64(counts.total) + vocabularySize))
+		}
+
+		if score > bestScore {
+			best, bestScore = name, score
+		}
+	}
+
+	return best, true
+}
@@ -0,0 +1,72 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// Placeholders of a prompt template: {rankings} is required, the review is appended when {review} is missing
+const (
+	rankingsPlaceholder = "{rankings}"
+	reviewPlaceholder   = "{review}"
+)
+
+// placeholderPattern finds the placeholders of a template, braces around anything but a word are left alone
+// so a template can show a JSON example
+var placeholderPattern = regexp.MustCompile(`\{[A-Za-z_]+\}`)
+
+// TemplateSource gives the prompt template the reviews are ranked with,
+// implemented by the prompt templates repository
+type TemplateSource interface {
+	// FindActive returns the active template version
+	FindActive(ctx context.Context) (models.PromptTemplate, error)
+}
+
+// StaticTemplate is a TemplateSource always giving the same template, reported as version 0
+type StaticTemplate string
+
+func (t StaticTemplate) FindActive(ctx context.Context) (models.PromptTemplate, error) {
+	return models.PromptTemplate{Template: string(t), Active: true}, nil
+}
+
+// ValidateTemplate checks that the template has the {rankings} placeholder, at most one {review} placeholder
+// and no other placeholder
+func ValidateTemplate(template string) error {
+	var problems []string
+
+	if !strings.Contains(template, rankingsPlaceholder) {
+		problems = append(problems, "the "+rankingsPlaceholder+" placeholder is required")
+	}
+
+	if strings.Count(template, reviewPlaceholder) > 1 {
+		problems = append(problems, "the "+reviewPlaceholder+" placeholder can only be used once")
+	}
+
+	for _, placeholder := range placeholderPattern.FindAllString(template, -1) {
+		if placeholder != rankingsPlaceholder && placeholder != reviewPlaceholder {
+			problems = append(problems, fmt.Sprintf("unknown placeholder %s, use %s and %s", placeholder, rankingsPlaceholder, reviewPlaceholder))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// renderPrompt fills the placeholders of the template with the allowed ranking names and the review
+func renderPrompt(template string, allowed string, review string) string {
+	prompt := strings.ReplaceAll(template, rankingsPlaceholder, allowed)
+
+	if strings.Contains(prompt, reviewPlaceholder) {
+		return strings.Replace(prompt, reviewPlaceholder, review, 1)
+	}
+
+	return prompt + review
+}
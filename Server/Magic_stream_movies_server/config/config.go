@@ -0,0 +1,319 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/joho/godotenv"
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// Config holds every setting of the server.
+// It is loaded once at startup by Load and then passed explicitly to the components that need it.
+//
+// Each field is read from the environment variable named by its env tag, from the .env file,
+// or from the yaml/toml key of the optional config file. Fields tagged secret are redacted by String,
+// fields tagged oneof only accept the listed values.
+type Config struct {
+	// Address the HTTP server listens on
+	Port string `env:"PORT" yaml:"port" toml:"port" default:"8080"`
+
+	// MongoDB connection
+	MongoDB_URI   string `env:"MONGODB_URI" yaml:"mongodb_uri" toml:"mongodb_uri" required:"true" secret:"true"`
+	Database_name string `env:"DATABASE_NAME" yaml:"database_name" toml:"database_name" required:"true"`
+
+	// Keys used to sign the access and refresh tokens
+	Secret_key         string `env:"SECRET_KEY" yaml:"secret_key" toml:"secret_key" required:"true" secret:"true"`
+	Secret_refresh_key string `env:"SECRET_REFRESH_KEY" yaml:"secret_refresh_key" toml:"secret_refresh_key" required:"true" secret:"true"`
+
+	// Email of the user promoted to ADMIN at startup, POST /register only creates USER accounts
+	Admin_email string `env:"ADMIN_EMAIL" yaml:"admin_email" toml:"admin_email"`
+
+	// Review ranking with the LLM, the prompt template must contain the {rankings} placeholder.
+	// It is stored as the first version of the prompt template, later versions are managed with /prompttemplates.
+	OpenAI_API_key       string `env:"OPENAI_API_KEY" yaml:"openai_api_key" toml:"openai_api_key" secret:"true"`
+	Base_prompt_template string `env:"BASE_PROMPT_TEMPLATE" yaml:"base_prompt_template" toml:"base_prompt_template" required:"true"`
+
+	// Classifier of the admin reviews: "openai", "openai_compatible" (any server speaking the OpenAI API,
+	// such as Ollama or llama.cpp, at LLM_BASE_URL), "local" (lexicon and naive Bayes, no network) or "fake" (deterministic)
+	LLM_provider string `env:"LLM_PROVIDER" yaml:"llm_provider" toml:"llm_provider" default:"openai" oneof:"openai openai_compatible local fake"`
+	LLM_base_url string `env:"LLM_BASE_URL" yaml:"llm_base_url" toml:"llm_base_url"`
+	// Model name, the OpenAI client default when empty with the openai provider
+	LLM_model string `env:"LLM_MODEL" yaml:"llm_model" toml:"llm_model"`
+	// Ask the LLM for a JSON answer, turn it off for OpenAI compatible servers without the JSON response format
+	LLM_json_mode bool `env:"LLM_JSON_MODE" yaml:"llm_json_mode" toml:"llm_json_mode" default:"true"`
+	// Number of corrective prompts sent when the LLM answers something that is not a ranking
+	LLM_max_retries int `env:"LLM_MAX_RETRIES" yaml:"llm_max_retries" toml:"llm_max_retries" default:"2"`
+	// "local" ranks the review with the local classifier when the provider fails, "none" answers with an error
+	Classifier_fallback string `env:"CLASSIFIER_FALLBACK" yaml:"classifier_fallback" toml:"classifier_fallback" default:"local" oneof:"local none"`
+
+	// Background classification of the admin reviews: number of workers, attempts per review
+	// before giving up, delay before the first retry (doubled after every failure)
+	Review_workers      int      `env:"REVIEW_WORKERS" yaml:"review_workers" toml:"review_workers" default:"2"`
+	Review_max_attempts int      `env:"REVIEW_MAX_ATTEMPTS" yaml:"review_max_attempts" toml:"review_max_attempts" default:"5"`
+	Review_retry_delay  Duration `env:"REVIEW_RETRY_DELAY" yaml:"review_retry_delay" toml:"review_retry_delay" default:"5s"`
+
+	// The rankings collection is kept in the process for RANKINGS_CACHE_TTL, a ranking change refreshes it at once.
+	// The rankings given by the LLM are stored by content hash and reused for CLASSIFICATION_CACHE_TTL. 0 turns a cache off.
+	Rankings_cache_ttl       Duration `env:"RANKINGS_CACHE_TTL" yaml:"rankings_cache_ttl" toml:"rankings_cache_ttl" default:"5m"`
+	Classification_cache_ttl Duration `env:"CLASSIFICATION_CACHE_TTL" yaml:"classification_cache_ttl" toml:"classification_cache_ttl" default:"720h"`
+
+	// Number of movies returned by GET /recommendedmovies
+	Recommended_movie_limit int64 `env:"RECOMMENDED_MOVIE_LIMIT" yaml:"recommended_movie_limit" toml:"recommended_movie_limit" default:"5"`
+
+	// Share of the collaborative signal (movies liked by users with the same tastes) in the recommendation score,
+	// from 0 to 1, the favourite genres and the admin ranking share the rest. Users with fewer liked movies
+	// than RECOMMENDER_MIN_INTERACTIONS get the best ranked movies of their favourite genres.
+	// The similarities between movies are recomputed every RECOMMENDER_REFRESH_INTERVAL.
+	Recommender_cf_weight        float64  `env:"RECOMMENDER_CF_WEIGHT" yaml:"recommender_cf_weight" toml:"recommender_cf_weight" default:"0.7"`
+	Recommender_min_interactions int      `env:"RECOMMENDER_MIN_INTERACTIONS" yaml:"recommender_min_interactions" toml:"recommender_min_interactions" default:"3"`
+	Recommender_refresh_interval Duration `env:"RECOMMENDER_REFRESH_INTERVAL" yaml:"recommender_refresh_interval" toml:"recommender_refresh_interval" default:"10m"`
+
+	// Backend of GET /search: "memory" keeps a typo tolerant index in the process, "mongo" uses a Mongo text index
+	Search_backend string `env:"SEARCH_BACKEND" yaml:"search_backend" toml:"search_backend" default:"memory" oneof:"memory mongo"`
+}
+
+// Duration is a time.Duration written like "5s" or "1m30s" in the environment and in the config files
+type Duration time.Duration
+
+// UnmarshalText parses the duration, the YAML and TOML decoders call it for the string values
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Load builds the configuration, from lowest to highest priority:
+// the default tags, the optional YAML (.yaml/.yml) or TOML (.toml) file at path,
+// the .env file and finally the environment variables.
+// When path is empty the CONFIG_FILE environment variable is used, if set.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+
+	if err := cfg.applyDefaults(); err != nil {
+		return nil, err
+	}
+
+	// godotenv never overrides variables already set in the environment
+	if err := godotenv.Load(".env"); err != nil {
+		log.Println("Warning: .env file not found")
+	}
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile decodes the config file, its format is chosen from the file extension
+func (cfg *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, cfg)
+	case ".toml":
+		err = toml.Unmarshal(content, cfg)
+	default:
+		return fmt.Errorf("unsupported config file format %q, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+
+	if err != nil {
+		return fmt.Errorf("decoding config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (cfg *Config) applyDefaults() error {
+	return cfg.eachField(func(field reflect.StructField, value reflect.Value) error {
+		if def, ok := field.Tag.Lookup("default"); ok {
+			return setField(field, value, def)
+		}
+		return nil
+	})
+}
+
+func (cfg *Config) applyEnv() error {
+	return cfg.eachField(func(field reflect.StructField, value reflect.Value) error {
+		if raw := os.Getenv(field.Tag.Get("env")); raw != "" {
+			return setField(field, value, raw)
+		}
+		return nil
+	})
+}
+
+// Validate reports every missing required key and invalid value at once
+func (cfg *Config) Validate() error {
+	var problems []string
+
+	cfg.eachField(func(field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get("required") == "true" && value.IsZero() {
+			problems = append(problems, field.Tag.Get("env")+" is required")
+		}
+
+		if allowed, ok := field.Tag.Lookup("oneof"); ok && !slices.Contains(strings.Fields(allowed), fmt.Sprint(value.Interface())) {
+			problems = append(problems, field.Tag.Get("env")+" must be one of: "+allowed)
+		}
+		return nil
+	})
+
+	if cfg.Base_prompt_template != "" && !strings.Contains(cfg.Base_prompt_template, "{rankings}") {
+		problems = append(problems, "BASE_PROMPT_TEMPLATE must contain the {rankings} placeholder")
+	}
+
+	switch cfg.LLM_provider {
+	case "openai":
+		if cfg.OpenAI_API_key == "" && cfg.Classifier_fallback == "none" {
+			problems = append(problems, "OPENAI_API_KEY is required with LLM_PROVIDER=openai and CLASSIFIER_FALLBACK=none")
+		}
+	case "openai_compatible":
+		if cfg.LLM_base_url == "" || cfg.LLM_model == "" {
+			problems = append(problems, "LLM_BASE_URL and LLM_MODEL are required with LLM_PROVIDER=openai_compatible")
+		}
+	}
+
+	if cfg.LLM_max_retries < 0 {
+		problems = append(problems, "LLM_MAX_RETRIES must not be negative")
+	}
+
+	if cfg.Review_workers <= 0 || cfg.Review_max_attempts <= 0 || cfg.Review_retry_delay <= 0 {
+		problems = append(problems, "REVIEW_WORKERS, REVIEW_MAX_ATTEMPTS and REVIEW_RETRY_DELAY must be positive")
+	}
+
+	if cfg.Rankings_cache_ttl < 0 || cfg.Classification_cache_ttl < 0 {
+		problems = append(problems, "RANKINGS_CACHE_TTL and CLASSIFICATION_CACHE_TTL must not be negative")
+	}
+
+	if cfg.Recommended_movie_limit <= 0 {
+		problems = append(problems, "RECOMMENDED_MOVIE_LIMIT must be a positive number")
+	}
+
+	if cfg.Recommender_cf_weight < 0 || cfg.Recommender_cf_weight > 1 {
+		problems = append(problems, "RECOMMENDER_CF_WEIGHT must be between 0 and 1")
+	}
+
+	if cfg.Recommender_min_interactions <= 0 || cfg.Recommender_refresh_interval <= 0 {
+		problems = append(problems, "RECOMMENDER_MIN_INTERACTIONS and RECOMMENDER_REFRESH_INTERVAL must be positive")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// String prints every setting with the secrets redacted, so the config can be logged safely
+func (cfg *Config) String() string {
+	var builder strings.Builder
+
+	cfg.eachField(func(field reflect.StructField, value reflect.Value) error {
+		shown := fmt.Sprint(value.Interface())
+
+		if field.Tag.Get("secret") == "true" && !value.IsZero() {
+			shown = "********"
+		}
+
+		fmt.Fprintf(&builder, "%s=%s\n", field.Tag.Get("env"), shown)
+		return nil
+	})
+
+	return builder.String()
+}
+
+// eachField calls fn for every field of the config carrying an env tag
+func (cfg *Config) eachField(fn func(field reflect.StructField, value reflect.Value) error) error {
+	structValue := reflect.ValueOf(cfg).Elem()
+	structType := structValue.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		if field.Tag.Get("env") == "" {
+			continue
+		}
+
+		if err := fn(field, structValue.Field(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setField converts the raw string to the type of the field
+func setField(field reflect.StructField, value reflect.Value, raw string) error {
+	// Durations are written like "5s" or "1m30s"
+	if duration, ok := value.Addr().Interface().(*Duration); ok {
+		if err := duration.UnmarshalText([]byte(raw)); err != nil {
+			return fmt.Errorf("%s must be a duration such as 5s or 1m: %w", field.Tag.Get("env"), err)
+		}
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number: %w", field.Tag.Get("env"), err)
+		}
+		value.SetInt(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number: %w", field.Tag.Get("env"), err)
+		}
+		value.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false: %w", field.Tag.Get("env"), err)
+		}
+		value.SetBool(parsed)
+	default:
+		return fmt.Errorf("%s has an unsupported type %s", field.Tag.Get("env"), value.Kind())
+	}
+
+	return nil
+}
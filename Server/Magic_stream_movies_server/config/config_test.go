@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadDurationsFromTheConfigFile(t *testing.T) {
+	files := map[string]string{
+		"config.toml": `mongodb_uri = "mongodb://localhost:27017"
+database_name = "magic_stream_movies"
+secret_key = "secret"
+secret_refresh_key = "refresh-secret"
+base_prompt_template = "Rank the review with one of {rankings}: "
+review_retry_delay = "1m30s"
+rankings_cache_ttl = "1m30s"
+classification_cache_ttl = "2h"
+recommender_refresh_interval = "5s"
+`,
+		"config.yaml": `mongodb_uri: mongodb://localhost:27017
+database_name: magic_stream_movies
+secret_key: secret
+secret_refresh_key: refresh-secret
+base_prompt_template: "Rank the review with one of {rankings}: "
+review_retry_delay: 1m30s
+rankings_cache_ttl: 1m30s
+classification_cache_ttl: "2h"
+recommender_refresh_interval: "5s"
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			// The variables of the environment would override the file
+			for _, key := range []string{"MONGODB_URI", "DATABASE_NAME", "SECRET_KEY", "SECRET_REFRESH_KEY", "BASE_PROMPT_TEMPLATE",
+				"REVIEW_RETRY_DELAY", "RANKINGS_CACHE_TTL", "CLASSIFICATION_CACHE_TTL", "RECOMMENDER_REFRESH_INTERVAL"} {
+				t.Setenv(key, "")
+			}
+
+			path := filepath.Join(t.TempDir(), name)
+
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.Review_retry_delay != Duration(90*time.Second) || cfg.Rankings_cache_ttl != Duration(90*time.Second) ||
+				cfg.Classification_cache_ttl != Duration(2*time.Hour) || cfg.Recommender_refresh_interval != Duration(5*time.Second) {
+				t.Fatalf("got the durations %v, %v, %v and %v, want 1m30s, 1m30s, 2h0m0s and 5s", cfg.Review_retry_delay, cfg.Rankings_cache_ttl, cfg.Classification_cache_ttl, cfg.Recommender_refresh_interval)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsAnInvalidDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+
+	if err := os.WriteFile(path, []byte(`review_retry_delay = "soon"`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Fatal("loaded a config file with an invalid duration")
+	}
+}
@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"net/http" // Standard library package for HTTP status codes
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/cache"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// GetCacheStats is the handler function for the GET /cachestats route.
+// It returns the hits, misses and invalidations of the rankings and classification caches since the server started.
+// A classification miss is a review sent to the classifier, so the misses bound the LLM calls.
+func GetCacheStats(stats *cache.Stats) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, stats.Snapshot())
+	}
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context" // Package for context handling, crucial for managing request lifecycles and timeouts
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http" // Standard library package for HTTP status codes
+	"time"     // Package for managing time and timeouts
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/importer"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// Content types of the export formats
+const (
+	contentTypeNDJSON = "application/x-ndjson"
+	contentTypeCSV    = "text/csv"
+	contentTypeJSON   = "application/json"
+)
+
+// The response is flushed to the client every exportFlushEvery movies
+const exportFlushEvery = 100
+
+// ExportMovies is the handler function for the GET /movies/export route.
+// It streams every movie matching the genre and ranking filters of GET /movies, straight from the database cursor,
+// so the memory used does not grow with the size of the catalogue.
+// The format is chosen by the format query parameter (ndjson, csv or json) or else by the Accept header,
+// NDJSON being the default. The CSV columns are the ones read by POST /movies/import.
+func ExportMovies(movies repository.MovieRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseMovieFilter(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+			return
+		}
+
+		contentType, extension := exportFormat(c)
+
+		if contentType == "" {
+			c.JSON(http.StatusNotAcceptable, gin.H{"error": "format must be ndjson, csv or json"})
+			return
+		}
+
+		// A large catalogue takes longer than the usual 100 seconds to send
+		ctx, cancel := context.WithTimeout(c, 30*time.Minute)
+		defer cancel()
+
+		writer := newMovieWriter(c.Writer, contentType)
+
+		started := false
+
+		// The headers are only sent with the first movie, so an early failure can still answer with an error status
+		start := func() error {
+			started = true
+			c.Header("Content-Type", contentType)
+			c.Header("Content-Disposition", `attachment; filename="movies.`+extension+`"`)
+			c.Status(http.StatusOK)
+			return writer.begin()
+		}
+
+		err = movies.Stream(ctx, filter, func(movie models.Movie) error {
+			if !started {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+
+			return writer.write(movie)
+		})
+
+		if err != nil && !started {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export movies."})
+			return
+		}
+
+		if err != nil {
+			// The status is already sent, the client sees a truncated body
+			log.Println("Export interrupted:", err)
+			return
+		}
+
+		// When no movie matches an empty but valid document is still sent
+		if !started {
+			err = start()
+		}
+
+		if err == nil {
+			err = writer.end()
+		}
+
+		if err != nil {
+			log.Println("Export interrupted:", err)
+		}
+	}
+}
+
+// exportFormat returns the content type and the file extension of the requested format,
+// an empty content type when the format is not supported
+func exportFormat(c *gin.Context) (string, string) {
+	contentType := ""
+
+	switch c.Query("format") {
+	case "":
+		// With no Accept header, or */*, the first offer (NDJSON) is chosen
+		contentType = c.NegotiateFormat(contentTypeNDJSON, contentTypeCSV, contentTypeJSON)
+	case "ndjson", "jsonl":
+		contentType = contentTypeNDJSON
+	case "csv":
+		contentType = contentTypeCSV
+	case "json":
+		contentType = contentTypeJSON
+	}
+
+	switch contentType {
+	case contentTypeNDJSON:
+		return contentType, "ndjson"
+	case contentTypeCSV:
+		return contentType, "csv"
+	case contentTypeJSON:
+		return contentType, "json"
+	}
+
+	return "", ""
+}
+
+// movieWriter encodes the exported movies one by one
+type movieWriter struct {
+	response    gin.ResponseWriter
+	contentType string
+	csv         *csv.Writer
+	count       int
+}
+
+func newMovieWriter(response gin.ResponseWriter, contentType string) *movieWriter {
+	return &movieWriter{response: response, contentType: contentType, csv: csv.NewWriter(response)}
+}
+
+// begin writes what comes before the first movie
+func (w *movieWriter) begin() error {
+	switch w.contentType {
+	case contentTypeCSV:
+		return w.csv.Write(importer.CSVHeader)
+	case contentTypeJSON:
+		_, err := w.response.WriteString("[")
+		return err
+	}
+	return nil
+}
+
+func (w *movieWriter) write(movie models.Movie) error {
+	var err error
+
+	switch w.contentType {
+	case contentTypeCSV:
+		err = w.csv.Write(importer.CSVRecord(movie))
+	default:
+		err = w.writeJSON(movie)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	w.count++
+
+	if w.count%exportFlushEvery == 0 {
+		return w.flush()
+	}
+
+	return nil
+}
+
+// writeJSON writes the movie as an NDJSON line or as an element of the JSON array
+func (w *movieWriter) writeJSON(movie models.Movie) error {
+	encoded, err := json.Marshal(movie)
+
+	if err != nil {
+		return err
+	}
+
+	if w.contentType == contentTypeNDJSON {
+		encoded = append(encoded, '\n')
+	} else if w.count > 0 {
+		encoded = append([]byte{','}, encoded...)
+	}
+
+	_, err = w.response.Write(encoded)
+	return err
+}
+
+// end writes what comes after the last movie and sends what is left
+func (w *movieWriter) end() error {
+	if w.contentType == contentTypeJSON {
+		if _, err := w.response.WriteString("]"); err != nil {
+			return err
+		}
+	}
+
+	return w.flush()
+}
+
+func (w *movieWriter) flush() error {
+	if w.contentType == contentTypeCSV {
+		w.csv.Flush()
+
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+
+	w.response.Flush()
+
+	return nil
+}
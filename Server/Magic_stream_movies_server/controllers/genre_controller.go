@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"context"  // Package for context handling, crucial for managing request lifecycles and timeouts
+	"errors"   // Package for comparing the repository errors
+	"fmt"      // Package for formatting the validation errors
+	"net/http" // Standard library package for HTTP status codes
+	"strconv"
+	"strings"
+	"time" // Package for managing time and timeouts
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// GetGenres is the handler function for the GET /genres route.
+// It returns every genre of the genres collection, ordered by genre_id, the only genres movies and users can have.
+func GetGenres(genres repository.GenreRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		result, err := genres.FindAll(ctx)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch genres"})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// CreateGenre is the handler function for the POST /genres route.
+// Body: {"genre_name": "Western"}, optionally with a genre_id, otherwise the next free one is given.
+// Ids and names are unique, a taken one answers 409 Conflict.
+func CreateGenre(genres repository.GenreRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.GenreCreate
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		genre, err := genres.Insert(ctx, models.Genre{Genre_id: req.Genre_id, Genre_name: strings.TrimSpace(req.Genre_name)})
+
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A genre with this id or name already exists"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add the genre"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, genre)
+	}
+}
+
+// RenameGenre is the handler function for the PUT /genres/:genre_id route.
+// Body: {"genre_name": "Science Fiction"}. The new name is also given to the copies of the genre
+// in the movies and in the favourite genres of the users, their numbers are returned.
+func RenameGenre(genres repository.GenreRepository, movies repository.MovieRepository, users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		genreID, ok := genreIDParam(c)
+
+		if !ok {
+			return
+		}
+
+		var req models.GenreRename
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		genre, err := genres.Rename(ctx, genreID, strings.TrimSpace(req.Genre_name))
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Genre not found"})
+			return
+		}
+
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another genre already has this name"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename the genre"})
+			return
+		}
+
+		// Renaming again with the same name finishes a cascade that failed halfway
+		renamed, err := movies.RenameGenre(ctx, genre)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "The genre was renamed but not its copies in the movies, send the request again", "details": err.Error()})
+			return
+		}
+
+		usersUpdated, err := users.RenameGenre(ctx, genre)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "The genre was renamed but not its copies in the users, send the request again", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"genre": genre, "movies_updated": len(renamed), "users_updated": usersUpdated})
+	}
+}
+
+// MergeGenre is the handler function for the POST /genres/:genre_id/merge route.
+// Body: {"into": 3}. The movies and users having the genre get the genre into instead, then the genre is deleted.
+func MergeGenre(genres repository.GenreRepository, movies repository.MovieRepository, users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		genreID, ok := genreIDParam(c)
+
+		if !ok {
+			return
+		}
+
+		var req models.GenreMerge
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		if req.Into == genreID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A genre can not be merged into itself"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		if _, err := genres.FindByID(ctx, genreID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Genre not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the genre"})
+			return
+		}
+
+		into, err := genres.FindByID(ctx, req.Into)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "into is not an existing genre"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the genre"})
+			return
+		}
+
+		// The copies are replaced before the genre is deleted, so a failure can be fixed by sending the request again
+		merged, err := movies.MergeGenre(ctx, genreID, into)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge the genre in the movies", "details": err.Error()})
+			return
+		}
+
+		usersUpdated, err := users.MergeGenre(ctx, genreID, into)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge the genre in the users", "details": err.Error()})
+			return
+		}
+
+		if err := genres.Delete(ctx, genreID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the merged genre"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"genre": into, "merged": genreID, "movies_updated": len(merged), "users_updated": usersUpdated})
+	}
+}
+
+// genreIDParam reads the genre_id path parameter, answering 400 when it is not a number
+func genreIDParam(c *gin.Context) (int, bool) {
+	genreID, err := strconv.Atoi(c.Param("genre_id"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "genre_id must be a number"})
+		return 0, false
+	}
+
+	return genreID, true
+}
+
+// errUnknownGenre is returned by resolveGenres for a genre missing from the genres collection
+var errUnknownGenre = errors.New("unknown genre")
+
+// resolveGenres checks every genre against the genres collection and returns them with their catalogue name.
+// A genre must have the id of an existing genre and its name, the case of the name does not matter.
+func resolveGenres(ctx context.Context, genres repository.GenreRepository, list []models.Genre) ([]models.Genre, error) {
+	resolved := make([]models.Genre, 0, len(list))
+
+	for _, genre := range list {
+		known, err := genres.FindByID(ctx, genre.Genre_id)
+
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && !strings.EqualFold(known.Genre_name, strings.TrimSpace(genre.Genre_name))) {
+			return nil, fmt.Errorf("%w %d %q, see GET /genres", errUnknownGenre, genre.Genre_id, genre.Genre_name)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		resolved = append(resolved, known)
+	}
+
+	return resolved, nil
+}
+
+// checkGenres resolves the genres of a request body, answering 400 for an unknown genre
+func checkGenres(c *gin.Context, ctx context.Context, genres repository.GenreRepository, list []models.Genre) ([]models.Genre, bool) {
+	resolved, err := resolveGenres(ctx, genres, list)
+
+	if errors.Is(err, errUnknownGenre) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return nil, false
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the genres"})
+		return nil, false
+	}
+
+	return resolved, true
+}
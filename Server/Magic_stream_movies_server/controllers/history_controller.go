@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"context" // Package for context handling, crucial for managing request lifecycles and timeouts
+	"errors"  // Package for comparing the repository errors
+	"math"
+	"net/http" // Standard library package for HTTP status codes
+	"strconv"
+	"time" // Package for managing time and timeouts
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/utils"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// Page size of the lists of a user, by default and at most
+const (
+	defaultUserListLimit = 20
+	maxUserListLimit     = 100
+)
+
+// RecordWatch is the handler function for the POST /watchhistory/:imdb_id route.
+// It adds the movie to the watch history of the logged in user, watching it again updates the date.
+// Watched movies are no longer recommended.
+func RecordWatch(interactions repository.InteractionRepository, movies repository.MovieRepository) gin.HandlerFunc {
+	return recordInteraction(interactions, movies, models.InteractionWatch)
+}
+
+// GetWatchHistory is the handler function for the GET /watchhistory route.
+// It returns a page (?page=&limit=) of the movies watched by the logged in user, last watched first.
+func GetWatchHistory(interactions repository.InteractionRepository, movies repository.MovieRepository) gin.HandlerFunc {
+	return listInteractions(interactions, movies, models.InteractionWatch)
+}
+
+// DeleteWatch is the handler function for the DELETE /watchhistory/:imdb_id route
+func DeleteWatch(interactions repository.InteractionRepository) gin.HandlerFunc {
+	return removeInteraction(interactions, models.InteractionWatch, "The movie is not in the watch history")
+}
+
+// DismissMovie is the handler function for the POST /dismissals/:imdb_id route.
+// The logged in user is not interested in the movie, it is never recommended to them again.
+func DismissMovie(interactions repository.InteractionRepository, movies repository.MovieRepository) gin.HandlerFunc {
+	return recordInteraction(interactions, movies, models.InteractionDismiss)
+}
+
+// GetDismissals is the handler function for the GET /dismissals route, paged like GET /watchhistory
+func GetDismissals(interactions repository.InteractionRepository, movies repository.MovieRepository) gin.HandlerFunc {
+	return listInteractions(interactions, movies, models.InteractionDismiss)
+}
+
+// UndoDismissal is the handler function for the DELETE /dismissals/:imdb_id route, the movie can be recommended again
+func UndoDismissal(interactions repository.InteractionRepository) gin.HandlerFunc {
+	return removeInteraction(interactions, models.InteractionDismiss, "The movie is not dismissed")
+}
+
+// recordInteraction stores an interaction of the given kind between the logged in user and an existing movie
+func recordInteraction(interactions repository.InteractionRepository, movies repository.MovieRepository, kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		imdbID := c.Param("imdb_id")
+
+		// Deleted movies are not found either
+		if _, err := movies.FindByImdbID(ctx, imdbID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the movie"})
+			return
+		}
+
+		interaction := models.Interaction{User_id: userID, Imdb_id: imdbID, Kind: kind}
+
+		if err := interactions.Record(ctx, interaction); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record the movie"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"imdb_id": imdbID, "kind": kind})
+	}
+}
+
+// listInteractions returns a page of the interactions of the given kind of the logged in user, with the movie details
+func listInteractions(interactions repository.InteractionRepository, movies repository.MovieRepository, kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		page, limit, ok := pageParams(c)
+
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		list, total, err := interactions.ListByUser(ctx, userID, kind, (page-1)*limit, limit)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the list"})
+			return
+		}
+
+		entries, err := historyEntries(ctx, movies, list)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the movies of the list"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": entries, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// removeInteraction deletes the interaction of the given kind between the logged in user and the movie
+func removeInteraction(interactions repository.InteractionRepository, kind string, notFound string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		err = interactions.Remove(ctx, userID, c.Param("imdb_id"), kind)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove the movie"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"removed": c.Param("imdb_id"), "kind": kind})
+	}
+}
+
+// historyEntries joins the details of the movies to the interactions, in the same order
+func historyEntries(ctx context.Context, movies repository.MovieRepository, list []models.Interaction) ([]models.HistoryEntry, error) {
+	ids := make([]string, len(list))
+
+	for i, interaction := range list {
+		ids[i] = interaction.Imdb_id
+	}
+
+	byID, err := moviesByImdbID(ctx, movies, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.HistoryEntry, len(list))
+
+	for i, interaction := range list {
+		entries[i] = models.HistoryEntry{Imdb_id: interaction.Imdb_id, Updated_at: interaction.Updated_at, Movie: byID[interaction.Imdb_id]}
+	}
+
+	return entries, nil
+}
+
+// moviesByImdbID fetches the movies and returns them by imdb_id, the deleted ones are missing
+func moviesByImdbID(ctx context.Context, movies repository.MovieRepository, imdbIDs []string) (map[string]*models.Movie, error) {
+	found, err := movies.FindByImdbIDs(ctx, imdbIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]*models.Movie{}
+
+	for i := range found {
+		byID[found[i].Imbd_id] = &found[i]
+	}
+
+	return byID, nil
+}
+
+// pageParams reads the page and limit query parameters of the lists of a user, answering 400 when they are invalid
+func pageParams(c *gin.Context) (int64, int64, bool) {
+	return pageParamsWithLimit(c, defaultUserListLimit)
+}
+
+// pageParamsWithLimit is pageParams with another default limit
+func pageParamsWithLimit(c *gin.Context, defaultLimit int64) (int64, int64, bool) {
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive number"})
+		return 0, 0, false
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.FormatInt(defaultLimit, 10)), 10, 64)
+
+	if err != nil || limit < 1 || limit > maxUserListLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxUserListLimit)})
+		return 0, 0, false
+	}
+
+	// The handlers skip (page-1)*limit items, it must not overflow
+	if page > math.MaxInt64/limit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page is too large"})
+		return 0, 0, false
+	}
+
+	return page, limit, true
+}
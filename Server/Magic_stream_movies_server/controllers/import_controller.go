@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"  // Package for context handling, crucial for managing request lifecycles and timeouts
+	"net/http" // Standard library package for HTTP status codes
+	"strconv"
+	"time" // Package for managing time and timeouts
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/importer"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// Largest catalogue accepted by POST /movies/import, bigger dumps go through the import command
+const maxImportSize = 64 << 20
+
+// ImportMovies is the handler function for the POST /movies/import route.
+// The request body is the catalogue, its format is given by the format query parameter
+// (csv, ndjson or tsv) or by the Content-Type header. With dry_run=true nothing is written.
+// Every row is validated and upserted by imdb_id, the response lists the rows that failed.
+func ImportMovies(imp *importer.Importer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format, err := importer.ParseFormat(c.DefaultQuery("format", c.ContentType()))
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 10*time.Minute)
+		defer cancel()
+
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+		result, err := imp.Import(ctx, body, format, dryRun)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Import failed", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -3,44 +3,55 @@ package controllers // Defines the package name as 'controllers', which holds th
 import ( // Start of the import block
 	"context" // Package for context handling, crucial for managing request lifecycles and timeouts
 	"errors"
-	"log"
 	"net/http" // Standard library package for HTTP status codes
-	"os"
 	"strconv"
 	"strings"
 
-	// Custom imports for database connection and data model structure
-	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/database" // Import the database connection setup
-	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"   // Import the Movie structure definition
+	// Custom imports for the configuration, the data access layer and data model structure
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/jobs"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/middleware"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models" // Import the Movie structure definition
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/recommend"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository" // Import the repository interfaces
 	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/utils"
 	"github.com/go-playground/validator/v10"
-	"github.com/tmc/langchaingo/llms/openai"
-
-	// Library to load environment variables from a .env file
-	"github.com/joho/godotenv"
 
 	// Third-party imports
 	"time" // Package for managing time and timeouts
 
-	"github.com/gin-gonic/gin"             // The Gin web framework
-	"go.mongodb.org/mongo-driver/v2/bson"  // MongoDB BSON library for query filters
-	"go.mongodb.org/mongo-driver/v2/mongo" // MongoDB driver core functionality
-	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"github.com/gin-gonic/gin" // The Gin web framework
 )
 
-// movieCollection is a global variable holding the handle to the "movies" collection in MongoDB.
-// It uses the database.OpenCollection function to establish the connection.
-var movieCollection *mongo.Collection = database.OpenCollection("movies")
-var RankingCollection *mongo.Collection = database.OpenCollection("rankings")
-
 // Validator object for data validation
 var validate = validator.New()
 
+// Page size of GET /movies when no limit is given, and the largest accepted limit
+const (
+	defaultMoviesPageLimit = 20
+	maxMoviesPageLimit     = 100
+)
+
 // GetMovies is the handler function for the GET /movies route.
 // It returns a gin.HandlerFunc, which is the signature Gin uses for route handlers.
-func GetMovies() gin.HandlerFunc {
+// The movies repository is injected so the handler can run against Mongo or the in-memory implementation.
+//
+// Query parameters:
+//   - page, limit: page number (from 1) and page size (default 20, at most 100)
+//   - cursor: opaque next_cursor of the previous page, replaces page
+//   - genre, genre_id: only movies having this genre
+//   - min_ranking, max_ranking: ranking_value range, bounds included
+//   - sort: "title" (default) or "ranking", order: "asc" (default) or "desc"
+func GetMovies(movies repository.MovieRepository) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
+		query, err := parseMovieQuery(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+			return
+		}
+
 		// Create a Context with a 100-second timeout for the database query.
 		// This ensures the query doesn't hang indefinitely.
 		ctx, cancel := context.WithTimeout(c, 100*time.Second)
@@ -49,11 +60,13 @@ func GetMovies() gin.HandlerFunc {
 		// This prevents memory leaks if the handler finishes before the timeout.
 		defer cancel()
 
-		var movies []models.Movie // Declare a slice to hold the decoded movie documents
+		// Retrieve the requested page of the movies collection along with the total count
+		page, err := movies.List(ctx, query)
 
-		// Perform the MongoDB Find operation to retrieve ALL documents from the collection.
-		// bson.M{} is an empty filter, meaning "find everything."
-		cursor, err := movieCollection.Find(ctx, bson.M{})
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
 
 		// Check for an error during the Find operation (e.g., connection issue)
 		if err != nil {
@@ -61,24 +74,104 @@ func GetMovies() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movies."})
 			return // Stop execution
 		}
-		// defer cursor.Close(ctx) ensures the MongoDB cursor is properly closed after processing the results.
-		defer cursor.Close(ctx)
 
-		// Decode all documents retrieved by the cursor into the 'movies' slice.
-		if err = cursor.All(ctx, &movies); err != nil {
-			// Respond with a 500 Internal Server Error if decoding fails
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode movies."})
-			return // Stop execution
+		// Respond with a 200 OK status and the page of movies as JSON
+		c.JSON(http.StatusOK, page)
+
+	}
+}
+
+// parseMovieFilter reads the genre and ranking filters from the query string
+func parseMovieFilter(c *gin.Context) (repository.MovieFilter, error) {
+	filter := repository.MovieFilter{Genre_name: c.Query("genre")}
+
+	if raw := c.Query("genre_id"); raw != "" {
+		genreID, err := strconv.Atoi(raw)
+		if err != nil {
+			return filter, errors.New("genre_id must be a number")
 		}
+		filter.Genre_id = genreID
+	}
 
-		// Respond with a 200 OK status and the list of movies as JSON
-		c.JSON(http.StatusOK, movies)
+	var err error
 
+	if filter.Min_ranking, err = optionalIntQuery(c, "min_ranking"); err != nil {
+		return filter, err
 	}
+
+	if filter.Max_ranking, err = optionalIntQuery(c, "max_ranking"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// optionalIntQuery returns nil when the query parameter is absent
+func optionalIntQuery(c *gin.Context, name string) (*int, error) {
+	raw := c.Query(name)
+
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+
+	if err != nil {
+		return nil, errors.New(name + " must be a number")
+	}
+
+	return &value, nil
+}
+
+// parseMovieQuery reads the filters, sort order and pagination of GET /movies
+func parseMovieQuery(c *gin.Context) (repository.MovieQuery, error) {
+	filter, err := parseMovieFilter(c)
+
+	if err != nil {
+		return repository.MovieQuery{}, err
+	}
+
+	query := repository.MovieQuery{
+		MovieFilter: filter,
+		Sort_by:     c.DefaultQuery("sort", repository.SortByTitle),
+		Page:        1,
+		Limit:       defaultMoviesPageLimit,
+		Cursor:      c.Query("cursor"),
+	}
+
+	if query.Sort_by != repository.SortByTitle && query.Sort_by != repository.SortByRanking {
+		return query, errors.New("sort must be title or ranking")
+	}
+
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, errors.New("order must be asc or desc")
+	}
+
+	if raw := c.Query("page"); raw != "" {
+		page, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || page < 1 {
+			return query, errors.New("page must be a positive number")
+		}
+		query.Page = page
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || limit < 1 || limit > maxMoviesPageLimit {
+			return query, errors.New("limit must be between 1 and " + strconv.Itoa(maxMoviesPageLimit))
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
 }
 
 // GetMovie is the handler function for the GET /movie/:imdb_id route.
-func GetMovie() gin.HandlerFunc {
+func GetMovie(movies repository.MovieRepository) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 
@@ -96,12 +189,8 @@ func GetMovie() gin.HandlerFunc {
 			return
 		}
 
-		var movie models.Movie // Declare a variable to hold the single decoded movie document
-
-		// Perform the MongoDB FindOne operation.
-		// The filter is bson.M{"imdb_id": movieID}, which looks for a document where the
-		// "imdb_id" field matches the value pulled from the URL.
-		err := movieCollection.FindOne(ctx, bson.M{"imdb_id": movieID}).Decode(&movie)
+		// Look for the document where the "imdb_id" field matches the value pulled from the URL.
+		movie, err := movies.FindByImdbID(ctx, movieID)
 
 		if err != nil {
 			// Check if the error is a "no documents found" error
@@ -111,12 +200,17 @@ func GetMovie() gin.HandlerFunc {
 			return
 		}
 
+		// The version is also sent as ETag so clients can echo it in If-Match when updating
+		c.Header("ETag", strconv.Quote(strconv.FormatInt(movie.Version, 10)))
+
 		// Respond with a 200 OK status and the single movie object as JSON
 		c.JSON(http.StatusOK, movie)
 	}
 }
 
-func AddMovie() gin.HandlerFunc {
+// AddMovie is the handler function for the POST /addmovie route.
+// The genres of the movie must be in the genres collection (see GET /genres) and its ranking in the rankings collection (see GET /rankings).
+func AddMovie(movies repository.MovieRepository, genres repository.GenreRepository, rankings repository.RankingRepository) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 100*time.Second)
@@ -135,8 +229,32 @@ func AddMovie() gin.HandlerFunc {
 			return
 		}
 
+		// Every genre must come from the genres collection
+		resolved, ok := checkGenres(c, ctx, genres, movie.Genre)
+
+		if !ok {
+			return
+		}
+
+		movie.Genre = resolved
+
+		// The ranking is the one of the rankings collection with the same name
+		ranking, ok := checkRanking(c, ctx, rankings, movie.Ranking)
+
+		if !ok {
+			return
+		}
+
+		movie.Ranking = ranking
+
 		// Insert validated data in the database
-		result, err := movieCollection.InsertOne(ctx, movie)
+		insertedID, err := movies.Insert(ctx, movie)
+
+		// imdb_id is unique, a soft deleted movie must be restored rather than added again
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A movie with this imdb_id already exists"})
+			return
+		}
 
 		// If there's an error send a http internalServerError to the client
 		if err != nil {
@@ -144,161 +262,262 @@ func AddMovie() gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusCreated, result)
+		c.JSON(http.StatusCreated, gin.H{"InsertedID": insertedID})
 	}
 }
 
-func AdminReviewUpdate() gin.HandlerFunc {
+// UpdateMovie is the handler function for the PUT and PATCH /movie/:imdb_id routes.
+// PUT (partial false) replaces the title, poster_path, youtube_id and genre of the movie,
+// PATCH (partial true) only changes the fields present in the body.
+// The update must be based on the current version of the movie, sent in the If-Match header
+// or in the version field, otherwise it is rejected with a 409 Conflict. The genres must be in the genres collection.
+func UpdateMovie(movies repository.MovieRepository, genres repository.GenreRepository, partial bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		movieID := c.Param("imdb_id")
 
-		if movieID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID required or movies does not exists"})
+		var req models.MovieUpdate
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 			return
 		}
 
-		var req struct {
-			AdminReview string `json:"admin_review"`
+		if !partial && (req.Title == nil || req.Poster_path == nil || req.YouTube_id == nil || req.Genre == nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title, poster_path, youtube_id and genre are required"})
+			return
 		}
 
-		var res struct {
-			RankingName string `json:"ranking_name"`
-			AdminReview string `json:"admin_review"`
-		}
+		version, ok := expectedVersion(c, req.Version)
 
-		if err := c.ShouldBind(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		if !ok {
 			return
 		}
 
-		sentiment, rankVal, err := GetReviewRanking(req.AdminReview)
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		movie, err := movies.FindByImdbID(ctx, movieID)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting review ranking", "detail": err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movie"})
 			return
+		}
 
+		if req.Title != nil {
+			movie.Title = *req.Title
 		}
+		if req.Poster_path != nil {
+			movie.Poster_path = *req.Poster_path
+		}
+		if req.YouTube_id != nil {
+			movie.YouTube_id = *req.YouTube_id
+		}
+		if req.Genre != nil {
+			// Every genre must come from the genres collection
+			resolved, ok := checkGenres(c, ctx, genres, *req.Genre)
 
-		filter := bson.M{"imdb_id": movieID}
+			if !ok {
+				return
+			}
 
-		update := bson.M{
-			"$set": bson.M{
-				"admin_review": req.AdminReview,
-				"ranking": bson.M{
-					"ranking_value": rankVal,
-					"ranking_name":  sentiment,
-				},
-			},
+			movie.Genre = resolved
 		}
 
-		ctx, cancel := context.WithTimeout(c, 100*time.Second)
-		defer cancel()
+		// The updated movie must follow the same rules as a new one
+		if err := validate.Struct(movie); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
 
-		result, err := movieCollection.UpdateOne(ctx, filter, update)
+		updated, err := movies.Replace(ctx, movie, version)
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie", "detail": err.Error()})
+		if respondWriteError(c, err) {
 			return
 		}
 
-		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+		c.Header("ETag", strconv.Quote(strconv.FormatInt(updated.Version, 10)))
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// DeleteMovie is the handler function for the DELETE /movie/:imdb_id route.
+// The movie is only soft deleted and can be brought back with RestoreMovie.
+// Like UpdateMovie it needs the current version in the If-Match header or the version query parameter.
+func DeleteMovie(movies repository.MovieRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var queryVersion *int64
+
+		if raw := c.Query("version"); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a number"})
+				return
+			}
+
+			queryVersion = &parsed
+		}
+
+		version, ok := expectedVersion(c, queryVersion)
+
+		if !ok {
 			return
 		}
 
-		res.RankingName = sentiment
-		res.AdminReview = req.AdminReview
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
 
-		c.JSON(http.StatusOK, res)
+		err := movies.SoftDelete(ctx, c.Param("imdb_id"), version)
 
+		if respondWriteError(c, err) {
+			return
+		}
+
+		c.Status(http.StatusNoContent)
 	}
 }
 
-func GetReviewRanking(admin_review string) (string, int, error) {
-	rankings, err := GetRankings()
+// RestoreMovie is the handler function for the POST /movie/:imdb_id/restore route, it undoes DeleteMovie
+func RestoreMovie(movies repository.MovieRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
 
-	if err != nil {
-		return "", 0, err
-	}
-	sentimentDelimited := ""
+		restored, err := movies.Restore(ctx, c.Param("imdb_id"))
 
-	for _, ranking := range rankings {
-		if ranking.Ranking_value != 999 {
-			sentimentDelimited = sentimentDelimited + ranking.Ranking_name + ","
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No deleted movie with this ID"})
+			return
 		}
-	}
-	//list of ranking words sentiment
-	sentimentDelimited = strings.Trim(sentimentDelimited, ",")
 
-	err = godotenv.Load(".env")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore movie"})
+			return
+		}
 
-	if err != nil {
-		log.Println("Warning: .env file not found")
+		c.JSON(http.StatusOK, restored)
 	}
+}
 
-	OpenAiApiKey := os.Getenv("OPENAI_API_KEY")
+// expectedVersion reads the version an update is based on from the If-Match header,
+// falling back to the given body or query value. It answers the request itself when the version is missing or invalid.
+func expectedVersion(c *gin.Context, fallback *int64) (int64, bool) {
+	if header := c.GetHeader("If-Match"); header != "" {
+		version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
 
-	if OpenAiApiKey == "" {
-		return "", 0, errors.New("could not read OPENAI_API_KEY")
-	}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match must hold the movie version"})
+			return 0, false
+		}
 
-	llm, err := openai.New(openai.WithToken(OpenAiApiKey))
+		return version, true
+	}
 
-	if err != nil {
-		return "", 0, err
+	if fallback == nil {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "The movie version is required in the If-Match header or the version field"})
+		return 0, false
 	}
 
-	base_prompt_template := os.Getenv("BASE_PROMPT_TEMPLATE")
+	return *fallback, true
+}
 
-	//Replace the {rankings} placeholder with the list of sentiment names in the rankings collection
-	base_prompt := strings.Replace(base_prompt_template, "{rankings}", sentimentDelimited, 1)
+// respondWriteError answers the request for the errors of a versioned write and reports if it did
+func respondWriteError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+	case errors.Is(err, repository.ErrVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "The movie was modified by another request, fetch it again and retry"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update movie", "details": err.Error()})
+	}
 
-	// Response is the llm call with the base prompt + the admin review argument passed to the function
-	response, err := llm.Call(context.Background(), base_prompt+admin_review)
+	return true
+}
 
-	if err != nil {
-		return "", 0, err
-	}
+// AdminReviewUpdate is the handler function for the PATCH /updatereview/:imdb_id route.
+// The review is saved at once with the ranking status "pending" and ranked in the background by the review queue.
+// It answers 202 Accepted with the job, whose progress is polled on GET /reviewjobs/:job_id.
+func AdminReviewUpdate(queue *jobs.ReviewQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieID := c.Param("imdb_id")
 
-	rank_value := 0
+		if movieID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID required or movies does not exists"})
+			return
+		}
 
-	for _, ranking := range rankings {
-		if ranking.Ranking_name == response {
-			rank_value = ranking.Ranking_value
-			break
+		var req struct {
+			AdminReview string `json:"admin_review"`
 		}
-	}
-	return response, rank_value, nil
-}
 
-// Returns an array of rankings (from the rankings collection) and an error code
-func GetRankings() ([]models.Ranking, error) {
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
 
-	var rankings []models.Ranking
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
 
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+		job, err := queue.Enqueue(ctx, movieID, req.AdminReview)
 
-	// Return all the documents in the rankings collection and put it in the cursor variable
-	cursor, err := RankingCollection.Find(ctx, bson.M{})
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
 
-	// if an error occurs during the find operation returns an empty array and the error code
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie", "detail": err.Error()})
+			return
+		}
 
-	// Add whatever it's in the cursor in the rankings variable
-	if err := cursor.All(ctx, &rankings); err != nil {
-		return nil, err
+		c.Header("Location", "/reviewjobs/"+job.ID.Hex())
+		c.JSON(http.StatusAccepted, gin.H{
+			"job_id":         job.ID.Hex(),
+			"status":         job.Status,
+			"admin_review":   job.Admin_review,
+			"ranking_status": models.RankingStatusPending,
+		})
 	}
+}
 
-	// Return the rankings array with the documents from the rankings collections in the database
-	return rankings, nil
+// GetReviewJob is the handler function for the GET /reviewjobs/:job_id route.
+// It returns the job ranking an admin review: its status, attempts, last error and,
+// once succeeded, the ranking and the classifier that chose it.
+func GetReviewJob(reviewJobs repository.ReviewJobRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
 
+		job, err := reviewJobs.FindByID(ctx, c.Param("job_id"))
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review job not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the review job"})
+			return
+		}
+
+		c.JSON(http.StatusOK, job)
+	}
 }
 
-func GetRecommendedMovies() gin.HandlerFunc {
+// GetRecommendedMovies blends the movies liked by the users with the same tastes with the favourite genres
+// of the user, the admin ranking and the trending movies, a user without enough ratings, watches or watchlist adds
+// gets the best movies of their favourite genres. Every movie comes with its score and the reasons it was picked,
+// ?debug=true (admins only) adds the breakdown of the score. ?page=&limit= page through them, limit defaults to
+// RECOMMENDED_MOVIE_LIMIT.
+func GetRecommendedMovies(cfg *config.Config, recommender *recommend.Recommender, users repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user_id, err := utils.GetUserIdFromContext(c)
 
@@ -307,111 +526,72 @@ func GetRecommendedMovies() gin.HandlerFunc {
 			return
 		}
 
-		favourite_genres, err := GetUsersFavouriteGenres(user_id)
+		debug, err := strconv.ParseBool(c.DefaultQuery("debug", "false"))
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "debug must be true or false"})
 			return
 		}
 
-		err = godotenv.Load(".env")
-
-		if err != nil {
-			log.Println("Warning: .env file not found")
+		// The breakdown shows how the ranking logic is tuned, it is kept for the admins
+		if role, _ := utils.GetRoleFromContext(c); debug && role != middleware.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can use the debug mode"})
 			return
 		}
 
-		// Limit
-		var recommended_movies_limited_value int64 = 5
+		// Limit, RECOMMENDED_MOVIE_LIMIT defaults to 5
+		page, limit, ok := pageParamsWithLimit(c, min(cfg.Recommended_movie_limit, maxUserListLimit))
 
-		recommended_movies_limited_str := os.Getenv("RECOMMENDED_MOVIE_LIMIT")
-
-		if recommended_movies_limited_str != "" {
-			if val, err := strconv.ParseInt(recommended_movies_limited_str, 10, 64); err == nil {
-				recommended_movies_limited_value = val
-			}
+		if !ok {
+			return
 		}
 
-		find_options := options.Find()
-
-		find_options.SetSort(bson.D{{Key: "ranking.ranking_value", Value: 1}})
+		favourite_genres, err := GetUsersFavouriteGenres(user_id, users)
 
-		// Limit the resul to 5 movie recommendation
-		find_options.SetLimit(recommended_movies_limited_value)
-
-		filter := bson.M{"genre.genre_name": bson.M{"$in": favourite_genres}}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		cursor, err := movieCollection.Find(ctx, filter, find_options)
+		// A page of the best recommendations of the user, 5 movies by default
+		recommended_movies, err := recommender.Recommend(ctx, user_id, favourite_genres, (page-1)*limit, limit, debug)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recommended movies"})
 			return
 		}
 
-		defer cursor.Close(ctx)
-
-		var recommended_movies []models.Movie
-
-		if err := cursor.All(ctx, &recommended_movies); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
 		c.JSON(http.StatusOK, recommended_movies)
 
 	}
 
 }
 
-func GetUsersFavouriteGenres(user_id string) ([]string, error) {
+// Returns the genre ids of the favourite genres of the user, the ids are kept consistent by the genres collection
+func GetUsersFavouriteGenres(user_id string, users repository.UserRepository) ([]int, error) {
 
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	filter := bson.M{"user_id": user_id}
-
-	projection := bson.M{
-		"favourite_genres.genre_name": 1,
-		"_id":                         0,
-	}
-
-	options := options.FindOne().SetProjection(projection)
-
-	// result variable to store the results found in the collection
-	var result bson.M
-
-	err := userCollection.FindOne(ctx, filter, options).Decode(&result)
+	user, err := users.FindByID(ctx, user_id)
 
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return []string{}, nil
+		if errors.Is(err, repository.ErrNotFound) {
+			return []int{}, nil
 		}
+		return []int{}, errors.New("unable to retrieve favourite genres for user")
 	}
 
-	favourite_genres_array, ok := result["favourite_genres"].(bson.A)
-
-	if !ok {
-		return []string{}, errors.New("unable to retrieve favourite genres for user")
-	}
-
-	var genre_names []string
+	var genre_ids []int
 
 	// the undersocre means that this for loop will return 2 values but we don't need the first value to be returned
-	for _, item := range favourite_genres_array {
-		if genre_map, ok := item.(bson.D); ok {
-			for _, element := range genre_map {
-				if element.Key == "genre_name" {
-					if name, ok := element.Value.(string); ok {
-						genre_names = append(genre_names, name)
-					}
-				}
-			}
-		}
+	for _, genre := range user.Favourite_genres {
+		genre_ids = append(genre_ids, genre.Genre_id)
 	}
 
-	//Return the array of genre names for the user id
-	return genre_names, nil
+	//Return the array of genre ids for the user id
+	return genre_ids, nil
 }
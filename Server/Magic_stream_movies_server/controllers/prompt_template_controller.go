@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"  // Package for context handling, crucial for managing request lifecycles and timeouts
+	"errors"   // Package for comparing the repository errors
+	"net/http" // Standard library package for HTTP status codes
+	"strconv"
+	"time" // Package for managing time and timeouts
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/classifier"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/utils"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// GetPromptTemplates is the handler function for the GET /prompttemplates route.
+// It returns every version of the prompt template, newest first, the active one has "active": true.
+func GetPromptTemplates(prompts repository.PromptTemplateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		templates, err := prompts.FindAll(ctx)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the prompt templates"})
+			return
+		}
+
+		c.JSON(http.StatusOK, templates)
+	}
+}
+
+// GetPromptTemplate is the handler function for the GET /prompttemplates/:version route
+func GetPromptTemplate(prompts repository.PromptTemplateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version, ok := promptVersionParam(c)
+
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		template, err := prompts.FindByVersion(ctx, version)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template version not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the prompt template"})
+			return
+		}
+
+		c.JSON(http.StatusOK, template)
+	}
+}
+
+// CreatePromptTemplate is the handler function for the POST /prompttemplates route.
+// Body: {"template": "Classify this review as one of {rankings}: {review}", "description": "...", "activate": true}.
+// The template needs the {rankings} placeholder, {review} is optional (the review is appended otherwise)
+// and no other placeholder is accepted. It is stored as the next version, activated at once when activate is set.
+func CreatePromptTemplate(prompts repository.PromptTemplateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.PromptTemplateCreate
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		if err := classifier.ValidateTemplate(req.Template); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt template", "details": err.Error()})
+			return
+		}
+
+		// The admin creating the version is recorded for audits
+		userID, _ := utils.GetUserIdFromContext(c)
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		template, err := prompts.Insert(ctx, models.PromptTemplate{Template: req.Template, Description: req.Description, Created_by: userID})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add the prompt template"})
+			return
+		}
+
+		if req.Activate {
+			if err := prompts.Activate(ctx, template.Version); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "The prompt template was added but not activated", "version": template.Version})
+				return
+			}
+
+			template.Active = true
+		}
+
+		c.Header("Location", "/prompttemplates/"+strconv.Itoa(template.Version))
+		c.JSON(http.StatusCreated, template)
+	}
+}
+
+// ActivatePromptTemplate is the handler function for the POST /prompttemplates/:version/activate route.
+// The reviews ranked from now on use this version, an older version can be activated again to roll back.
+func ActivatePromptTemplate(prompts repository.PromptTemplateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version, ok := promptVersionParam(c)
+
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		err := prompts.Activate(ctx, version)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template version not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate the prompt template"})
+			return
+		}
+
+		template, err := prompts.FindByVersion(ctx, version)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the prompt template"})
+			return
+		}
+
+		c.JSON(http.StatusOK, template)
+	}
+}
+
+// promptVersionParam reads the version path parameter, answering 400 when it is not a positive number
+func promptVersionParam(c *gin.Context) (int, bool) {
+	version, err := strconv.Atoi(c.Param("version"))
+
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a positive number"})
+		return 0, false
+	}
+
+	return version, true
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"  // Package for context handling, crucial for managing request lifecycles and timeouts
+	"errors"   // Package for comparing the repository errors
+	"fmt"      // Package for formatting the validation details
+	"log"      // Package for logging the failures that are not answered to the client
+	"net/http" // Standard library package for HTTP status codes
+	"slices"   // Package for searching the rankings
+	"time"     // Package for managing time and timeouts
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// GetRankings is the handler function for the GET /rankings route.
+// It returns every ranking of the rankings collection, ordered by value.
+func GetRankings(rankings repository.RankingRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		result, err := rankings.FindAll(ctx)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rankings"})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// CreateRanking is the handler function for the POST /rankings route.
+// Body: {"ranking_name": "Good", "ranking_value": 2}, with "not_ranked": true for the ranking of the movies
+// without a ranked review. Names and values are unique and there is a single "not ranked" ranking (409 otherwise).
+func CreateRanking(rankings repository.RankingRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ranking, ok := bindRanking(c)
+
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		err := rankings.Insert(ctx, ranking)
+
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A ranking with this name or value, or another not ranked ranking, already exists"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add the ranking"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, ranking)
+	}
+}
+
+// UpdateRanking is the handler function for the PUT /rankings/:ranking_name route.
+// It gives the new ranking (same body and conflicts as POST /rankings) to the movies ranked with the previous one,
+// their number is returned as movies_updated, then replaces the ranking.
+func UpdateRanking(rankings repository.RankingRepository, movies repository.MovieRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ranking, ok := bindRanking(c)
+
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		name := c.Param("ranking_name")
+
+		previous, err := rankings.FindByName(ctx, name)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ranking not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the ranking"})
+			return
+		}
+
+		all, err := rankings.FindAll(ctx)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the rankings"})
+			return
+		}
+
+		// The conflicts are checked before touching the movies, so they are never moved to a ranking that can not be saved
+		if rankingConflicts(all, name, ranking) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A ranking with this name or value, or another not ranked ranking, already exists"})
+			return
+		}
+
+		// The movies embed their ranking, they are moved before the ranking is replaced:
+		// the previous ranking stays until they all have the new one, so sending the request again finishes a failed remap
+		remapped := []string{}
+
+		if ranking != previous {
+			remapped, err = movies.RemapRanking(ctx, previous.Ranking_name, ranking)
+
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the movies ranked with the ranking, send the request again", "details": err.Error()})
+				return
+			}
+		}
+
+		err = rankings.Update(ctx, name, ranking)
+
+		if err != nil && ranking != previous {
+			// The movies go back to the ranking still in the collection
+			if _, remapErr := movies.RemapRanking(ctx, ranking.Ranking_name, previous); remapErr != nil {
+				log.Printf("Failed to give the ranking %q back to the movies after a failed update: %v", previous.Ranking_name, remapErr)
+			}
+		}
+
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A ranking with this name or value, or another not ranked ranking, already exists"})
+			return
+		}
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ranking not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the ranking"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"ranking": ranking, "movies_updated": len(remapped)})
+	}
+}
+
+// DeleteRanking is the handler function for the DELETE /rankings/:ranking_name route.
+// A ranking still given to movies is only deleted with ?remap_to=<ranking name>, the movies then get that ranking.
+// Without remap_to it answers 409 Conflict with the number of movies using the ranking.
+func DeleteRanking(rankings repository.RankingRepository, movies repository.MovieRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		name := c.Param("ranking_name")
+		remapTo := c.Query("remap_to")
+
+		if _, err := rankings.FindByName(ctx, name); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Ranking not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the ranking"})
+			return
+		}
+
+		remapped := []string{}
+
+		if remapTo == "" {
+			count, err := movies.CountByRanking(ctx, name)
+
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count the movies using the ranking"})
+				return
+			}
+
+			if count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "The ranking is still given to movies, delete it with remap_to=<ranking name>", "movies": count})
+				return
+			}
+		} else {
+			if remapTo == name {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "remap_to must be another ranking"})
+				return
+			}
+
+			target, err := rankings.FindByName(ctx, remapTo)
+
+			if errors.Is(err, repository.ErrNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "remap_to is not an existing ranking"})
+				return
+			}
+
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the ranking"})
+				return
+			}
+
+			// The movies are moved first, so a failure never leaves them with a deleted ranking
+			remapped, err = movies.RemapRanking(ctx, name, target)
+
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remap the movies", "details": err.Error()})
+				return
+			}
+		}
+
+		err := rankings.Delete(ctx, name)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ranking not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the ranking"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"deleted": name, "movies_updated": len(remapped)})
+	}
+}
+
+// rankingConflicts tells if a ranking other than the one named name has the name or the value of the ranking,
+// or is also the "not ranked" ranking
+func rankingConflicts(all []models.Ranking, name string, ranking models.Ranking) bool {
+	return slices.ContainsFunc(all, func(other models.Ranking) bool {
+		return other.Ranking_name != name && (other.Ranking_name == ranking.Ranking_name || other.Ranking_value == ranking.Ranking_value || (other.Not_ranked && ranking.Not_ranked))
+	})
+}
+
+// checkRanking resolves the ranking of a request body by its name against the rankings collection,
+// answering 400 for an unknown ranking
+func checkRanking(c *gin.Context, ctx context.Context, rankings repository.RankingRepository, ranking models.Ranking) (models.Ranking, bool) {
+	known, err := rankings.FindByName(ctx, ranking.Ranking_name)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": fmt.Sprintf("unknown ranking %q, see GET /rankings", ranking.Ranking_name)})
+		return models.Ranking{}, false
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the ranking"})
+		return models.Ranking{}, false
+	}
+
+	return known, true
+}
+
+// bindRanking reads and validates the ranking of the request body, answering 400 when it is invalid
+func bindRanking(c *gin.Context) (models.Ranking, bool) {
+	var ranking models.Ranking
+
+	if err := c.ShouldBindJSON(&ranking); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return ranking, false
+	}
+
+	if err := validate.Struct(ranking); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return ranking, false
+	}
+
+	return ranking, true
+}
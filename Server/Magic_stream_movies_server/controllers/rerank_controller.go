@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context" // Package for context handling, crucial for managing request lifecycles and timeouts
+	"errors"  // Package for comparing the repository errors
+	"math"
+	"net/http" // Standard library package for HTTP status codes
+	"strconv"
+	"time" // Package for managing time and timeouts
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/jobs"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// Number of reviews classified at once by a re-ranking run when the request does not say, and the largest accepted
+const (
+	defaultRerankConcurrency = 4
+	maxRerankConcurrency     = 16
+)
+
+// Page size of GET /rerankruns/:run_id/changes when no limit is given, and the largest accepted limit
+const (
+	defaultRerankChangesLimit = 50
+	maxRerankChangesLimit     = 500
+)
+
+// StartRerank is the handler function for the POST /rerankruns route.
+// It starts re-classifying every admin review against the current rankings and answers 202 Accepted with the run.
+// Body (optional): {"dry_run": true, "concurrency": 4}. A dry run records the changes without applying them.
+func StartRerank(reranker *jobs.Reranker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Dry_run     bool `json:"dry_run"`
+			Concurrency int  `json:"concurrency"`
+		}
+
+		// The body is optional, an empty body starts a real run with the default concurrency
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+				return
+			}
+		}
+
+		if req.Concurrency == 0 {
+			req.Concurrency = defaultRerankConcurrency
+		}
+
+		if req.Concurrency < 1 || req.Concurrency > maxRerankConcurrency {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "concurrency must be between 1 and " + strconv.Itoa(maxRerankConcurrency)})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		run, err := reranker.Start(ctx, req.Dry_run, req.Concurrency)
+
+		if errors.Is(err, jobs.ErrRerankInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start the re-ranking", "details": err.Error()})
+			return
+		}
+
+		c.Header("Location", "/rerankruns/"+run.ID.Hex())
+		c.JSON(http.StatusAccepted, run)
+	}
+}
+
+// GetRerank is the handler function for the GET /rerankruns/:run_id route.
+// It returns the run with its progress: movies processed, changed and failed, and the checkpoint reached.
+func GetRerank(runs repository.RerankRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		run, err := runs.FindByID(ctx, c.Param("run_id"))
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Re-ranking run not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the re-ranking run"})
+			return
+		}
+
+		c.JSON(http.StatusOK, run)
+	}
+}
+
+// GetRerankChanges is the handler function for the GET /rerankruns/:run_id/changes route.
+// It returns a page (page, limit query parameters) of the ranking changes found by the run, the diff of a dry run.
+func GetRerankChanges(runs repository.RerankRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+
+		if err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive number"})
+			return
+		}
+
+		limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultRerankChangesLimit)), 10, 64)
+
+		if err != nil || limit < 1 || limit > maxRerankChangesLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxRerankChangesLimit)})
+			return
+		}
+
+		// The changes skipped, (page-1)*limit, must not overflow
+		if page > math.MaxInt64/limit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page is too large"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		run, err := runs.FindByID(ctx, c.Param("run_id"))
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Re-ranking run not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the re-ranking run"})
+			return
+		}
+
+		changes, total, err := runs.ListChanges(ctx, run.ID, (page-1)*limit, limit)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the changes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"run_id": run.ID.Hex(), "dry_run": run.Dry_run, "changes": changes, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// CancelRerank is the handler function for the POST /rerankruns/:run_id/cancel route.
+// The run stops after the movies being classified, it can be resumed later.
+// An unknown run answers 404 and a run that is not running 409.
+func CancelRerank(reranker *jobs.Reranker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		err := reranker.Cancel(ctx, c.Param("run_id"))
+
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Re-ranking run not found"})
+			return
+		case errors.Is(err, jobs.ErrRerankNotRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel the re-ranking run", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"run_id": c.Param("run_id"), "status": "canceling"})
+	}
+}
+
+// ResumeRerank is the handler function for the POST /rerankruns/:run_id/resume route.
+// A canceled or failed run continues from its last checkpoint.
+func ResumeRerank(reranker *jobs.Reranker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		run, err := reranker.Resume(ctx, c.Param("run_id"))
+
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Re-ranking run not found"})
+		case errors.Is(err, jobs.ErrRerankNotResumable), errors.Is(err, jobs.ErrRerankInProgress):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume the re-ranking run", "details": err.Error()})
+		default:
+			c.JSON(http.StatusAccepted, run)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"  // Package for context handling, crucial for managing request lifecycles and timeouts
+	"net/http" // Standard library package for HTTP status codes
+	"strconv"
+	"strings"
+	"time" // Package for managing time and timeouts
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/search"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// Number of results of GET /search when no limit is given, and the largest accepted limit
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// SearchMovies is the handler function for the GET /search?q= route.
+// It returns the movies whose title or admin review match the query, most relevant first,
+// with the matching words highlighted. The search backend is chosen by SEARCH_BACKEND.
+func SearchMovies(index search.Index) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := strings.TrimSpace(c.Query("q"))
+
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query q is required"})
+			return
+		}
+
+		limit := defaultSearchLimit
+
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+
+			if err != nil || parsed < 1 || parsed > maxSearchLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxSearchLimit)})
+				return
+			}
+
+			limit = parsed
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		results, err := index.Search(ctx, query, limit)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search movies"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"query": query, "results": results})
+	}
+}
@@ -2,17 +2,19 @@ package controllers
 
 import (
 	"context"  // Package for context handling, crucial for managing request lifecycles and timeouts
+	"errors"   // Package for comparing the repository errors
 	"net/http" // Standard library package for HTTP status codes
 	"time"     // Package for managing time and timeouts
 
-	// Custom imports for database connection and data model structure
-	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/database" // Import the database connection setup
-	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"   // Import the Movie structure definition
+	// Custom imports for the configuration, the data access layer and data model structure
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/middleware"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"     // Import the Movie structure definition
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository" // Import the repository interfaces
 	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/utils"
 	"github.com/gin-gonic/gin" // The Gin web framework
 	"github.com/go-playground/validator/v10"
-	"go.mongodb.org/mongo-driver/v2/bson"  // MongoDB BSON library for query filters
-	"go.mongodb.org/mongo-driver/v2/mongo" // MongoDB driver core functionality
+	"go.mongodb.org/mongo-driver/v2/bson" // MongoDB BSON library, used to generate user ids
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -26,11 +28,9 @@ func HashPassword(password string) (string, error) {
 	return string(HashPassword), nil
 }
 
-// userCollection is a global variable holding the handle to the "user" collection in MongoDB.
-// It uses the database.OpenCollection function to establish the connection.
-var userCollection *mongo.Collection = database.OpenCollection("users")
-
-func RegisterUser() gin.HandlerFunc {
+// RegisterUser is the handler function for the POST /register route.
+// The users repository is injected so the handler can run against Mongo or the in-memory implementation.
+func RegisterUser(users repository.UserRepository, genres repository.GenreRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var user models.User
 
@@ -39,6 +39,9 @@ func RegisterUser() gin.HandlerFunc {
 			return
 		}
 
+		// The role is never taken from the request, admins are promoted with PUT /users/:user_id/role
+		user.Role = middleware.RoleUser
+
 		validate := validator.New()
 
 		if err := validate.Struct(user); err != nil {
@@ -61,7 +64,7 @@ func RegisterUser() gin.HandlerFunc {
 		defer cancel() // Release context resources on exit
 
 		// Returns the number of documents where the user has the email address contained in the context object or an err if operation fails
-		count, err := userCollection.CountDocuments(ctx, bson.M{"email": user.Email})
+		count, err := users.CountByEmail(ctx, user.Email)
 
 		// Check for an error during the CountDocuments operation (e.g., Can not check if user already has an account in the users collection)
 		if err != nil {
@@ -74,6 +77,16 @@ func RegisterUser() gin.HandlerFunc {
 			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 			return // Stop execution
 		}
+
+		// The favourite genres must come from the genres collection (see GET /genres)
+		favouriteGenres, ok := checkGenres(c, ctx, genres, user.Favourite_genres)
+
+		if !ok {
+			return // Stop execution
+		}
+
+		user.Favourite_genres = favouriteGenres
+
 		// Create an user id for the new user
 		user.User_ID = bson.NewObjectID().Hex()
 
@@ -84,7 +97,7 @@ func RegisterUser() gin.HandlerFunc {
 		// Assigns hashed password to the user
 		user.Password = hashedPassword
 
-		result, err := userCollection.InsertOne(ctx, user)
+		insertedID, err := users.Insert(ctx, user)
 
 		// Check for an error during the insertion operation (e.g., Can not check if user already has an account in the users collection)
 		if err != nil {
@@ -94,13 +107,13 @@ func RegisterUser() gin.HandlerFunc {
 		}
 
 		// Returns a 201 status created and the result
-		c.JSON(http.StatusCreated, result)
+		c.JSON(http.StatusCreated, gin.H{"InsertedID": insertedID})
 
 	}
 
 }
 
-func LoginUser() gin.HandlerFunc {
+func LoginUser(cfg *config.Config, users repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var userLogin models.UserLogin
 
@@ -112,9 +125,7 @@ func LoginUser() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
 		defer cancel() // Release context resources on exit
 
-		var foundUser models.User
-
-		err := userCollection.FindOne(ctx, bson.M{"email": userLogin.Email}).Decode(&foundUser)
+		foundUser, err := users.FindByEmail(ctx, userLogin.Email)
 
 		// Check for an error during the find user operation (e.g., Check if the user is registered with that email address)
 		if err != nil {
@@ -131,19 +142,93 @@ func LoginUser() gin.HandlerFunc {
 			return // Stop execution
 		}
 
-		token, refreshToken, err := utils.GenerateAllTokens(foundUser.Email, foundUser.First_name, foundUser.Last_name, foundUser.Role, foundUser.User_ID)
+		token, refreshToken, err := utils.GenerateAllTokens(cfg, foundUser.Email, foundUser.First_name, foundUser.Last_name, foundUser.Role, foundUser.User_ID)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+			return
+		}
+
+		err = utils.UpdateAllTokens(foundUser.User_ID, token, refreshToken, users)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh tokens"})
+			return
+
+		}
+
+		c.JSON(http.StatusOK, models.UserResponse{
+			User_ID:          foundUser.User_ID,
+			First_name:       foundUser.First_name,
+			Last_name:        foundUser.Last_name,
+			Email:            foundUser.Email,
+			Role:             foundUser.Role,
+			Token:            token,
+			Refresh_token:    refreshToken,
+			Favourite_genres: foundUser.Favourite_genres,
+		})
+
+	}
+}
+
+// RefreshToken exchanges a valid refresh token for a new access/refresh token pair.
+// The refresh token must match the one stored on the user document; presenting an older one
+// means it was reused (or stolen), so every token of that user is revoked.
+func RefreshToken(cfg *config.Config, users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.RefreshTokenRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			return
+		}
+
+		// Checks the signature against SECRET_REFRESH_KEY and the expiry date
+		claims, err := utils.ValidateRefreshToken(cfg, req.Refresh_token)
+
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+
+		// Set a context with a 100-second timeout for the single database query.
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel() // Release context resources on exit
+
+		foundUser, err := users.FindByID(ctx, claims.User_id)
+
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+
+		// New tokens are generated from the stored user so role or name changes are picked up
+		token, refreshToken, err := utils.GenerateAllTokens(cfg, foundUser.Email, foundUser.First_name, foundUser.Last_name, foundUser.Role, foundUser.User_ID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 			return
 		}
 
-		err = utils.UpdateAllTokens(foundUser.User_ID, token, refreshToken, database.Client)
+		// The tokens are only replaced if the presented refresh token is still the stored one,
+		// so two requests racing with the same token can not both rotate it
+		rotated, err := users.RotateTokens(ctx, foundUser.User_ID, req.Refresh_token, token, refreshToken)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh tokens"})
 			return
+		}
+
+		// A validly signed token that is not the stored one has already been rotated out:
+		// treat it as a replay and log the user out everywhere
+		if !rotated {
+			if err := utils.RevokeAllTokens(foundUser.User_ID, users); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+				return
+			}
 
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, all sessions have been revoked"})
+			return
 		}
 
 		c.JSON(http.StatusOK, models.UserResponse{
@@ -156,6 +241,83 @@ func LoginUser() gin.HandlerFunc {
 			Refresh_token:    refreshToken,
 			Favourite_genres: foundUser.Favourite_genres,
 		})
+	}
+}
+
+// SetUserRole is the handler function for the PUT /users/:user_id/role route.
+// Body: {"role": "ADMIN"} or {"role": "USER"}. The tokens of the user are revoked, the new role applies from their next login.
+// Admins can not change their own role, so the last admin can not lock everyone out.
+func SetUserRole(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.UserRoleUpdate
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		userID := c.Param("user_id")
+
+		if adminID, _ := utils.GetUserIdFromContext(c); adminID == userID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Admins can not change their own role"})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		err := users.SetRole(ctx, userID, req.Role)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change the role"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": req.Role})
+	}
+}
+
+// PromoteAdmin gives the ADMIN role to the user registered with the email, it is how the first admin is created.
+// It returns ErrNotFound when nobody registered with the email, an admin is left as is and keeps their tokens.
+func PromoteAdmin(ctx context.Context, users repository.UserRepository, email string) error {
+	user, err := users.FindByEmail(ctx, email)
+
+	if err != nil {
+		return err
+	}
+
+	if user.Role == middleware.RoleAdmin {
+		return nil
+	}
+
+	return users.SetRole(ctx, user.User_ID, middleware.RoleAdmin)
+}
+
+// LogoutUser revokes the access and refresh tokens of the logged in user
+func LogoutUser(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user_id, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		if err := utils.RevokeAllTokens(user_id, users); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+			return
+		}
 
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
 	}
 }
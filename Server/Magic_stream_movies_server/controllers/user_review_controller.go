@@ -0,0 +1,335 @@
+package controllers
+
+import (
+	"context"  // Package for context handling, crucial for managing request lifecycles and timeouts
+	"errors"   // Package for comparing the repository errors
+	"math"     // Package for rounding the average rating
+	"net/http" // Standard library package for HTTP status codes
+	"strings"
+	"time" // Package for managing time and timeouts
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/middleware"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/utils"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// CreateUserReview is the handler function for the POST /movie/:imdb_id/reviews route.
+// Body: {"rating": 8, "review": "..."}, the rating is from 1 to 10 stars and the text is optional.
+// A user reviews a movie once (409 otherwise), the review is then changed with PUT.
+// The average rating of the movie is updated and the rating is a signal for the recommendations.
+func CreateUserReview(reviews repository.UserReviewRepository, movies repository.MovieRepository, users repository.UserRepository, interactions repository.InteractionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		req, ok := bindUserReview(c)
+
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		imdbID := c.Param("imdb_id")
+
+		// Deleted movies can not be reviewed
+		if !findMovie(c, ctx, movies, imdbID) {
+			return
+		}
+
+		user, err := users.FindByID(ctx, userID)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the user"})
+			return
+		}
+
+		review := models.UserReview{
+			User_id:   userID,
+			User_name: strings.TrimSpace(user.First_name + " " + user.Last_name),
+			Imdb_id:   imdbID,
+			Rating:    req.Rating,
+			Review:    strings.TrimSpace(req.Review),
+		}
+
+		review, err = reviews.Insert(ctx, review)
+
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already reviewed this movie, update your review instead"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add the review"})
+			return
+		}
+
+		stats, ok := afterUserReviewWrite(c, ctx, reviews, movies, interactions, review)
+
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"review": review, "movie_rating": stats})
+	}
+}
+
+// UpdateUserReview is the handler function for the PUT /movie/:imdb_id/reviews route.
+// It replaces the rating and the text of the review of the logged in user, same body as POST.
+func UpdateUserReview(reviews repository.UserReviewRepository, movies repository.MovieRepository, interactions repository.InteractionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		req, ok := bindUserReview(c)
+
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		review, err := reviews.Update(ctx, userID, c.Param("imdb_id"), req.Rating, strings.TrimSpace(req.Review))
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "You have not reviewed this movie"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the review"})
+			return
+		}
+
+		stats, ok := afterUserReviewWrite(c, ctx, reviews, movies, interactions, review)
+
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"review": review, "movie_rating": stats})
+	}
+}
+
+// DeleteUserReview is the handler function for the DELETE /movie/:imdb_id/reviews route.
+// It deletes the review of the logged in user, an admin can delete the review of another user with ?user_id=.
+func DeleteUserReview(reviews repository.UserReviewRepository, movies repository.MovieRepository, interactions repository.InteractionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		if author := c.Query("user_id"); author != "" && author != userID {
+			// Moderation is kept for the admins
+			if role, _ := utils.GetRoleFromContext(c); role != middleware.RoleAdmin {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can delete the review of another user"})
+				return
+			}
+
+			userID = author
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		imdbID := c.Param("imdb_id")
+
+		err = reviews.Delete(ctx, userID, imdbID)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the review"})
+			return
+		}
+
+		stats, err := refreshRatingStats(ctx, reviews, movies, imdbID)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "The review was deleted but the average rating of the movie was not updated"})
+			return
+		}
+
+		// The rating no longer counts for the recommendations
+		if err := interactions.Remove(ctx, userID, imdbID, models.InteractionRating); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "The review was deleted but not its rating signal for the recommendations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"deleted": imdbID, "user_id": userID, "movie_rating": stats})
+	}
+}
+
+// GetMovieReviews is the handler function for the GET /movie/:imdb_id/reviews route.
+// It returns a page (?page=&limit=) of the reviews of the movie, most recently updated first, with its average rating.
+func GetMovieReviews(reviews repository.UserReviewRepository, movies repository.MovieRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit, ok := pageParams(c)
+
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		imdbID := c.Param("imdb_id")
+
+		movie, err := movies.FindByImdbID(ctx, imdbID)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the movie"})
+			return
+		}
+
+		list, total, err := reviews.ListByMovie(ctx, imdbID, (page-1)*limit, limit)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the reviews"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"items":        list,
+			"total":        total,
+			"page":         page,
+			"limit":        limit,
+			"movie_rating": models.RatingStats{Average: movie.Rating_average, Count: movie.Rating_count},
+		})
+	}
+}
+
+// GetUserReviews is the handler function for the GET /users/:user_id/reviews route.
+// It returns a page (?page=&limit=) of the reviews written by the user, most recently updated first.
+func GetUserReviews(reviews repository.UserReviewRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit, ok := pageParams(c)
+
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		list, total, err := reviews.ListByUser(ctx, c.Param("user_id"), (page-1)*limit, limit)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the reviews"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": list, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// bindUserReview reads and validates the body of a review, answering 400 when it is invalid
+func bindUserReview(c *gin.Context) (models.UserReviewInput, bool) {
+	var req models.UserReviewInput
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return req, false
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return req, false
+	}
+
+	return req, true
+}
+
+// findMovie checks that the movie exists and is not deleted, answering 404 otherwise
+func findMovie(c *gin.Context, ctx context.Context, movies repository.MovieRepository, imdbID string) bool {
+	_, err := movies.FindByImdbID(ctx, imdbID)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+		return false
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the movie"})
+		return false
+	}
+
+	return true
+}
+
+// afterUserReviewWrite updates the average rating of the movie and records the rating for the recommendations.
+// On failure the review is kept, updating it again finishes the work.
+func afterUserReviewWrite(c *gin.Context, ctx context.Context, reviews repository.UserReviewRepository, movies repository.MovieRepository, interactions repository.InteractionRepository, review models.UserReview) (models.RatingStats, bool) {
+	stats, err := refreshRatingStats(ctx, reviews, movies, review.Imdb_id)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "The review was saved but the average rating of the movie was not updated, update the review to try again"})
+		return stats, false
+	}
+
+	interaction := models.Interaction{User_id: review.User_id, Imdb_id: review.Imdb_id, Kind: models.InteractionRating, Value: float64(review.Rating)}
+
+	if err := interactions.Record(ctx, interaction); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "The review was saved but not its rating signal for the recommendations, update the review to try again"})
+		return stats, false
+	}
+
+	return stats, true
+}
+
+// refreshRatingStats recomputes the average rating of the movie from its reviews and stores it on the movie.
+// Recomputing rather than incrementing means any later review corrects a failed update. The revision is taken
+// after the review is written and before the stats are read: the stats of the latest revision see every review
+// written before, and the stats of a concurrent review computed at an earlier revision are not stored over them.
+func refreshRatingStats(ctx context.Context, reviews repository.UserReviewRepository, movies repository.MovieRepository, imdbID string) (models.RatingStats, error) {
+	revision, err := movies.NextRatingRevision(ctx, imdbID)
+
+	// The reviews of a movie removed from the database have nothing left to update
+	removed := errors.Is(err, repository.ErrNotFound)
+
+	if err != nil && !removed {
+		return models.RatingStats{}, err
+	}
+
+	stats, err := reviews.RatingStats(ctx, imdbID)
+
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Average = math.Round(stats.Average*100) / 100
+
+	if removed {
+		return stats, nil
+	}
+
+	// ErrNotFound when the stats of a later revision are already stored
+	if err := movies.SetRatingStats(ctx, imdbID, stats, revision); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return stats, err
+	}
+
+	return stats, nil
+}
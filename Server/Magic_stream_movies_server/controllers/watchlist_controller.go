@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"  // Package for context handling, crucial for managing request lifecycles and timeouts
+	"errors"   // Package for comparing the repository errors
+	"net/http" // Standard library package for HTTP status codes
+	"strconv"
+	"time" // Package for managing time and timeouts
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/utils"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// AddToWatchlist is the handler function for the POST /watchlist/:imdb_id route.
+// It appends the movie to the watchlist of the logged in user (201), a movie already in it is left in place (200).
+// The add is a positive signal for the recommendations.
+func AddToWatchlist(watchlists repository.WatchlistRepository, movies repository.MovieRepository, interactions repository.InteractionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		imdbID := c.Param("imdb_id")
+
+		// Deleted and unknown movies are rejected
+		if !findMovie(c, ctx, movies, imdbID) {
+			return
+		}
+
+		added, err := watchlists.Add(ctx, userID, imdbID)
+
+		if errors.Is(err, repository.ErrWatchlistFull) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The watchlist can not hold more than " + strconv.Itoa(repository.MaxWatchlistSize) + " movies"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add the movie to the watchlist"})
+			return
+		}
+
+		// Recorded again for a movie already in the watchlist, so a failure here is fixed by sending the request again
+		interaction := models.Interaction{User_id: userID, Imdb_id: imdbID, Kind: models.InteractionWatchlist}
+
+		if err := interactions.Record(ctx, interaction); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "The movie was added but not its signal for the recommendations, send the request again"})
+			return
+		}
+
+		status := http.StatusOK
+
+		if added {
+			status = http.StatusCreated
+		}
+
+		c.JSON(status, gin.H{"imdb_id": imdbID, "added": added})
+	}
+}
+
+// RemoveFromWatchlist is the handler function for the DELETE /watchlist/:imdb_id route
+func RemoveFromWatchlist(watchlists repository.WatchlistRepository, interactions repository.InteractionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		imdbID := c.Param("imdb_id")
+
+		err = watchlists.Remove(ctx, userID, imdbID)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "The movie is not in the watchlist"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove the movie from the watchlist"})
+			return
+		}
+
+		if err := interactions.Remove(ctx, userID, imdbID, models.InteractionWatchlist); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "The movie was removed but not its signal for the recommendations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"removed": imdbID})
+	}
+}
+
+// GetWatchlist is the handler function for the GET /watchlist route.
+// It returns a page (?page=&limit=) of the watchlist of the logged in user in its order, with the movie details.
+func GetWatchlist(watchlists repository.WatchlistRepository, movies repository.MovieRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		page, limit, ok := pageParams(c)
+
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		skip := (page - 1) * limit
+
+		items, total, err := watchlists.List(ctx, userID, skip, limit)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the watchlist"})
+			return
+		}
+
+		ids := make([]string, len(items))
+
+		for i, item := range items {
+			ids[i] = item.Imdb_id
+		}
+
+		byID, err := moviesByImdbID(ctx, movies, ids)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the movies of the watchlist"})
+			return
+		}
+
+		entries := make([]models.WatchlistEntry, len(items))
+
+		for i, item := range items {
+			entries[i] = models.WatchlistEntry{Position: int(skip) + i + 1, Imdb_id: item.Imdb_id, Added_at: item.Added_at, Movie: byID[item.Imdb_id]}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": entries, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// MoveInWatchlist is the handler function for the PATCH /watchlist/:imdb_id route.
+// Body: {"position": 1}, the movie is moved to the position (from 1), past the end it becomes the last one.
+// A watchlist changed by another request meanwhile answers 409, the move can be sent again.
+func MoveInWatchlist(watchlists repository.WatchlistRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		var req models.WatchlistMove
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		imdbID := c.Param("imdb_id")
+
+		position, err := watchlists.Move(ctx, userID, imdbID, req.Position-1)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "The movie is not in the watchlist"})
+			return
+		}
+
+		if errors.Is(err, repository.ErrVersionConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The watchlist was modified by another request, send the move again"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move the movie"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"imdb_id": imdbID, "position": position + 1})
+	}
+}
@@ -1,46 +1,23 @@
 package database // Defines the package name as 'database'
 
 import ( // Start of the import block for necessary libraries
-    "fmt"   // Package for formatted I/O (like printing database names)
-    "log"   // Package for logging messages (warnings and fatal errors)
-    "os"    // Package for interacting with the operating system (like reading environment variables)
+    "log" // Package for logging messages (warnings and fatal errors)
 
     // MongoDB driver library to connect to and interact with the database
     "go.mongodb.org/mongo-driver/v2/mongo"
     "go.mongodb.org/mongo-driver/v2/mongo/options"
 
-    // Library to load environment variables from a .env file
-    "github.com/joho/godotenv"
+    // Typed configuration loaded once at startup
+    "github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
 )
 
 // DBInstance creates and returns a MongoDB client instance
-// The function connects to the MongoDB server using the URI from environment variables.
-func DBInstance() *mongo.Client {
+// The function connects to the MongoDB server using the URI from the configuration.
+func DBInstance(cfg *config.Config) *mongo.Client {
 
-    // Load environment variables from a .env file in the current directory
-    err := godotenv.Load(".env")
-
-    // Check if loading the .env file resulted in an error
-    if err != nil {
-        log.Println("Warning: unable to find the .env file")
-        // Note: The program continues even if the .env file is missing,
-        // relying on system environment variables if they are set.
-    }
-
-    // Retrieve the MongoDB connection URI from the environment variables
-    MongoDB := os.Getenv("MONGODB_URI")
-
-    // Check if the MONGODB_URI environment variable is empty
-    if MongoDB == "" {
-        // If it's not set, log a fatal error and exit the application
-        log.Fatal("Fatal: MONGODB_URI not set!")
-    }
-
-    // Print the URI (usually for debugging/confirmation)
-    fmt.Println("MONGODB_URI: ", MongoDB)
-
-    // Create a new client options object and apply the retrieved MongoDB URI
-    clientOptions := options.Client().ApplyURI(MongoDB)
+    // Create a new client options object and apply the MongoDB URI
+    // (the URI is validated by config.Load and never printed as it holds the credentials)
+    clientOptions := options.Client().ApplyURI(cfg.MongoDB_URI)
 
     // Attempt to connect to the MongoDB server
     // client is the connected object, or err holds the connection error
@@ -56,41 +33,12 @@ func DBInstance() *mongo.Client {
     return client
 }
 
-// ---------------------------------------------------------------------
-// Create client object based on DB instance
-// This line calls DBInstance() once when the program starts
-// and stores the connection in a global package variable 'Client'
-var Client *mongo.Client = DBInstance()
-
-// ---------------------------------------------------------------------
-
-// OpenCollection opens and returns a specific MongoDB collection
-// It takes the name of the desired collection as a string argument.
-func OpenCollection(collectionName string) *mongo.Collection {
+// OpenDatabase returns the database named by DATABASE_NAME on the given client.
+// Collections are then opened by the repositories.
+func OpenDatabase(client *mongo.Client, cfg *config.Config) *mongo.Database {
 
-    // Reload environment variables (re-loading might be redundant if Client is already set,
-    // but ensures DATABASE_NAME is available if the first load failed or was skipped)
-    err := godotenv.Load(".env")
+    log.Println("DATABASE_NAME: ", cfg.Database_name)
 
-    if err != nil {
-        log.Println("Warning: unable to find the .env file")
-    }
-
-    // Retrieve the target database name from environment variables
-    databaseName := os.Getenv("DATABASE_NAME")
-
-    fmt.Println("DATABASE_NAME: ", databaseName)
-
-    // Use the global 'Client' to access the specified database and then the specified collection
-    collection := Client.Database(databaseName).Collection(collectionName)
-
-    // Check if the collection object was successfully retrieved
-    // (Note: MongoDB collections are created on first use, this checks the retrieval process)
-    if collection == nil {
-        log.Println("Error: Unable to retrieve the collection ")
-        return nil
-    }
-
-    // Return the handle to the MongoDB collection
-    return collection
-}
\ No newline at end of file
+    // Return the handle to the database, MongoDB creates it on first use
+    return client.Database(cfg.Database_name)
+}
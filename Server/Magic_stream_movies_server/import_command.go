@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/database"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/importer"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+)
+
+// runImport implements the "import" subcommand:
+//
+//	go run . import -format csv [-dry-run] movies.csv
+//
+// The catalogue is read from the file, or from the standard input when the path is "-" or missing,
+// and the import result is printed as JSON. The format defaults to the file extension.
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "catalogue format: csv, ndjson or tsv (default: the file extension)")
+	dryRun := flags.Bool("dry-run", false, "validate the rows and report what would change without writing")
+	flags.Parse(args)
+
+	path := flags.Arg(0)
+
+	var input io.Reader = os.Stdin
+
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+
+		input = file
+
+		if *format == "" {
+			*format = strings.TrimPrefix(filepath.Ext(path), ".")
+		}
+	}
+
+	parsedFormat, err := importer.ParseFormat(*format)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := config.Load("")
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client := database.DBInstance(cfg)
+	repos := repository.NewMongoRepositories(database.OpenDatabase(client, cfg))
+
+	ctx := context.Background()
+
+	if err := repos.EnsureIndexes(ctx); err != nil {
+		log.Fatal("Error creating indexes: ", err)
+	}
+
+	// Databases created before the genres collection get the genres of their movies
+	if err := repos.SeedGenres(ctx); err != nil {
+		log.Fatal("Error seeding genres: ", err)
+	}
+
+	// A running server with the memory search backend only sees the imported movies after a restart
+	result, err := importer.New(repos.Movies, repos.Rankings, repos.Genres).Import(ctx, input, parsedFormat, *dryRun)
+
+	if err != nil {
+		log.Fatal("Import failed: ", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(result)
+
+	if result.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,404 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/go-playground/validator/v10"
+)
+
+// Supported catalogue formats
+const (
+	// CSV with a header row: imdb_id,title,poster_path,youtube_id,genre,admin_review,ranking_value,ranking_name
+	FormatCSV = "csv"
+	// One models.Movie JSON document per line
+	FormatNDJSON = "ndjson"
+	// IMDb title.basics dump: tconst, titleType, primaryTitle and genres, optionally followed by poster_path and youtube_id columns.
+	// Only the movies are imported, with placeholders for the missing poster and trailer.
+	FormatTSV = "tsv"
+)
+
+// RowError tells why a row was not imported, rows are numbered from 1 without the header
+type RowError struct {
+	Row     int    `json:"row"`
+	Imdb_id string `json:"imdb_id,omitempty"`
+	Error   string `json:"error"`
+}
+
+// Number of movies upserted by one bulk write
+const importBatchSize = 500
+
+// Largest number of row errors listed in the result, the next ones are only counted
+const maxRowErrors = 100
+
+// Result summarises an import. In a dry run Created and Updated tell what would have been written.
+// Skipped counts the rows of an IMDb dump which are not movies. Errors lists the first maxRowErrors failed rows,
+// Errors_omitted counts the failed rows left out of the list.
+type Result struct {
+	Format         string     `json:"format"`
+	Dry_run        bool       `json:"dry_run"`
+	Rows           int        `json:"rows"`
+	Created        int        `json:"created"`
+	Updated        int        `json:"updated"`
+	Skipped        int        `json:"skipped"`
+	Failed         int        `json:"failed"`
+	Errors         []RowError `json:"errors"`
+	Errors_omitted int        `json:"errors_omitted,omitempty"`
+}
+
+// row is a parsed line of the input, or the error that prevented parsing it
+type row struct {
+	number int
+	movie  models.Movie
+	err    error
+	// Set on the rows left out of the import on purpose
+	skipped bool
+}
+
+// Importer loads catalogues into the movies collection, upserting by imdb_id
+type Importer struct {
+	movies   repository.MovieRepository
+	rankings repository.RankingRepository
+	genres   repository.GenreRepository
+	validate *validator.Validate
+}
+
+func New(movies repository.MovieRepository, rankings repository.RankingRepository, genres repository.GenreRepository) *Importer {
+	return &Importer{movies: movies, rankings: rankings, genres: genres, validate: validator.New()}
+}
+
+// ParseFormat accepts a format name or a content type and returns the matching format
+func ParseFormat(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(strings.Split(value, ";")[0]))
+
+	switch value {
+	case FormatCSV, "text/csv":
+		return FormatCSV, nil
+	case FormatNDJSON, "jsonl", "application/x-ndjson", "application/jsonl":
+		return FormatNDJSON, nil
+	case FormatTSV, "text/tab-separated-values":
+		return FormatTSV, nil
+	}
+
+	return "", fmt.Errorf("unsupported format %q, use csv, ndjson or tsv", value)
+}
+
+// Import streams the rows of the input, checks them with the same validation rules as POST /addmovie
+// and upserts them by batches of importBatchSize unless dryRun is set, so a large dump is never held in memory.
+// A bad row is reported in the result and does not stop the import. An error is only returned when the input
+// can not be read or the import is canceled, the batches written before stay written.
+func (imp *Importer) Import(ctx context.Context, input io.Reader, format string, dryRun bool) (Result, error) {
+	result := Result{Format: format, Dry_run: dryRun, Errors: []RowError{}}
+
+	lookups, err := imp.loadLookups(ctx)
+
+	if err != nil {
+		return result, err
+	}
+
+	run := &importRun{
+		ctx:     ctx,
+		imp:     imp,
+		lookups: lookups,
+		dryRun:  dryRun,
+		result:  &result,
+		queued:  map[string]bool{},
+		seen:    map[string]bool{},
+	}
+
+	switch format {
+	case FormatCSV:
+		err = parseCSV(input, lookups, run.add)
+	case FormatNDJSON:
+		err = parseNDJSON(input, run.add)
+	case FormatTSV:
+		err = parseTSV(input, lookups, run.add)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	// The last batch is written once the whole input is read
+	return result, run.flush()
+}
+
+// importRun holds the state of an import while its rows are streamed
+type importRun struct {
+	ctx     context.Context
+	imp     *Importer
+	lookups lookups
+	dryRun  bool
+	result  *Result
+	// Checked rows waiting for the next bulk write, with their imdb_id
+	pending []row
+	queued  map[string]bool
+	// imdb_id already counted by a dry run, so a repeated row counts as an update
+	seen map[string]bool
+}
+
+// add checks a parsed row and queues it for the next bulk write
+func (run *importRun) add(r row) error {
+	if err := run.ctx.Err(); err != nil {
+		return err
+	}
+
+	run.result.Rows++
+
+	if r.skipped {
+		run.result.Skipped++
+		return nil
+	}
+
+	movie, err := run.imp.check(r, run.lookups)
+
+	if err != nil {
+		run.fail(r, err)
+		return nil
+	}
+
+	// A movie repeated in the input is written after its previous row, never in the same bulk write
+	if run.queued[movie.Imbd_id] {
+		if err := run.flush(); err != nil {
+			return err
+		}
+	}
+
+	r.movie = movie
+	run.pending = append(run.pending, r)
+	run.queued[movie.Imbd_id] = true
+
+	if len(run.pending) < importBatchSize {
+		return nil
+	}
+
+	return run.flush()
+}
+
+// flush upserts the queued rows in one bulk write, or counts what they would change in a dry run
+func (run *importRun) flush() error {
+	pending := run.pending
+	run.pending = nil
+	clear(run.queued)
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	movies := make([]models.Movie, len(pending))
+
+	for i, r := range pending {
+		movies[i] = r.movie
+	}
+
+	var results []repository.UpsertResult
+	var err error
+
+	if run.dryRun {
+		results, err = run.preview(movies)
+	} else {
+		results, err = run.imp.movies.UpsertMany(run.ctx, movies)
+	}
+
+	if err != nil {
+		if ctxErr := run.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		// The rows of a failed write are reported, the next batches are still tried
+		for _, r := range pending {
+			run.fail(r, err)
+		}
+
+		return nil
+	}
+
+	for i, result := range results {
+		switch {
+		case errors.Is(result.Err, repository.ErrDuplicate):
+			run.fail(pending[i], errors.New("the movie is deleted, restore it before importing it again"))
+		case result.Err != nil:
+			run.fail(pending[i], result.Err)
+		case result.Created:
+			run.result.Created++
+		default:
+			run.result.Updated++
+		}
+	}
+
+	return nil
+}
+
+// preview tells which movies a dry run would create: the ones neither in the catalogue nor earlier in the input
+func (run *importRun) preview(movies []models.Movie) ([]repository.UpsertResult, error) {
+	ids := make([]string, len(movies))
+
+	for i, movie := range movies {
+		ids[i] = movie.Imbd_id
+	}
+
+	existing, err := run.imp.movies.FindByImdbIDs(run.ctx, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, movie := range existing {
+		run.seen[movie.Imbd_id] = true
+	}
+
+	results := make([]repository.UpsertResult, len(movies))
+
+	for i, movie := range movies {
+		results[i].Created = !run.seen[movie.Imbd_id]
+		run.seen[movie.Imbd_id] = true
+	}
+
+	return results, nil
+}
+
+// fail counts the row as failed, its error is listed until maxRowErrors errors were listed
+func (run *importRun) fail(r row, err error) {
+	run.result.Failed++
+
+	if len(run.result.Errors) >= maxRowErrors {
+		run.result.Errors_omitted++
+		return
+	}
+
+	run.result.Errors = append(run.result.Errors, RowError{Row: r.number, Imdb_id: r.movie.Imbd_id, Error: err.Error()})
+}
+
+// check resolves the ranking and genres of the row against the collections and validates the movie
+func (imp *Importer) check(r row, lookups lookups) (models.Movie, error) {
+	movie := r.movie
+
+	if r.err != nil {
+		return movie, r.err
+	}
+
+	ranking, err := lookups.ranking(movie.Ranking)
+
+	if err != nil {
+		return movie, err
+	}
+
+	movie.Ranking = ranking
+
+	if movie.Genre, err = lookups.knownGenres(movie.Genre); err != nil {
+		return movie, err
+	}
+
+	return movie, imp.validate.Struct(movie)
+}
+
+// lookups resolve the values missing from the input
+type lookups struct {
+	// genres of the genres collection by lower-cased name, for the formats giving only names
+	genres map[string]models.Genre
+	// genres of the genres collection by id
+	genresByID map[int]models.Genre
+	// rankings of the collection by name
+	rankings map[string]models.Ranking
+	// "not ranked" ranking given to the movies imported without one
+	defaultRanking models.Ranking
+}
+
+func (imp *Importer) loadLookups(ctx context.Context) (lookups, error) {
+	result := lookups{genres: map[string]models.Genre{}, genresByID: map[int]models.Genre{}, rankings: map[string]models.Ranking{}}
+
+	genres, err := imp.genres.FindAll(ctx)
+
+	if err != nil {
+		return result, err
+	}
+
+	for _, genre := range genres {
+		result.genres[strings.ToLower(genre.Genre_name)] = genre
+		result.genresByID[genre.Genre_id] = genre
+	}
+
+	rankings, err := imp.rankings.FindAll(ctx)
+
+	if err != nil {
+		return result, err
+	}
+
+	for _, ranking := range rankings {
+		result.rankings[ranking.Ranking_name] = ranking
+
+		if ranking.Not_ranked {
+			result.defaultRanking = ranking
+		}
+	}
+
+	return result, nil
+}
+
+// genresByName resolves genre names to the genres of the catalogue
+func (l lookups) genresByName(names []string) ([]models.Genre, error) {
+	genres := []models.Genre{}
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+
+		if name == "" {
+			continue
+		}
+
+		genre, ok := l.genres[strings.ToLower(name)]
+
+		if !ok {
+			return nil, fmt.Errorf("unknown genre %q", name)
+		}
+
+		genres = append(genres, genre)
+	}
+
+	return genres, nil
+}
+
+// knownGenres checks the genres of a row against the genres collection, by id and case-insensitive name,
+// and returns them with their catalogue name
+func (l lookups) knownGenres(genres []models.Genre) ([]models.Genre, error) {
+	known := make([]models.Genre, 0, len(genres))
+
+	for _, genre := range genres {
+		catalogue, ok := l.genresByID[genre.Genre_id]
+
+		if !ok || !strings.EqualFold(catalogue.Genre_name, strings.TrimSpace(genre.Genre_name)) {
+			return nil, fmt.Errorf("unknown genre %d %q", genre.Genre_id, genre.Genre_name)
+		}
+
+		known = append(known, catalogue)
+	}
+
+	return known, nil
+}
+
+// ranking resolves the ranking of a row to the ranking of the collection with the same name,
+// a row without ranking gets the "not ranked" ranking
+func (l lookups) ranking(ranking models.Ranking) (models.Ranking, error) {
+	if ranking.Ranking_name == "" && ranking.Ranking_value == 0 {
+		return l.defaultRanking, nil
+	}
+
+	known, ok := l.rankings[ranking.Ranking_name]
+
+	if !ok {
+		return ranking, fmt.Errorf("unknown ranking %q", ranking.Ranking_name)
+	}
+
+	if ranking.Ranking_value != 0 && ranking.Ranking_value != known.Ranking_value {
+		return ranking, fmt.Errorf("ranking %q has the value %d, not %d", known.Ranking_name, known.Ranking_value, ranking.Ranking_value)
+	}
+
+	return known, nil
+}
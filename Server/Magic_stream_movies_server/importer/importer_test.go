@@ -0,0 +1,230 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+)
+
+// titleBasics is an excerpt of the real IMDb title.basics dump, which has no poster nor trailer
+const titleBasics = "tconst\ttitleType\tprimaryTitle\toriginalTitle\tisAdult\tstartYear\tendYear\truntimeMinutes\tgenres\n" +
+	"tt0000001\tshort\tCarmencita\tCarmencita\t0\t1894\t\\N\t1\tDocumentary,Short\n" +
+	"tt0133093\tmovie\tThe Matrix\tThe Matrix\t0\t1999\t\\N\t136\tAction,Sci-Fi\n" +
+	"tt0903747\ttvSeries\tBreaking Bad\tBreaking Bad\t0\t2008\t2013\t45\tCrime,Drama,Thriller\n" +
+	"tt0111161\tmovie\tThe Shawshank Redemption\tThe Shawshank Redemption\t0\t1994\t\\N\t142\tDrama\n"
+
+func newTestImporter(movies ...models.Movie) (*Importer, repository.MovieRepository) {
+	movieRepository := repository.NewMemoryMovieRepository(movies...)
+	rankings := repository.NewMemoryRankingRepository(
+		models.Ranking{Ranking_value: 1, Ranking_name: "Excellent"},
+		models.Ranking{Ranking_value: 999, Ranking_name: "Not_Ranked", Not_ranked: true},
+	)
+	genres := repository.NewMemoryGenreRepository(
+		models.Genre{Genre_id: 1, Genre_name: "Action"},
+		models.Genre{Genre_id: 2, Genre_name: "Sci-Fi"},
+		models.Genre{Genre_id: 3, Genre_name: "Drama"},
+	)
+
+	return New(movieRepository, rankings, genres), movieRepository
+}
+
+func TestImportIMDbDump(t *testing.T) {
+	imp, movies := newTestImporter()
+
+	result, err := imp.Import(context.Background(), strings.NewReader(titleBasics), FormatTSV, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Rows != 4 || result.Created != 2 || result.Skipped != 2 || result.Failed != 0 {
+		t.Fatalf("got %+v, want 2 movies created and the short and the series skipped", result)
+	}
+
+	movie, err := movies.FindByImdbID(context.Background(), "tt0133093")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.Poster_path != models.PlaceholderPosterPath || movie.YouTube_id != models.PlaceholderYouTubeID {
+		t.Fatalf("got the poster %q and the trailer %q, want the placeholders", movie.Poster_path, movie.YouTube_id)
+	}
+
+	if len(movie.Genre) != 2 || movie.Ranking.Ranking_name != "Not_Ranked" {
+		t.Fatalf("got %+v, want two genres and the not ranked ranking", movie)
+	}
+}
+
+func TestImportIMDbDumpKeepsThePoster(t *testing.T) {
+	imp, movies := newTestImporter(models.Movie{
+		Imbd_id:     "tt0133093",
+		Title:       "Matrix",
+		Poster_path: "https://example.com/matrix.jpg",
+		YouTube_id:  "vKQi3bBA1y8",
+		Genre:       []models.Genre{{Genre_id: 1, Genre_name: "Action"}},
+		Ranking:     models.Ranking{Ranking_value: 1, Ranking_name: "Excellent"},
+		Version:     1,
+	})
+
+	result, err := imp.Import(context.Background(), strings.NewReader(titleBasics), FormatTSV, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Created != 1 || result.Updated != 1 {
+		t.Fatalf("got %+v, want 1 movie created and 1 updated", result)
+	}
+
+	movie, err := movies.FindByImdbID(context.Background(), "tt0133093")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.Title != "The Matrix" || movie.Poster_path != "https://example.com/matrix.jpg" || movie.YouTube_id != "vKQi3bBA1y8" {
+		t.Fatalf("got %+v, want the new title with the poster and trailer kept", movie)
+	}
+
+	// The dump has no ranking, the one of the movie is kept
+	if movie.Ranking.Ranking_name != "Excellent" {
+		t.Fatalf("got the ranking %+v, want the Excellent ranking kept", movie.Ranking)
+	}
+}
+
+// ndjsonMovie is an NDJSON row of a valid movie, or of a movie with an unknown genre when invalid is set
+func ndjsonMovie(imdbID string, invalid bool) string {
+	genre := `{"genre_id":3,"genre_name":"Drama"}`
+
+	if invalid {
+		genre = `{"genre_id":42,"genre_name":"Western"}`
+	}
+
+	return `{"imdb_id":"` + imdbID + `","title":"Movie ` + imdbID + `","poster_path":"https://example.com/p.jpg","youtube_id":"yt","genre":[` + genre + `]}` + "\n"
+}
+
+func TestImportBatchesAndCapsTheErrors(t *testing.T) {
+	imp, movies := newTestImporter()
+
+	var input strings.Builder
+
+	// More rows than a batch, every eighth one invalid
+	for i := range 1200 {
+		input.WriteString(ndjsonMovie(fmt.Sprintf("tt%07d", i), i%8 == 0))
+	}
+
+	result, err := imp.Import(context.Background(), strings.NewReader(input.String()), FormatNDJSON, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Rows != 1200 || result.Created != 1050 || result.Failed != 150 {
+		t.Fatalf("got %d rows, %d created and %d failed, want 1200, 1050 and 150", result.Rows, result.Created, result.Failed)
+	}
+
+	if len(result.Errors) != maxRowErrors || result.Errors_omitted != 150-maxRowErrors {
+		t.Fatalf("got %d errors listed and %d omitted, want %d and %d", len(result.Errors), result.Errors_omitted, maxRowErrors, 150-maxRowErrors)
+	}
+
+	if first := result.Errors[0]; first.Row != 1 || first.Imdb_id != "tt0000000" {
+		t.Fatalf("got the first error %+v, want row 1", first)
+	}
+
+	all, err := movies.FindAll(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 1050 {
+		t.Fatalf("got %d movies stored, want 1050", len(all))
+	}
+}
+
+func TestImportRepeatedRows(t *testing.T) {
+	input := ndjsonMovie("tt0000001", false) + ndjsonMovie("tt0000002", false) + ndjsonMovie("tt0000001", false)
+
+	for _, dryRun := range []bool{true, false} {
+		imp, movies := newTestImporter()
+
+		result, err := imp.Import(context.Background(), strings.NewReader(input), FormatNDJSON, dryRun)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Created != 2 || result.Updated != 1 {
+			t.Fatalf("dry run %v: got %+v, want 2 movies created and 1 updated", dryRun, result)
+		}
+
+		all, err := movies.FindAll(context.Background())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := 2
+
+		// A dry run writes nothing
+		if dryRun {
+			want = 0
+		}
+
+		if len(all) != want {
+			t.Fatalf("dry run %v: got %d movies stored, want %d", dryRun, len(all), want)
+		}
+	}
+}
+
+func TestImportAnEditedExport(t *testing.T) {
+	matrix := models.Movie{
+		Imbd_id:     "tt0133093",
+		Title:       "The Matrix",
+		Poster_path: "https://example.com/matrix.jpg",
+		YouTube_id:  "vKQi3bBA1y8",
+		Genre:       []models.Genre{{Genre_id: 1, Genre_name: "Action"}},
+		Ranking:     models.Ranking{Ranking_value: 999, Ranking_name: "Not_Ranked", Not_ranked: true},
+		Version:     1,
+	}
+
+	imp, movies := newTestImporter(matrix)
+
+	// Exports the movie, then ranks and reviews it in the export
+	record := CSVRecord(matrix)
+	record[5] = "A classic"
+	record[6], record[7] = "1", "Excellent"
+
+	var input strings.Builder
+
+	w := csv.NewWriter(&input)
+	w.Write(CSVHeader)
+	w.Write(record)
+	w.Flush()
+
+	result, err := imp.Import(context.Background(), strings.NewReader(input.String()), FormatCSV, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Updated != 1 || result.Failed != 0 {
+		t.Fatalf("got %+v, want 1 movie updated", result)
+	}
+
+	movie, err := movies.FindByImdbID(context.Background(), "tt0133093")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.Admin_review != "A classic" || movie.Ranking.Ranking_name != "Excellent" || movie.Ranking.Not_ranked {
+		t.Fatalf("got the review %q and the ranking %+v, want the edited ones", movie.Admin_review, movie.Ranking)
+	}
+}
@@ -0,0 +1,278 @@
+package importer
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Longest accepted NDJSON line
+const maxLineSize = 1024 * 1024
+
+// IMDb dumps write missing values as \N
+const imdbNull = `\N`
+
+// parseCSV reads a CSV file with a header row naming the columns and calls fn for every row, stopping at its first error.
+// The genre column lists genres separated by "|", either as names ("Drama|Comedy")
+// or as id:name pairs ("18:Drama|35:Comedy"). Names alone must already exist in the catalogue.
+func parseCSV(input io.Reader, lookups lookups, fn func(row) error) error {
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	columns := columnIndexes(header)
+
+	if _, ok := columns["imdb_id"]; !ok {
+		return errors.New("the CSV header must contain an imdb_id column")
+	}
+
+	for number := 1; ; number++ {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			// A malformed line is reported, the next ones are still read
+			if err := fn(row{number: number, err: err}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		r := row{number: number, movie: models.Movie{
+			Imbd_id:      value("imdb_id"),
+			Title:        value("title"),
+			Poster_path:  value("poster_path"),
+			YouTube_id:   value("youtube_id"),
+			Admin_review: value("admin_review"),
+		}}
+
+		r.movie.Genre, r.err = parseGenreList(value("genre"), lookups)
+
+		if r.err == nil {
+			r.movie.Ranking, r.err = parseRanking(value("ranking_value"), value("ranking_name"))
+		}
+
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CSVHeader lists the CSV columns in the order written by CSVRecord
+var CSVHeader = []string{"imdb_id", "title", "poster_path", "youtube_id", "genre", "admin_review", "ranking_value", "ranking_name"}
+
+// CSVRecord writes a movie as a CSV row read back by parseCSV, so a CSV export can be imported again.
+// Genres are written as id:name pairs to keep their ids.
+func CSVRecord(movie models.Movie) []string {
+	genres := make([]string, len(movie.Genre))
+
+	for i, genre := range movie.Genre {
+		genres[i] = strconv.Itoa(genre.Genre_id) + ":" + genre.Genre_name
+	}
+
+	return []string{
+		movie.Imbd_id,
+		movie.Title,
+		movie.Poster_path,
+		movie.YouTube_id,
+		strings.Join(genres, "|"),
+		movie.Admin_review,
+		strconv.Itoa(movie.Ranking.Ranking_value),
+		movie.Ranking.Ranking_name,
+	}
+}
+
+// parseNDJSON reads one JSON movie per line and calls fn for every movie, stopping at its first error.
+// Empty lines are skipped but still counted in the row numbers.
+func parseNDJSON(input io.Reader, fn func(row) error) error {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" {
+			continue
+		}
+
+		r := row{number: number}
+
+		if err := json.Unmarshal([]byte(line), &r.movie); err != nil {
+			r.err = fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		// Identity, version and deletion are managed by the server
+		r.movie.ID = bson.NilObjectID
+		r.movie.Version = 0
+		r.movie.Deleted_at = nil
+
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading NDJSON: %w", err)
+	}
+
+	return nil
+}
+
+// parseTSV reads an IMDb title.basics style dump: tconst becomes imdb_id, primaryTitle the title
+// and the comma separated genres are matched by name against the catalogue. fn is called for every row, stopping at its first error,
+// the rows whose titleType is not movie are marked skipped.
+// IMDb dumps have no poster nor trailer, they are read from the optional poster_path and youtube_id columns
+// and the placeholders of models.PlaceholderPosterPath and models.PlaceholderYouTubeID are used when they are missing.
+func parseTSV(input io.Reader, lookups lookups, fn func(row) error) error {
+	reader := csv.NewReader(input)
+	reader.Comma = '\t'
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return fmt.Errorf("reading TSV header: %w", err)
+	}
+
+	columns := columnIndexes(header)
+
+	for _, required := range []string{"tconst", "primarytitle"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("the TSV header must contain a %s column", required)
+		}
+	}
+
+	for number := 1; ; number++ {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			if err := fn(row{number: number, err: err}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) && record[i] != imdbNull {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		// The dumps also list series, episodes, shorts and video games
+		if titleType := value("titletype"); titleType != "" && titleType != "movie" {
+			if err := fn(row{number: number, skipped: true}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		r := row{number: number, movie: models.Movie{
+			Imbd_id:     value("tconst"),
+			Title:       value("primarytitle"),
+			Poster_path: cmp.Or(value("poster_path"), models.PlaceholderPosterPath),
+			YouTube_id:  cmp.Or(value("youtube_id"), models.PlaceholderYouTubeID),
+		}}
+
+		r.movie.Genre, r.err = lookups.genresByName(strings.Split(value("genres"), ","))
+
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// columnIndexes maps the lower-cased column names of a header to their position
+func columnIndexes(header []string) map[string]int {
+	columns := map[string]int{}
+
+	for i, name := range header {
+		// Spreadsheet exports may start with a byte order mark
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	return columns
+}
+
+// parseGenreList reads "Drama|Comedy" or "18:Drama|35:Comedy"
+func parseGenreList(value string, lookups lookups) ([]models.Genre, error) {
+	if value == "" {
+		return []models.Genre{}, nil
+	}
+
+	var genres []models.Genre
+
+	for _, item := range strings.Split(value, "|") {
+		id, name, hasID := strings.Cut(strings.TrimSpace(item), ":")
+
+		if !hasID {
+			named, err := lookups.genresByName([]string{id})
+
+			if err != nil {
+				return nil, err
+			}
+
+			genres = append(genres, named...)
+			continue
+		}
+
+		genreID, err := strconv.Atoi(strings.TrimSpace(id))
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid genre id %q", id)
+		}
+
+		genres = append(genres, models.Genre{Genre_id: genreID, Genre_name: strings.TrimSpace(name)})
+	}
+
+	return genres, nil
+}
+
+// parseRanking reads the optional ranking columns, both empty means no ranking
+func parseRanking(value, name string) (models.Ranking, error) {
+	if value == "" && name == "" {
+		return models.Ranking{}, nil
+	}
+
+	rankingValue, err := strconv.Atoi(value)
+
+	if err != nil {
+		return models.Ranking{}, fmt.Errorf("invalid ranking_value %q", value)
+	}
+
+	return models.Ranking{Ranking_value: rankingValue, Ranking_name: name}, nil
+}
@@ -0,0 +1,351 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/classifier"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrRerankInProgress is returned when a run is started while another one is running
+var ErrRerankInProgress = errors.New("a re-ranking run is already in progress")
+
+// ErrRerankNotRunning is returned when canceling a run that is not running in this server
+var ErrRerankNotRunning = errors.New("the re-ranking run is not running")
+
+// ErrRerankNotResumable is returned when resuming a run that completed or is still running
+var ErrRerankNotResumable = errors.New("only a canceled or failed run can be resumed")
+
+// Number of movies classified between two checkpoints
+const rerankBatchSize = 50
+
+// errRerankCanceled is the cause of the context of a run canceled by an admin
+var errRerankCanceled = errors.New("canceled by an admin")
+
+// Reranker re-classifies every admin review against the current rankings, after the rankings changed.
+// A single run is active at a time, its progress is saved after every batch of movies:
+// a run interrupted by a restart is resumed at boot from its last checkpoint.
+type Reranker struct {
+	runs       repository.RerankRepository
+	movies     repository.MovieRepository
+	rankings   repository.RankingRepository
+	classifier classifier.SentimentClassifier
+
+	// Context of the server, the runs stop with it
+	ctx context.Context
+
+	// Identifies this server as the owner of the runs it executes
+	owner string
+
+	mu     sync.Mutex
+	active map[string]context.CancelCauseFunc
+}
+
+func NewReranker(ctx context.Context, runs repository.RerankRepository, movies repository.MovieRepository, rankings repository.RankingRepository, sentimentClassifier classifier.SentimentClassifier) *Reranker {
+	return &Reranker{
+		runs:       runs,
+		movies:     movies,
+		rankings:   rankings,
+		classifier: sentimentClassifier,
+		ctx:        ctx,
+		owner:      bson.NewObjectID().Hex(),
+		active:     map[string]context.CancelCauseFunc{},
+	}
+}
+
+// Start creates a run and processes it in the background. A dry run only records the changes.
+func (r *Reranker) Start(ctx context.Context, dryRun bool, concurrency int) (models.RerankRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.active) > 0 {
+		return models.RerankRun{}, ErrRerankInProgress
+	}
+
+	total, err := r.movies.CountReviewed(ctx)
+
+	if err != nil {
+		return models.RerankRun{}, err
+	}
+
+	run, err := r.runs.Insert(ctx, models.RerankRun{
+		Status:      models.RerankRunning,
+		Dry_run:     dryRun,
+		Concurrency: concurrency,
+		Total:       total,
+		Owner:       r.owner,
+	})
+
+	if err != nil {
+		return run, err
+	}
+
+	r.launch(run)
+
+	return run, nil
+}
+
+// Resume continues a canceled or failed run from its checkpoint
+func (r *Reranker) Resume(ctx context.Context, runID string) (models.RerankRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run, err := r.runs.FindByID(ctx, runID)
+
+	if err != nil {
+		return run, err
+	}
+
+	if run.Status != models.RerankCanceled && run.Status != models.RerankFailed {
+		return run, ErrRerankNotResumable
+	}
+
+	if len(r.active) > 0 {
+		return run, ErrRerankInProgress
+	}
+
+	run.Status = models.RerankRunning
+	run.Owner = r.owner
+	run.Last_error = ""
+	run.Ended_at = nil
+
+	if err := r.runs.SaveProgress(ctx, run); err != nil {
+		return run, err
+	}
+
+	r.launch(run)
+
+	return run, nil
+}
+
+// ResumeInterrupted restarts the run left running when the server stopped, it is called once at boot.
+// A single run is active at a time: the most recent one is resumed and the others are marked failed.
+// Every run is claimed first, so servers booting together never resume the same run twice.
+func (r *Reranker) ResumeInterrupted(ctx context.Context) error {
+	runs, err := r.runs.FindByStatus(ctx, models.RerankRunning)
+
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(runs, func(a, b models.RerankRun) int {
+		return b.Created_at.Compare(a.Created_at)
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, run := range runs {
+		claimed, err := r.runs.Claim(ctx, run, r.owner)
+
+		if err != nil {
+			return err
+		}
+
+		// Another server resumed or failed it
+		if !claimed {
+			continue
+		}
+
+		run.Owner = r.owner
+
+		if i == 0 && len(r.active) == 0 {
+			log.Println("Resuming re-ranking run", run.ID.Hex(), "after", run.Checkpoint)
+			r.launch(run)
+			continue
+		}
+
+		log.Println("Failing re-ranking run", run.ID.Hex(), "interrupted by a restart, a more recent run was resumed")
+
+		now := time.Now()
+		run.Status = models.RerankFailed
+		run.Last_error = "interrupted by a restart, a more recent run was resumed"
+		run.Ended_at = &now
+
+		if err := r.saveProgress(ctx, run); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cancel stops a running run once its current batch is done, it can be resumed later.
+// It returns repository.ErrNotFound for an unknown run.
+func (r *Reranker) Cancel(ctx context.Context, runID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cancel, ok := r.active[runID]
+
+	if !ok {
+		if _, err := r.runs.FindByID(ctx, runID); err != nil {
+			return err
+		}
+
+		return ErrRerankNotRunning
+	}
+
+	cancel(errRerankCanceled)
+
+	return nil
+}
+
+// launch runs the run in a goroutine, must be called with the lock held
+func (r *Reranker) launch(run models.RerankRun) {
+	ctx, cancel := context.WithCancelCause(r.ctx)
+	r.active[run.ID.Hex()] = cancel
+
+	go func() {
+		r.execute(ctx, run)
+
+		r.mu.Lock()
+		delete(r.active, run.ID.Hex())
+		r.mu.Unlock()
+
+		cancel(nil)
+	}()
+}
+
+// execute processes the movies after the checkpoint batch by batch
+func (r *Reranker) execute(ctx context.Context, run models.RerankRun) {
+	rankings, err := r.rankings.FindAll(ctx)
+
+	// A canceled run stops between two batches, so the changes and the checkpoint always match
+	for err == nil && ctx.Err() == nil {
+		var batch []models.Movie
+
+		batch, err = r.movies.FindReviewed(ctx, run.Checkpoint, rerankBatchSize)
+
+		if err != nil || len(batch) == 0 {
+			break
+		}
+
+		changes := r.rerankBatch(context.WithoutCancel(ctx), run, batch, rankings)
+
+		for _, change := range changes {
+			if change.Error != "" {
+				run.Failed++
+			} else {
+				run.Changed++
+			}
+		}
+
+		run.Processed += int64(len(batch))
+		run.Checkpoint = batch[len(batch)-1].Imbd_id
+
+		// The changes are saved before the checkpoint moves past them
+		if err = r.runs.SaveChanges(context.WithoutCancel(ctx), changes); err == nil {
+			err = r.saveProgress(context.WithoutCancel(ctx), run)
+		}
+	}
+
+	switch {
+	case r.ctx.Err() != nil:
+		// The server is stopping, the run stays running and resumes at the next boot
+		return
+	case context.Cause(ctx) == errRerankCanceled:
+		run.Status = models.RerankCanceled
+	case err != nil:
+		run.Status = models.RerankFailed
+		run.Last_error = err.Error()
+	default:
+		run.Status = models.RerankCompleted
+	}
+
+	now := time.Now()
+	run.Ended_at = &now
+
+	if err := r.saveProgress(context.WithoutCancel(ctx), run); err != nil {
+		log.Println("Error saving re-ranking run", run.ID.Hex(), err)
+	}
+}
+
+func (r *Reranker) saveProgress(ctx context.Context, run models.RerankRun) error {
+	if run.Total > 0 {
+		run.Progress = min(float64(run.Processed)/float64(run.Total), 1)
+	}
+
+	return r.runs.SaveProgress(ctx, run)
+}
+
+// rerankBatch classifies the movies of the batch with at most run.Concurrency classifications at once
+// and returns the changes, nil entries are movies whose ranking stays the same
+func (r *Reranker) rerankBatch(ctx context.Context, run models.RerankRun, batch []models.Movie, rankings []models.Ranking) []models.RerankChange {
+	results := make([]*models.RerankChange, len(batch))
+	slots := make(chan struct{}, max(run.Concurrency, 1))
+
+	var group sync.WaitGroup
+
+	for i, movie := range batch {
+		slots <- struct{}{}
+
+		group.Go(func() {
+			defer func() { <-slots }()
+			results[i] = r.rerankMovie(ctx, run, movie, rankings)
+		})
+	}
+
+	group.Wait()
+
+	changes := []models.RerankChange{}
+
+	for _, change := range results {
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	return changes
+}
+
+// rerankMovie classifies the review of the movie and, unless it is a dry run, stores a different ranking
+func (r *Reranker) rerankMovie(ctx context.Context, run models.RerankRun, movie models.Movie, rankings []models.Ranking) *models.RerankChange {
+	change := &models.RerankChange{Run_id: run.ID, Imdb_id: movie.Imbd_id, Title: movie.Title, Previous: movie.Ranking}
+
+	if movie.Ranking_audit != nil {
+		change.Previous_prompt_version = movie.Ranking_audit.Prompt_version
+	}
+
+	classifyCtx, cancel := context.WithTimeout(ctx, classifyTimeout)
+	defer cancel()
+
+	result, err := r.classifier.Classify(classifyCtx, movie.Admin_review, rankings)
+
+	if err != nil {
+		change.Error = err.Error()
+		return change
+	}
+
+	if result.Ranking == movie.Ranking && movie.Ranking_status != models.RankingStatusFailed {
+		return nil
+	}
+
+	change.Ranking = &result.Ranking
+	change.Classifier = result.Classifier
+	change.Prompt_version = result.Prompt_version
+
+	if run.Dry_run {
+		return change
+	}
+
+	err = r.movies.UpdateReview(ctx, movie.Imbd_id, movie.Admin_review, result.Ranking, result.Audit())
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		change.Error = "the movie was deleted or its review changed during the run"
+	case err != nil:
+		change.Error = err.Error()
+	default:
+		change.Applied = true
+	}
+
+	return change
+}
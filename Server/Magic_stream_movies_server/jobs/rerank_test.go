@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/classifier"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+)
+
+func newTestReranker(ctx context.Context, runs repository.RerankRepository) *Reranker {
+	return NewReranker(ctx, runs, repository.NewMemoryMovieRepository(), repository.NewMemoryRankingRepository(), classifier.NewFakeClassifier())
+}
+
+func TestResumeInterruptedResumesASingleRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := repository.NewMemoryRerankRepository()
+	var inserted []models.RerankRun
+
+	// Three runs left running by a stopped server, the last one is the most recent
+	for range 3 {
+		run, err := runs.Insert(ctx, models.RerankRun{Status: models.RerankRunning, Owner: "stopped server"})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		inserted = append(inserted, run)
+		time.Sleep(time.Millisecond)
+	}
+
+	// Another server booting at the same time read the runs before they were claimed
+	stale, err := runs.FindByStatus(ctx, models.RerankRunning)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestReranker(ctx, runs).ResumeInterrupted(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, run := range stale {
+		if claimed, err := runs.Claim(ctx, run, "other server"); err != nil || claimed {
+			t.Fatalf("the other server claimed the run %s: %v", run.ID.Hex(), err)
+		}
+	}
+
+	latest := inserted[len(inserted)-1]
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		resumed, err := runs.FindByID(ctx, latest.ID.Hex())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resumed.Status == models.RerankCompleted {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("the most recent run is %s, want it resumed until completed", resumed.Status)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, run := range inserted[:len(inserted)-1] {
+		failed, err := runs.FindByID(ctx, run.ID.Hex())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if failed.Status != models.RerankFailed || failed.Ended_at == nil {
+			t.Errorf("the older run %s is %s, want it failed", run.ID.Hex(), failed.Status)
+		}
+	}
+}
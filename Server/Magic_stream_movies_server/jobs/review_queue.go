@@ -0,0 +1,204 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/classifier"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+)
+
+// How often an idle worker looks for jobs, enqueued jobs and due retries wake a worker at once
+const pollInterval = 5 * time.Second
+
+// Longest a classification may take, a job still running after its lease is given to another worker
+const (
+	classifyTimeout = 100 * time.Second
+	jobLease        = 2 * classifyTimeout
+)
+
+// Longest delay between two attempts
+const maxRetryDelay = 10 * time.Minute
+
+// ReviewQueue ranks the admin reviews in the background.
+// The jobs are stored in the review_jobs collection, so the pending ones survive a restart
+// and are picked up again as soon as the workers start.
+type ReviewQueue struct {
+	jobs       repository.ReviewJobRepository
+	movies     repository.MovieRepository
+	rankings   repository.RankingRepository
+	classifier classifier.SentimentClassifier
+
+	workers     int
+	maxAttempts int
+	retryDelay  time.Duration
+
+	// Signals the idle workers that a job was enqueued
+	wake chan struct{}
+}
+
+func NewReviewQueue(cfg *config.Config, jobs repository.ReviewJobRepository, movies repository.MovieRepository, rankings repository.RankingRepository, sentimentClassifier classifier.SentimentClassifier) *ReviewQueue {
+	return &ReviewQueue{
+		jobs:        jobs,
+		movies:      movies,
+		rankings:    rankings,
+		classifier:  sentimentClassifier,
+		workers:     cfg.Review_workers,
+		maxAttempts: cfg.Review_max_attempts,
+		retryDelay:  time.Duration(cfg.Review_retry_delay),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Enqueue stores the review with the ranking status pending and creates the job ranking it.
+// The review is stored first so a worker never ranks it before it is on the movie; when the job can not be
+// created the ranking status becomes failed instead of staying pending. repository.ErrNotFound if the movie does not exist.
+func (q *ReviewQueue) Enqueue(ctx context.Context, imdbID string, review string) (models.ReviewJob, error) {
+	if err := q.movies.SetPendingReview(ctx, imdbID, review); err != nil {
+		return models.ReviewJob{}, err
+	}
+
+	job, err := q.jobs.Insert(ctx, models.ReviewJob{Imdb_id: imdbID, Admin_review: review})
+
+	if err != nil {
+		// Also when the request was cancelled, the movie must not keep a review no job will rank
+		if failErr := q.movies.FailReview(context.WithoutCancel(ctx), imdbID, review); failErr != nil && !errors.Is(failErr, repository.ErrNotFound) {
+			log.Println("Error marking the review of", imdbID, "as failed:", failErr)
+		}
+
+		return job, err
+	}
+
+	q.signal()
+
+	return job, nil
+}
+
+// signal wakes an idle worker, without waiting if they are all busy
+func (q *ReviewQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the workers until the context is cancelled, the returned function waits for them to stop
+func (q *ReviewQueue) Start(ctx context.Context) (wait func()) {
+	var group sync.WaitGroup
+
+	for range q.workers {
+		group.Go(func() { q.work(ctx) })
+	}
+
+	return group.Wait
+}
+
+// work claims and runs the jobs one at a time
+func (q *ReviewQueue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := q.jobs.Claim(ctx, time.Now(), jobLease)
+
+		if err == nil {
+			q.run(ctx, job)
+			continue
+		}
+
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Println("Error claiming a review job:", err)
+		}
+
+		// Nothing to do, sleep until a job is enqueued or a retry may be due
+		select {
+		case <-ctx.Done():
+		case <-q.wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// run classifies the review of the job and stores the ranking on the movie
+func (q *ReviewQueue) run(ctx context.Context, job models.ReviewJob) {
+	result, err := q.classify(ctx, job.Admin_review)
+
+	if err != nil {
+		q.failed(ctx, job, err)
+		return
+	}
+
+	err = q.movies.UpdateReview(ctx, job.Imdb_id, job.Admin_review, result.Ranking, result.Audit())
+
+	if errors.Is(err, repository.ErrNotFound) {
+		// The movie was deleted or reviewed again meanwhile, the newer review has its own job
+		q.finish(ctx, job, models.ReviewJobSuperseded, "the movie was deleted or has a newer review")
+		return
+	}
+
+	if err != nil {
+		q.failed(ctx, job, err)
+		return
+	}
+
+	if err := q.jobs.Succeed(ctx, job.ID, result.Ranking, result.Classifier); err != nil {
+		log.Println("Error completing review job", job.ID.Hex(), err)
+	}
+}
+
+func (q *ReviewQueue) classify(ctx context.Context, review string) (classifier.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, classifyTimeout)
+	defer cancel()
+
+	rankings, err := q.rankings.FindAll(ctx)
+
+	if err != nil {
+		return classifier.Result{}, err
+	}
+
+	return q.classifier.Classify(ctx, review, rankings)
+}
+
+// failed schedules another attempt with an exponential backoff, or gives up after the last attempt
+func (q *ReviewQueue) failed(ctx context.Context, job models.ReviewJob, cause error) {
+	if job.Attempts >= q.maxAttempts {
+		log.Println("Review job", job.ID.Hex(), "failed after", job.Attempts, "attempts:", cause)
+
+		if err := q.movies.FailReview(ctx, job.Imdb_id, job.Admin_review); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			log.Println("Error marking the review of", job.Imdb_id, "as failed:", err)
+		}
+
+		q.finish(ctx, job, models.ReviewJobFailed, cause.Error())
+		return
+	}
+
+	delay := q.backoff(job.Attempts)
+
+	if err := q.jobs.Retry(ctx, job.ID, time.Now().Add(delay), cause.Error()); err != nil {
+		log.Println("Error rescheduling review job", job.ID.Hex(), err)
+		return
+	}
+
+	// Wake a worker when the retry is due instead of waiting for the next poll
+	time.AfterFunc(delay, q.signal)
+}
+
+func (q *ReviewQueue) finish(ctx context.Context, job models.ReviewJob, status string, reason string) {
+	if err := q.jobs.Finish(ctx, job.ID, status, reason); err != nil {
+		log.Println("Error finishing review job", job.ID.Hex(), err)
+	}
+}
+
+// backoff doubles the retry delay after every attempt, with up to 20% of jitter so the retries spread out
+func (q *ReviewQueue) backoff(attempts int) time.Duration {
+	delay := q.retryDelay << min(attempts-1, 20)
+
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/classifier"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+)
+
+// unavailableJobs fails to store a job
+type unavailableJobs struct {
+	repository.ReviewJobRepository
+}
+
+func (unavailableJobs) Insert(ctx context.Context, job models.ReviewJob) (models.ReviewJob, error) {
+	return job, errors.New("review_jobs unavailable")
+}
+
+func TestEnqueueFailsTheReviewWithoutAJob(t *testing.T) {
+	ctx := context.Background()
+	movies := repository.NewMemoryMovieRepository(models.Movie{Imbd_id: "tt0000001", Title: "The First Movie"})
+	cfg := &config.Config{Review_workers: 1, Review_max_attempts: 1}
+	queue := NewReviewQueue(cfg, unavailableJobs{repository.NewMemoryReviewJobRepository()}, movies, repository.NewMemoryRankingRepository(), classifier.NewFakeClassifier())
+
+	if _, err := queue.Enqueue(ctx, "tt0000001", "A fine movie"); err == nil {
+		t.Fatal("got no error, want the error storing the job")
+	}
+
+	movie, err := movies.FindByImdbID(ctx, "tt0000001")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.Ranking_status != models.RankingStatusFailed {
+		t.Fatalf("got the ranking status %q, want %q since no job will rank the review", movie.Ranking_status, models.RankingStatusFailed)
+	}
+}
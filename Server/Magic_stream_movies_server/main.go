@@ -1,35 +1,188 @@
 package main // Defines the package as 'main', indicating an executable program
 
 import ( // Start of the import block for external libraries
+	"context"
+	"errors"
 	"fmt" // Package for formatted I/O (like printing errors)
+	"log" // Package for logging the configuration and fatal errors
+	"os"
+	"time"
 
-	"github.com/gin-gonic/gin" // The Gin web framework, used for building the server and handling HTTP requests
-
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/cache"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/classifier"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/controllers"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/database"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/jobs"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/recommend"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
 	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/routes"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/search"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 func main() {
 	//This is the main function - the entry point of the application
 
-	// Initialize the Gin router with default middleware (Logger and Recovery)
-	router := gin.Default()
+	// "import" loads a catalogue file instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		runImport(os.Args[2:])
+		return
+	}
+
+	// Load the configuration once from the environment, the .env file and the optional CONFIG_FILE
+	// The server refuses to start if a required setting is missing or invalid
+	cfg, err := config.Load("")
 
-	// Define a GET route for the path "/hello"
-	// When a request hits this endpoint, the anonymous function (handler) is executed
-	router.GET("/hello", func(c *gin.Context) {
-		// c.String(200, "Hello, Magic_stream_movies!") sends a simple text response
-		// 200 is the HTTP Status Code for "OK"
-		c.String(200, "Hello, Magic_stream_movies!")
-	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Secrets are redacted when the configuration is printed
+	log.Printf("Configuration:\n%s", cfg)
+
+	// Connect to MongoDB and open the application database
+	client := database.DBInstance(cfg)
+	db := database.OpenDatabase(client, cfg)
+
+	// The handlers access the collections through the repositories
+	repos := repository.NewMongoRepositories(db)
+
+	// Create the indexes used to filter, sort and paginate before serving requests
+	if err := repos.EnsureIndexes(context.Background()); err != nil {
+		log.Fatal("Error creating indexes: ", err)
+	}
+
+	// Databases created before the genres collection get the genres of their movies
+	if err := repos.SeedGenres(context.Background()); err != nil {
+		log.Fatal("Error seeding genres: ", err)
+	}
+
+	// BASE_PROMPT_TEMPLATE becomes the first version of the prompt template, later versions are managed by the API
+	if err := repos.SeedPromptTemplate(context.Background(), cfg.Base_prompt_template); err != nil {
+		log.Fatal("Error seeding the prompt template: ", err)
+	}
+
+	// ADMIN_EMAIL is promoted at every start, the first admin registers and the server is restarted
+	if cfg.Admin_email != "" {
+		err := controllers.PromoteAdmin(context.Background(), repos.Users, cfg.Admin_email)
+
+		if errors.Is(err, repository.ErrNotFound) {
+			log.Printf("No user registered with ADMIN_EMAIL %s yet, restart the server once they register", cfg.Admin_email)
+		} else if err != nil {
+			log.Fatal("Error promoting ADMIN_EMAIL: ", err)
+		}
+	}
+
+	// Hits and misses of the caches, read with GET /cachestats
+	cacheStats := &cache.Stats{}
+
+	// Every ranked review reads the rankings, they are kept in the process and refreshed when they change
+	if cfg.Rankings_cache_ttl > 0 {
+		repos.Rankings = cache.NewRankingRepository(repos.Rankings, time.Duration(cfg.Rankings_cache_ttl), &cacheStats.Rankings)
+	}
+
+	// Set up the search backend chosen by SEARCH_BACKEND
+	searchIndex, err := setUpSearch(cfg, db, repos)
+
+	if err != nil {
+		log.Fatal("Error setting up search: ", err)
+	}
+
+	// Set up the review classifier chosen by LLM_PROVIDER, the local one learns from the reviews already ranked
+	sentimentClassifier, err := setUpClassifier(cfg, repos, cacheStats)
+
+	if err != nil {
+		log.Fatal("Error setting up the review classifier: ", err)
+	}
+
+	// Rank the admin reviews in the background, the jobs left pending by a previous run are resumed
+	reviewQueue := jobs.NewReviewQueue(cfg, repos.ReviewJobs, repos.Movies, repos.Rankings, sentimentClassifier)
+	reviewQueue.Start(context.Background())
+
+	// Re-ranking runs interrupted by the last stop continue from their checkpoint
+	reranker := jobs.NewReranker(context.Background(), repos.Reranks, repos.Movies, repos.Rankings, sentimentClassifier)
 
-	routes.SetUpUnprotectedRoutes(router)
-	routes.SetUpProtectedRoutes(router)
+	if err := reranker.ResumeInterrupted(context.Background()); err != nil {
+		log.Fatal("Error resuming the re-ranking runs: ", err)
+	}
+
+	// Learn the similarities between movies from the interactions of the users, then keep them up to date
+	recommender := recommend.New(cfg, repos.Interactions, repos.Movies, repos.Rankings)
+
+	// Without the similarities every user is a cold start until the next refresh succeeds
+	if err := recommender.Refresh(context.Background()); err != nil {
+		log.Println("Error computing the recommendations, starting in cold start mode:", err)
+	}
+
+	recommender.Start(context.Background())
+
+	// Initialize the Gin router with all the public and protected routes
+	router := routes.SetUpRouter(&routes.Dependencies{
+		Config:      cfg,
+		Repos:       repos,
+		Search:      searchIndex,
+		ReviewQueue: reviewQueue,
+		Reranker:    reranker,
+		CacheStats:  cacheStats,
+		Recommender: recommender,
+	})
 
-	// Start the server and listen for incoming requests on port 8080
+	// Start the server and listen for incoming requests on the configured port (8080 by default)
 	// router.Run() is a blocking call, meaning the program stays here until the server stops
-	if err := router.Run(":8080"); err != nil {
+	if err := router.Run(":" + cfg.Port); err != nil {
 		// If the server fails to start (e.g., port is already in use), an error message is printed
 		fmt.Println("Filed to start server", err)
 	}
 
 }
+
+// setUpSearch returns the search index of the configured backend.
+// The memory index is filled with the whole catalogue and kept up to date by wrapping the movies repository.
+func setUpSearch(cfg *config.Config, db *mongo.Database, repos *repository.Repositories) (search.Index, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if cfg.Search_backend == search.BackendMongo {
+		index := search.NewMongoIndex(db.Collection("movies"))
+		return index, index.EnsureIndexes(ctx)
+	}
+
+	index := search.NewMemoryIndex()
+
+	movies, err := repos.Movies.FindAll(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := search.Rebuild(ctx, index, movies); err != nil {
+		return nil, err
+	}
+
+	repos.Movies = search.NewIndexedMovieRepository(repos.Movies, index)
+
+	return index, nil
+}
+
+// setUpClassifier trains the local classifier with the admin reviews of the catalogue,
+// the LLM ranks the reviews with the active prompt template version and its answers are reused from the classification cache
+func setUpClassifier(cfg *config.Config, repos *repository.Repositories, stats *cache.Stats) (classifier.SentimentClassifier, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	movies, err := repos.Movies.FindAll(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sentimentClassifier, err := classifier.New(cfg, movies, repos.Prompts)
+
+	// Only the LLM providers are worth caching, the local and fake classifiers answer at once
+	if err != nil || cfg.Classification_cache_ttl == 0 || classifier.ModelName(cfg) == "" {
+		return sentimentClassifier, err
+	}
+
+	return cache.NewClassifier(sentimentClassifier, repos.Classifications, repos.Prompts, cfg.LLM_provider+"/"+classifier.ModelName(cfg), time.Duration(cfg.Classification_cache_ttl), &stats.Classifications), nil
+}
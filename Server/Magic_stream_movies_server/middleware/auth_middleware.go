@@ -3,11 +3,13 @@ package middleware
 import (
 	"net/http"
 
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
 	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/utils"
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(cfg *config.Config, users repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token, err := utils.GetAccessToken(c)
 		if err != nil {
@@ -22,11 +24,28 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := utils.ValidateToken(token)
+		claims, err := utils.ValidateToken(cfg, token)
 
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
+			return
+		}
+
+		// A signed and unexpired token is only accepted while it is still the one stored for the user,
+		// so logging out or revoking the user's sessions takes effect immediately
+		active, err := utils.IsTokenActive(claims.User_id, token, users)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+			c.Abort()
+			return
+		}
+
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
 		}
 
 		c.Set("user_id", claims.User_id)
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Longest accepted Idempotency-Key
+const maxIdempotencyKeyLength = 255
+
+// How long a key stays in progress, longer than the 100 second timeout of the handlers.
+// A key still in progress after its lease was left by a server that stopped, a retry takes it over.
+const idempotencyLease = 5 * time.Minute
+
+// responseRecorder keeps a copy of the response body while it is written to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Idempotency makes a route safe to retry: when a request carries an Idempotency-Key header,
+// the response is stored and any later request of the same user with the same key gets it back
+// unchanged instead of running the handler again. Reusing a key for a different request is rejected.
+// Server errors and panics are not stored so the client can retry them. It must be registered after AuthMiddleware.
+func Idempotency(store repository.IdempotencyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		user_id, err := utils.GetUserIdFromContext(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			c.Abort()
+			return
+		}
+
+		// The handler reads the body again
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.Sum256(append([]byte(c.Request.Method+" "+c.FullPath()+"\n"), body...))
+		requestHash := hex.EncodeToString(hash[:])
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		existing, err := store.Reserve(ctx, models.IdempotencyRecord{Key: key, User_id: user_id, Request_hash: requestHash, Lease_expires_at: time.Now().Add(idempotencyLease)})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+			c.Abort()
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.Request_hash != requestHash:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case existing.Status != models.IdempotencyCompleted:
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+			default:
+				// Replay the original response
+				c.Header("Idempotent-Replayed", "true")
+
+				if existing.Response_location != "" {
+					c.Header("Location", existing.Response_location)
+				}
+
+				c.Data(existing.Response_status, "application/json; charset=utf-8", existing.Response_body)
+			}
+
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// Deferred so a panicking handler does not leave the key reserved, the panic goes on once the key is released
+		defer func() {
+			recovered := recover()
+
+			// The request context may be over by now, the record is saved on a fresh one
+			saveCtx, saveCancel := context.WithTimeout(context.Background(), 100*time.Second)
+			defer saveCancel()
+
+			if recovered == nil && recorder.Status() < http.StatusInternalServerError {
+				if err := store.Complete(saveCtx, user_id, key, recorder.Status(), recorder.Header().Get("Location"), recorder.body.Bytes()); err != nil {
+					log.Printf("Failed to save the response of Idempotency-Key %q: %v", key, err)
+				}
+				return
+			}
+
+			if err := store.Release(saveCtx, user_id, key); err != nil {
+				log.Printf("Failed to release Idempotency-Key %q: %v", key, err)
+			}
+
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyReleasesTheKeyOfAPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultErrorWriter = io.Discard
+
+	calls := 0
+	router := gin.New()
+	router.Use(gin.Recovery(), func(c *gin.Context) { c.Set("user_id", "user") })
+	router.POST("/movies", Idempotency(repository.NewMemoryIdempotencyRepository()), func(c *gin.Context) {
+		calls++
+
+		if calls == 1 {
+			panic("handler failed")
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"calls": calls})
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/movies", nil)
+		req.Header.Set("Idempotency-Key", "key")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d for the panic, want 500", w.Code)
+	}
+
+	// The key was released, the retry runs the handler
+	if w := send(); w.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("got status %d after %d calls, want 201 after 2", w.Code, calls)
+	}
+
+	// Then the response is replayed
+	if w := send(); w.Code != http.StatusCreated || calls != 2 || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("got status %d after %d calls, want the replayed 201", w.Code, calls)
+	}
+}
+
+func TestIdempotencyTakesOverAnAbandonedKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := repository.NewMemoryIdempotencyRepository()
+
+	// A server stopped while running the request, its lease is over
+	abandoned := models.IdempotencyRecord{Key: "key", User_id: "user", Request_hash: "other request", Lease_expires_at: time.Now().Add(-time.Second)}
+
+	if _, err := store.Reserve(context.Background(), abandoned); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", "user") })
+	router.POST("/movies", Idempotency(store), func(c *gin.Context) {
+		calls++
+		c.Header("Location", "/movie/tt0000001")
+		c.JSON(http.StatusCreated, gin.H{"calls": calls})
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/movies", nil)
+		req.Header.Set("Idempotency-Key", "key")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("got status %d after %d calls, want the abandoned key taken over and 201", w.Code, calls)
+	}
+
+	// The replay has the Location header of the original response
+	w := send()
+
+	if w.Code != http.StatusCreated || calls != 1 || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("got status %d after %d calls, want the replayed 201", w.Code, calls)
+	}
+
+	if location := w.Header().Get("Location"); location != "/movie/tt0000001" {
+		t.Fatalf("got the Location %q, want the one of the original response", location)
+	}
+}
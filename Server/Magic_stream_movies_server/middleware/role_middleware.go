@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles a user can have, see the validate tag of models.User.Role
+const (
+	RoleUser  = "USER"
+	RoleAdmin = "ADMIN"
+)
+
+// Policy maps a route, written as "METHOD /path" with the path as registered in gin
+// (e.g. "PATCH /updatereview/:imdb_id"), to the roles allowed to call it
+type Policy map[string][]string
+
+// Authorize checks every request against the policy table.
+// Routes missing from the table are denied so a new protected route can not be left open by mistake.
+func Authorize(policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Unknown paths have no full path, let gin answer them with a 404
+		if c.FullPath() == "" {
+			c.Next()
+			return
+		}
+
+		roles, ok := policy[c.Request.Method+" "+c.FullPath()]
+
+		if !ok || !hasRole(c, roles) {
+			forbidden(c)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func hasRole(c *gin.Context, roles []string) bool {
+	role, ok := c.Get("role")
+
+	if !ok {
+		return false
+	}
+
+	roleName, ok := role.(string)
+
+	return ok && slices.Contains(roles, roleName)
+}
+
+// forbidden sends the same 403 response for every denied request
+func forbidden(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
+	c.Abort()
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Classification is a ranking given by the LLM to a review, stored in the "classifications" collection.
+// Key is a hash of the review, the rankings offered, the prompt template and the model, so the same review
+// asked again with the same inputs is answered without calling the LLM until Expires_at.
+type Classification struct {
+	Key            string    `bson:"_id" json:"key"`
+	Ranking        Ranking   `bson:"ranking" json:"ranking"`
+	Classifier     string    `bson:"classifier" json:"classifier"`
+	Prompt_version int       `bson:"prompt_version,omitempty" json:"prompt_version,omitempty"`
+	Model          string    `bson:"model,omitempty" json:"model,omitempty"`
+	Raw_response   string    `bson:"raw_response,omitempty" json:"raw_response,omitempty"`
+	Created_at     time.Time `bson:"created_at" json:"created_at"`
+	Expires_at     time.Time `bson:"expires_at" json:"expires_at"`
+}
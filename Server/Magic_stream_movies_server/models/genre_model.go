@@ -0,0 +1,17 @@
+package models
+
+// Body of POST /genres, the next free genre_id is given when it is missing
+type GenreCreate struct {
+	Genre_id   int    `json:"genre_id" validate:"min=0"`
+	Genre_name string `json:"genre_name" validate:"required,min=2,max=100"`
+}
+
+// Body of PUT /genres/:genre_id, the new name of the genre
+type GenreRename struct {
+	Genre_name string `json:"genre_name" validate:"required,min=2,max=100"`
+}
+
+// Body of POST /genres/:genre_id/merge, the genre_id of the genre replacing the merged one
+type GenreMerge struct {
+	Into int `json:"into" validate:"required"`
+}
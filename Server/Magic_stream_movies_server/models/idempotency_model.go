@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// States of an idempotency record
+const (
+	IdempotencyInProgress = "in_progress"
+	IdempotencyCompleted  = "completed"
+)
+
+// Response stored for an Idempotency-Key, so a retried request gets the original result back.
+// Keys are scoped per user and expire after a day.
+type IdempotencyRecord struct {
+	Key          string `bson:"key" json:"key"`
+	User_id      string `bson:"user_id" json:"user_id"`
+	Request_hash string `bson:"request_hash" json:"request_hash"`
+	Status       string `bson:"status" json:"status"`
+	// An in progress record whose lease expired was left by a server that stopped, the key can be taken over
+	Lease_expires_at time.Time `bson:"lease_expires_at" json:"lease_expires_at"`
+	Response_status  int       `bson:"response_status" json:"response_status"`
+	// Location header of the response, replayed with the body
+	Response_location string    `bson:"response_location,omitempty" json:"response_location,omitempty"`
+	Response_body     []byte    `bson:"response_body" json:"response_body"`
+	Created_at        time.Time `bson:"created_at" json:"created_at"`
+}
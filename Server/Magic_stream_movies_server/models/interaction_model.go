@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Kinds of interactions between a user and a movie
+const (
+	InteractionRating    = "rating"
+	InteractionWatch     = "watch"
+	InteractionWatchlist = "watchlist"
+	// "Not interested", the movie is never recommended to the user again
+	InteractionDismiss = "dismiss"
+)
+
+// Interaction is a signal of the taste of a user for a movie, stored in the "interactions" collection
+// with at most one interaction per user, movie and kind. The recommender learns from them.
+type Interaction struct {
+	User_id string `bson:"user_id" json:"user_id"`
+	Imdb_id string `bson:"imdb_id" json:"imdb_id"`
+	Kind    string `bson:"kind" json:"kind"`
+	// Stars of a rating, from 1 to 10, unused by the other kinds
+	Value      float64   `bson:"value,omitempty" json:"value,omitempty"`
+	Updated_at time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// HistoryEntry is a movie of the watch history or of the dismissals of a user, with the details of the movie.
+// Movie is missing when the movie was deleted since.
+type HistoryEntry struct {
+	Imdb_id    string    `json:"imdb_id"`
+	Updated_at time.Time `json:"updated_at"`
+	Movie      *Movie    `json:"movie,omitempty"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -9,11 +11,27 @@ type Genre struct {
 	Genre_name string `bson:"genre_name" json:"genre_name" validate:"required,min=2,max=100"`
 }
 
+// Ranking status of a movie whose admin review is being classified, or could not be
+const (
+	RankingStatusPending = "pending"
+	RankingStatusRanked  = "ranked"
+	RankingStatusFailed  = "failed"
+)
+
 type Ranking struct {
 	Ranking_value int    `bson:"ranking_value" json:"ranking_value" validate:"required"`
-	Ranking_name  string `bson:"ranking_name" json:"ranking_name" validate:"required"`
+	Ranking_name  string `bson:"ranking_name" json:"ranking_name" validate:"required,max=100"`
+	// Set on the ranking of the movies without a ranked admin review, it is never offered to the classifiers
+	Not_ranked bool `bson:"not_ranked,omitempty" json:"not_ranked,omitempty"`
 }
 
+// Poster and trailer of the movies imported from an IMDb dump, which has neither, until an admin sets them.
+// An import never replaces the poster or trailer of an existing movie with a placeholder.
+const (
+	PlaceholderPosterPath = "https://placehold.co/300x450?text=No+poster"
+	PlaceholderYouTubeID  = "no-trailer"
+)
+
 type Movie struct {
 	ID           bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	Imbd_id      string        `bson:"imdb_id" json:"imdb_id" validate:"required"`
@@ -23,4 +41,48 @@ type Movie struct {
 	Genre        []Genre       `bson:"genre" json:"genre" validate:"required,dive"`
 	Admin_review string        `bson:"admin_review" json:"admin_review"`
 	Ranking      Ranking       `bson:"ranking" json:"ranking" validate:"required"`
+	// Pending while the admin review is classified in the background, then ranked or failed
+	Ranking_status string `bson:"ranking_status,omitempty" json:"ranking_status,omitempty"`
+	// How the ranking of the admin review was chosen, for audits and comparisons between prompt versions
+	Ranking_audit *RankingAudit `bson:"ranking_audit,omitempty" json:"ranking_audit,omitempty"`
+	// Average of the ratings given by the users and their number, updated with every user review
+	Rating_average float64 `bson:"rating_average,omitempty" json:"rating_average"`
+	Rating_count   int64   `bson:"rating_count,omitempty" json:"rating_count"`
+	// Incremented before the rating stats are recomputed, and the revision the stored stats were computed at,
+	// so the stats computed by a concurrent review never overwrite fresher ones
+	Rating_revision       int64 `bson:"rating_revision,omitempty" json:"-"`
+	Rating_stats_revision int64 `bson:"rating_stats_revision,omitempty" json:"-"`
+	// Incremented by every update, a write based on an older version is rejected
+	Version int64 `bson:"version" json:"version"`
+	// Set when the movie is soft deleted, deleted movies are hidden until restored
+	Deleted_at *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+}
+
+// Body of PUT and PATCH /movie/:imdb_id
+// PUT requires every field, PATCH only changes the fields present in the body.
+// Version is the version the changes are based on, it can also be sent in the If-Match header.
+type MovieUpdate struct {
+	Title       *string  `json:"title"`
+	Poster_path *string  `json:"poster_path"`
+	YouTube_id  *string  `json:"youtube_id"`
+	Genre       *[]Genre `json:"genre"`
+	Version     *int64   `json:"version"`
+}
+
+// Page of movies returned by GET /movies
+// Next_cursor is empty on the last page
+type MoviePage struct {
+	Movies      []Movie `json:"movies"`
+	Total       int64   `json:"total"`
+	Page        int64   `json:"page,omitempty"`
+	Limit       int64   `json:"limit"`
+	Next_cursor string  `json:"next_cursor,omitempty"`
+}
+
+// Movie returned by GET /search with its relevance score
+// Highlights holds the matching fields with the matched words wrapped in <em></em>
+type SearchResult struct {
+	Movie      Movie             `json:"movie"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
 }
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Version of the prompt template asking the LLM for the ranking of a review, stored in the "prompt_templates" collection.
+// The reviews are ranked with the active version, the {rankings} placeholder is replaced by the ranking names
+// and the optional {review} placeholder by the review, which is appended to the prompt otherwise.
+type PromptTemplate struct {
+	Version     int       `bson:"version" json:"version"`
+	Template    string    `bson:"template" json:"template"`
+	Description string    `bson:"description,omitempty" json:"description,omitempty"`
+	Created_by  string    `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	Created_at  time.Time `bson:"created_at" json:"created_at"`
+	// Set on the version the reviews are currently ranked with, it is not stored with the template
+	Active bool `bson:"-" json:"active"`
+}
+
+// Body of POST /prompttemplates, activate makes the new version the one the reviews are ranked with
+type PromptTemplateCreate struct {
+	Template    string `json:"template" validate:"required,max=10000"`
+	Description string `json:"description" validate:"max=500"`
+	Activate    bool   `json:"activate"`
+}
+
+// RankingAudit records how the ranking of an admin review was chosen: the classifier and,
+// when an LLM ranked it, the prompt template version, the model and its raw answer.
+// Cached is set when the answer was reused from the classification cache instead of asking the LLM again.
+type RankingAudit struct {
+	Classifier     string    `bson:"classifier" json:"classifier"`
+	Prompt_version int       `bson:"prompt_version,omitempty" json:"prompt_version,omitempty"`
+	Model          string    `bson:"model,omitempty" json:"model,omitempty"`
+	Raw_response   string    `bson:"raw_response,omitempty" json:"raw_response,omitempty"`
+	Cached         bool      `bson:"cached,omitempty" json:"cached,omitempty"`
+	Ranked_at      time.Time `bson:"ranked_at" json:"ranked_at"`
+}
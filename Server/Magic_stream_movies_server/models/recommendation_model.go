@@ -0,0 +1,65 @@
+package models
+
+// Codes of the reasons a movie is recommended
+const (
+	// The movie has genres among the favourite genres of the user, listed in Genres
+	ReasonFavouriteGenre = "favourite_genre"
+	// The admin ranked the movie among the best rankings, given in Ranking
+	ReasonHighAdminRanking = "high_admin_ranking"
+	// Users who liked the titles of Similar_to, which the user watched, rated well or saved, also liked the movie
+	ReasonSimilarToWatched = "similar_to_watched"
+	// Many users liked the movie recently, Interactions tells how many
+	ReasonTrending = "trending"
+)
+
+// Recommendation is a recommended movie with its score and the reasons it was picked, the strongest first.
+// The fields of the movie are kept at the top level, so clients reading movies can read recommendations.
+type Recommendation struct {
+	Movie
+	Score   float64                `json:"score"`
+	Reasons []RecommendationReason `json:"reasons"`
+	// Only returned in debug mode
+	Breakdown *ScoreBreakdown `json:"breakdown,omitempty"`
+}
+
+// RecommendationReason is a machine-readable reason, the fields set depend on the code
+type RecommendationReason struct {
+	Code         string         `json:"code"`
+	Genres       []Genre        `json:"genres,omitempty"`
+	Ranking      *Ranking       `json:"ranking,omitempty"`
+	Similar_to   []SimilarTitle `json:"similar_to,omitempty"`
+	Interactions int            `json:"interactions,omitempty"`
+}
+
+// SimilarTitle is a title of the user the recommended movie is similar to
+type SimilarTitle struct {
+	Imdb_id string `json:"imdb_id"`
+	Title   string `json:"title,omitempty"`
+}
+
+// ScoreBreakdown shows how the score was computed: score is the sum of the contributions,
+// each contribution the signal multiplied by its weight
+type ScoreBreakdown struct {
+	// Set when the user has too few interactions for collaborative filtering, its weight is then 0
+	Cold_start    bool          `json:"cold_start"`
+	Collaborative ScoreSignal   `json:"collaborative"`
+	Genre         ScoreSignal   `json:"genre"`
+	Ranking       ScoreSignal   `json:"ranking"`
+	Trending      ScoreSignal   `json:"trending"`
+	Neighbours    []ScoreSource `json:"neighbours,omitempty"`
+}
+
+// ScoreSignal is one signal of the score, from 0 to 1 (collaborative is negative for disliked tastes)
+type ScoreSignal struct {
+	Value        float64 `json:"value"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
+
+// ScoreSource is a title of the user behind the collaborative signal: its similarity with the movie
+// and how much the user likes it, from -1 to 1
+type ScoreSource struct {
+	Imdb_id    string  `json:"imdb_id"`
+	Similarity float64 `json:"similarity"`
+	Affinity   float64 `json:"affinity"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// States of a re-ranking run
+const (
+	RerankRunning   = "running"
+	RerankCompleted = "completed"
+	RerankCanceled  = "canceled"
+	RerankFailed    = "failed"
+)
+
+// Run re-classifying every admin review against the current rankings, stored in the "rerank_runs" collection.
+// The movies are processed in imdb_id order, Checkpoint is the last imdb_id done so an interrupted run
+// resumes where it stopped. A dry run only records the changes it would make.
+type RerankRun struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"run_id"`
+	Status      string        `bson:"status" json:"status"`
+	Dry_run     bool          `bson:"dry_run" json:"dry_run"`
+	Concurrency int           `bson:"concurrency" json:"concurrency"`
+	// Server running the run, a run left running by a restart is claimed by a single server
+	Owner string `bson:"owner" json:"-"`
+	// Movies with an admin review when the run started, and how many of them were processed
+	Total     int64   `bson:"total" json:"total"`
+	Processed int64   `bson:"processed" json:"processed"`
+	Progress  float64 `bson:"progress" json:"progress"`
+	// Movies whose ranking changed (or would change in a dry run) and movies that could not be classified
+	Changed    int64      `bson:"changed" json:"changed"`
+	Failed     int64      `bson:"failed" json:"failed"`
+	Checkpoint string     `bson:"checkpoint" json:"checkpoint,omitempty"`
+	Last_error string     `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	Created_at time.Time  `bson:"created_at" json:"created_at"`
+	Updated_at time.Time  `bson:"updated_at" json:"updated_at"`
+	Ended_at   *time.Time `bson:"ended_at,omitempty" json:"ended_at,omitempty"`
+}
+
+// Ranking change found by a re-ranking run, stored in the "rerank_changes" collection.
+// Applied is false in a dry run, or when the review changed during the run. Error is set when the review could not be classified.
+type RerankChange struct {
+	Run_id     bson.ObjectID `bson:"run_id" json:"run_id"`
+	Imdb_id    string        `bson:"imdb_id" json:"imdb_id"`
+	Title      string        `bson:"title" json:"title"`
+	Previous   Ranking       `bson:"previous" json:"previous"`
+	Ranking    *Ranking      `bson:"ranking,omitempty" json:"ranking,omitempty"`
+	Classifier string        `bson:"classifier,omitempty" json:"classifier,omitempty"`
+	// Prompt template versions the previous and the new ranking were chosen with, 0 when no LLM chose it
+	Previous_prompt_version int    `bson:"previous_prompt_version,omitempty" json:"previous_prompt_version,omitempty"`
+	Prompt_version          int    `bson:"prompt_version,omitempty" json:"prompt_version,omitempty"`
+	Applied                 bool   `bson:"applied" json:"applied"`
+	Error                   string `bson:"error,omitempty" json:"error,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// States of a review classification job
+const (
+	ReviewJobPending   = "pending"
+	ReviewJobRunning   = "running"
+	ReviewJobSucceeded = "succeeded"
+	ReviewJobFailed    = "failed"
+	// The movie was deleted or got another review before the job finished, its ranking was dropped
+	ReviewJobSuperseded = "superseded"
+)
+
+// Job ranking an admin review in the background, stored in the "review_jobs" collection.
+// Failed attempts are retried at Next_run_at, a running job whose Locked_until passed is picked up again.
+type ReviewJob struct {
+	ID           bson.ObjectID `bson:"_id,omitempty" json:"job_id"`
+	Imdb_id      string        `bson:"imdb_id" json:"imdb_id"`
+	Admin_review string        `bson:"admin_review" json:"admin_review"`
+	Status       string        `bson:"status" json:"status"`
+	Attempts     int           `bson:"attempts" json:"attempts"`
+	Last_error   string        `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	// Ranking given to the review and the classifier that chose it, once the job succeeded
+	Ranking      *Ranking   `bson:"ranking,omitempty" json:"ranking,omitempty"`
+	Classifier   string     `bson:"classifier,omitempty" json:"classifier,omitempty"`
+	Next_run_at  time.Time  `bson:"next_run_at" json:"next_run_at"`
+	Locked_until *time.Time `bson:"locked_until,omitempty" json:"-"`
+	Created_at   time.Time  `bson:"created_at" json:"created_at"`
+	Updated_at   time.Time  `bson:"updated_at" json:"updated_at"`
+}
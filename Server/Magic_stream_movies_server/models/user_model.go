@@ -13,7 +13,7 @@ type User struct {
 	Last_name        string        `bson:"last_name" json:"last_name" validate:"required,min=2,max=100"`
 	Email            string        `bson:"email" json:"email" validate:"required,email"`
 	Password         string        `bson:"password" json:"password" validate:"required,min=6"`
-	Role             string        `bson:"role" json:"role" validate:"oneof=USER ADMIN"`
+	Role             string        `bson:"role" json:"role" validate:"oneof=USER ADMIN"` // USER on registration, changed by an admin with PUT /users/:user_id/role
 	Created_at       time.Time     `bson:"created_at" json:"created_at"`
 	Updated_at       time.Time     `bson:"updated_at" json:"updated_at"`
 	Token            string        `bson:"token" json:"token"`
@@ -26,6 +26,16 @@ type UserLogin struct {
 	Password string `json:"password" validate:"required,min=6"`
 }
 
+// Body of PUT /users/:user_id/role
+type UserRoleUpdate struct {
+	Role string `json:"role" validate:"required,oneof=USER ADMIN"`
+}
+
+// Body of the POST /refresh request, the refresh token issued at login or at the previous refresh
+type RefreshTokenRequest struct {
+	Refresh_token string `json:"refresh_token" validate:"required"`
+}
+
 // User response DTO Data Transfer Object - Transfer data from frontend to backend or between software
 // By using DTO we're only exposing the data that needs to be exposed to the client
 type UserResponse struct {
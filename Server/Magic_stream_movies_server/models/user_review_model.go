@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// UserReview is the rating of a movie by a user, from 1 to 10 stars, with an optional text review.
+// It is stored in the "user_reviews" collection, a user has at most one review per movie.
+type UserReview struct {
+	User_id string `bson:"user_id" json:"user_id"`
+	// Name shown with the review, copied from the user when the review is written
+	User_name  string    `bson:"user_name" json:"user_name"`
+	Imdb_id    string    `bson:"imdb_id" json:"imdb_id"`
+	Rating     int       `bson:"rating" json:"rating"`
+	Review     string    `bson:"review,omitempty" json:"review,omitempty"`
+	Created_at time.Time `bson:"created_at" json:"created_at"`
+	Updated_at time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Body of POST and PUT /movie/:imdb_id/reviews
+type UserReviewInput struct {
+	Rating int    `json:"rating" validate:"required,min=1,max=10"`
+	Review string `json:"review" validate:"max=5000"`
+}
+
+// RatingStats is the average rating of a movie by the users and the number of ratings it is computed from
+type RatingStats struct {
+	Average float64 `bson:"average" json:"average"`
+	Count   int64   `bson:"count" json:"count"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Watchlist holds the movies a user saved for later, in the order chosen by the user.
+// It is stored in the "watchlists" collection, one document per user, so it is reordered in a single write.
+type Watchlist struct {
+	User_id string          `bson:"_id" json:"user_id"`
+	Items   []WatchlistItem `bson:"items" json:"items"`
+	// Incremented by every change, a reorder based on an older version is rejected
+	Version    int64     `bson:"version" json:"version"`
+	Updated_at time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+type WatchlistItem struct {
+	Imdb_id  string    `bson:"imdb_id" json:"imdb_id"`
+	Added_at time.Time `bson:"added_at" json:"added_at"`
+}
+
+// WatchlistEntry is a movie of the watchlist with its position, from 1, and the details of the movie.
+// Movie is missing when the movie was deleted since.
+type WatchlistEntry struct {
+	Position int       `json:"position"`
+	Imdb_id  string    `json:"imdb_id"`
+	Added_at time.Time `json:"added_at"`
+	Movie    *Movie    `json:"movie,omitempty"`
+}
+
+// Body of PATCH /watchlist/:imdb_id, the new position of the movie from 1, past the end moves it last
+type WatchlistMove struct {
+	Position int `json:"position" validate:"required,min=1"`
+}
@@ -0,0 +1,445 @@
+package recommend
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+)
+
+// Weights of the content signals, they share what RECOMMENDER_CF_WEIGHT leaves to them
+const (
+	genreWeight    = 0.6
+	rankingWeight  = 0.2
+	trendingWeight = 0.2
+)
+
+// Candidates loaded from each of the collaborative, genre and trending signals: candidateFactor per recommendation
+// up to the end of the page asked, and at least minCandidates so the blend has movies to choose from
+const (
+	candidateFactor = 5
+	minCandidates   = 100
+)
+
+// Deepest recommendation served, the pages past it are empty
+const maxRecommendations = 500
+
+// Thresholds of the reasons given with a recommendation
+const (
+	// Ranking signal of the high_admin_ranking reason, the best quarter of the rankings
+	minRankingSignal = 0.75
+	// Trending signal and number of users of the trending reason
+	minTrendingSignal = 0.5
+	minTrendingUsers  = 3
+	// Titles listed by the similar_to_watched reason and by the breakdown of the score
+	maxSimilarTitles    = 3
+	maxBreakdownSources = 10
+)
+
+// A like counts for trending during this period
+const trendingWindow = 7 * 24 * time.Hour
+
+// Recommender recommends movies by blending collaborative filtering, the movies liked by the users
+// having the same tastes, with the favourite genres of the user, the admin ranking and the trending movies.
+// The similarities between movies are recomputed from all the interactions every RECOMMENDER_REFRESH_INTERVAL,
+// the interactions of the user are read at every request so a new rating counts at once.
+type Recommender struct {
+	interactions repository.InteractionRepository
+	movies       repository.MovieRepository
+	rankings     repository.RankingRepository
+
+	cfWeight        float64
+	minInteractions int
+	refreshInterval time.Duration
+
+	mu    sync.RWMutex
+	model *itemModel
+}
+
+func New(cfg *config.Config, interactions repository.InteractionRepository, movies repository.MovieRepository, rankings repository.RankingRepository) *Recommender {
+	return &Recommender{
+		interactions:    interactions,
+		movies:          movies,
+		rankings:        rankings,
+		cfWeight:        cfg.Recommender_cf_weight,
+		minInteractions: cfg.Recommender_min_interactions,
+		refreshInterval: time.Duration(cfg.Recommender_refresh_interval),
+		model:           newItemModel(),
+	}
+}
+
+// Refresh recomputes the similarities between movies and the trending movies from every interaction
+func (r *Recommender) Refresh(ctx context.Context) error {
+	users := map[string]affinities{}
+	// Movies liked by each user during the trending window
+	recent := map[string]map[string]bool{}
+	since := time.Now().Add(-trendingWindow)
+
+	err := r.interactions.Stream(ctx, func(interaction models.Interaction) error {
+		if users[interaction.User_id] == nil {
+			users[interaction.User_id] = affinities{}
+			recent[interaction.User_id] = map[string]bool{}
+		}
+
+		users[interaction.User_id].add(interaction)
+
+		if affinity(interaction) > 0 && interaction.Updated_at.After(since) {
+			recent[interaction.User_id][interaction.Imdb_id] = true
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	model := train(users)
+
+	for userID, movies := range recent {
+		for imdbID := range movies {
+			// A recent watch followed by a bad rating is not a like
+			if users[userID][imdbID] > 0 {
+				model.trending[imdbID]++
+				model.maxTrending = max(model.maxTrending, model.trending[imdbID])
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.model = model
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Start refreshes the similarities in the background until ctx is done
+func (r *Recommender) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Refresh(ctx); err != nil {
+					log.Println("Error refreshing the recommendations:", err)
+				}
+			}
+		}
+	}()
+}
+
+// Recommend returns at most limit movies for the user after the skip best ones, best first,
+// with their score and the reasons they were picked.
+// A user with fewer than RECOMMENDER_MIN_INTERACTIONS liked movies, or whose movies nobody else interacted with,
+// is a cold start: the collaborative signal is left out. debug adds the breakdown of the score to every movie.
+func (r *Recommender) Recommend(ctx context.Context, userID string, favouriteGenres []int, skip int64, limit int64, debug bool) ([]models.Recommendation, error) {
+	if skip >= maxRecommendations {
+		return []models.Recommendation{}, nil
+	}
+
+	end := min(skip+limit, maxRecommendations)
+
+	interactions, err := r.interactions.FindByUser(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user := affinities{}
+
+	for _, interaction := range interactions {
+		user.add(interaction)
+	}
+
+	r.mu.RLock()
+	model := r.model
+	r.mu.RUnlock()
+
+	predictions := model.predict(user)
+
+	// Cold start: not enough known about the user, or about their movies
+	coldStart := user.positives() < r.minInteractions || len(predictions) == 0
+
+	if coldStart {
+		predictions = map[string]prediction{}
+	}
+
+	candidates, err := r.candidates(ctx, user, predictions, model, favouriteGenres, max(int(end)*candidateFactor, minCandidates))
+
+	if err != nil {
+		return nil, err
+	}
+
+	rankings, err := r.rankings.FindAll(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfWeight := r.cfWeight
+
+	if coldStart {
+		cfWeight = 0
+	}
+
+	recommendations := make([]models.Recommendation, 0, len(candidates))
+
+	for _, movie := range candidates {
+		breakdown := models.ScoreBreakdown{
+			Cold_start:    coldStart,
+			Collaborative: signal(predictions[movie.Imbd_id].score, cfWeight),
+			Genre:         signal(genreMatch(movie, favouriteGenres), (1-cfWeight)*genreWeight),
+			Ranking:       signal(rankingQuality(movie.Ranking, rankings), (1-cfWeight)*rankingWeight),
+			Trending:      signal(model.trendingSignal(movie.Imbd_id), (1-cfWeight)*trendingWeight),
+		}
+
+		sources := predictions[movie.Imbd_id].sources
+
+		recommendation := models.Recommendation{
+			Movie: movie,
+			Score: round(breakdown.Collaborative.Contribution + breakdown.Genre.Contribution + breakdown.Ranking.Contribution + breakdown.Trending.Contribution),
+		}
+
+		recommendation.Reasons = reasons(movie, breakdown, sources, favouriteGenres, model.trending[movie.Imbd_id])
+
+		if debug {
+			breakdown.Neighbours = sources[:min(len(sources), maxBreakdownSources)]
+			recommendation.Breakdown = &breakdown
+		}
+
+		recommendations = append(recommendations, recommendation)
+	}
+
+	slices.SortFunc(recommendations, func(a, b models.Recommendation) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Imbd_id, b.Imbd_id))
+	})
+
+	recommendations = recommendations[min(len(recommendations), int(skip)):min(len(recommendations), int(end))]
+
+	if err := r.addSimilarTitles(ctx, recommendations); err != nil {
+		return nil, err
+	}
+
+	return recommendations, nil
+}
+
+// candidates loads the movies with the best collaborative predictions, the best ranked movies of the favourite genres
+// and the trending movies. The movies the user already interacted with, watched and dismissed ones included,
+// are left out before the limits apply, so the next best movies replace them.
+func (r *Recommender) candidates(ctx context.Context, user affinities, predictions map[string]prediction, model *itemModel, favouriteGenres []int, perSignal int) ([]models.Movie, error) {
+	ids := make([]string, 0, len(predictions))
+
+	for id, prediction := range predictions {
+		if prediction.score > 0 {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.SortFunc(ids, func(a, b string) int {
+		return cmp.Or(cmp.Compare(predictions[b].score, predictions[a].score), cmp.Compare(a, b))
+	})
+
+	ids = append(ids[:min(len(ids), perSignal)], model.mostTrending(perSignal, user)...)
+
+	similar, err := r.movies.FindByImdbIDs(ctx, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	known := make([]string, 0, len(user))
+
+	for id := range user {
+		known = append(known, id)
+	}
+
+	byGenre, err := r.movies.FindByGenreIDs(ctx, favouriteGenres, known, int64(perSignal))
+
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []models.Movie{}
+	seen := map[string]bool{}
+
+	for _, movie := range slices.Concat(similar, byGenre) {
+		if seen[movie.Imbd_id] {
+			continue
+		}
+
+		seen[movie.Imbd_id] = true
+		candidates = append(candidates, movie)
+	}
+
+	return candidates, nil
+}
+
+// reasons lists why the movie is recommended, the reason of the largest contribution first
+func reasons(movie models.Movie, breakdown models.ScoreBreakdown, sources []models.ScoreSource, favouriteGenres []int, trendingUsers int) []models.RecommendationReason {
+	type weighted struct {
+		reason       models.RecommendationReason
+		contribution float64
+	}
+
+	var found []weighted
+
+	if breakdown.Collaborative.Value > 0 {
+		reason := models.RecommendationReason{Code: models.ReasonSimilarToWatched}
+
+		for _, source := range sources {
+			if source.Affinity <= 0 || len(reason.Similar_to) == maxSimilarTitles {
+				break
+			}
+
+			reason.Similar_to = append(reason.Similar_to, models.SimilarTitle{Imdb_id: source.Imdb_id})
+		}
+
+		if len(reason.Similar_to) > 0 {
+			found = append(found, weighted{reason, breakdown.Collaborative.Contribution})
+		}
+	}
+
+	if breakdown.Genre.Value > 0 {
+		reason := models.RecommendationReason{Code: models.ReasonFavouriteGenre}
+
+		for _, genre := range movie.Genre {
+			if slices.Contains(favouriteGenres, genre.Genre_id) {
+				reason.Genres = append(reason.Genres, genre)
+			}
+		}
+
+		found = append(found, weighted{reason, breakdown.Genre.Contribution})
+	}
+
+	if breakdown.Ranking.Value >= minRankingSignal {
+		ranking := movie.Ranking
+		found = append(found, weighted{models.RecommendationReason{Code: models.ReasonHighAdminRanking, Ranking: &ranking}, breakdown.Ranking.Contribution})
+	}
+
+	if breakdown.Trending.Value >= minTrendingSignal && trendingUsers >= minTrendingUsers {
+		found = append(found, weighted{models.RecommendationReason{Code: models.ReasonTrending, Interactions: trendingUsers}, breakdown.Trending.Contribution})
+	}
+
+	slices.SortStableFunc(found, func(a, b weighted) int {
+		return cmp.Compare(b.contribution, a.contribution)
+	})
+
+	result := []models.RecommendationReason{}
+
+	for _, w := range found {
+		result = append(result, w.reason)
+	}
+
+	return result
+}
+
+// addSimilarTitles fills in the titles of the movies the similar_to_watched reasons refer to
+func (r *Recommender) addSimilarTitles(ctx context.Context, recommendations []models.Recommendation) error {
+	var ids []string
+
+	for _, recommendation := range recommendations {
+		for _, reason := range recommendation.Reasons {
+			for _, similar := range reason.Similar_to {
+				ids = append(ids, similar.Imdb_id)
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	movies, err := r.movies.FindByImdbIDs(ctx, ids)
+
+	if err != nil {
+		return err
+	}
+
+	titles := map[string]string{}
+
+	for _, movie := range movies {
+		titles[movie.Imbd_id] = movie.Title
+	}
+
+	for _, recommendation := range recommendations {
+		for _, reason := range recommendation.Reasons {
+			for i := range reason.Similar_to {
+				reason.Similar_to[i].Title = titles[reason.Similar_to[i].Imdb_id]
+			}
+		}
+	}
+
+	return nil
+}
+
+// signal weighs a signal of the score
+func signal(value float64, weight float64) models.ScoreSignal {
+	return models.ScoreSignal{Value: round(value), Weight: round(weight), Contribution: round(value * weight)}
+}
+
+// round keeps 4 decimals, enough to compare the scores without the noise of floating point
+func round(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
+
+// genreMatch is the share of the genres of the movie among the favourite genres of the user
+func genreMatch(movie models.Movie, favouriteGenres []int) float64 {
+	if len(movie.Genre) == 0 {
+		return 0
+	}
+
+	matched := 0
+
+	for _, genre := range movie.Genre {
+		if slices.Contains(favouriteGenres, genre.Genre_id) {
+			matched++
+		}
+	}
+
+	return float64(matched) / float64(len(movie.Genre))
+}
+
+// rankingQuality maps the admin ranking of the movie to 1 for the best ranking down to 0 for the worst,
+// the lowest ranking value being the best. A movie not ranked yet gets 0.
+func rankingQuality(ranking models.Ranking, rankings []models.Ranking) float64 {
+	if ranking.Not_ranked {
+		return 0
+	}
+
+	best, worst := 0, 0
+	found := false
+
+	for _, known := range rankings {
+		if known.Not_ranked {
+			continue
+		}
+
+		if !found {
+			best, worst, found = known.Ranking_value, known.Ranking_value, true
+		}
+
+		best = min(best, known.Ranking_value)
+		worst = max(worst, known.Ranking_value)
+	}
+
+	if !found || ranking.Ranking_value < best || ranking.Ranking_value > worst {
+		return 0
+	}
+
+	if best == worst {
+		return 1
+	}
+
+	return float64(worst-ranking.Ranking_value) / float64(worst-best)
+}
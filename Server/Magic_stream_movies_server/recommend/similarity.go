@@ -0,0 +1,251 @@
+package recommend
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// Tuning of the item-item similarity
+const (
+	// Most similar movies kept per movie
+	maxNeighbours = 50
+	// Strongest interactions of a user used for training, bounds the pairs a very active user adds
+	maxItemsPerUser = 200
+	// Pairs of movies counted over all the users, the training time and memory stop growing with the users:
+	// past it every user is trained on fewer of their strongest interactions
+	maxTrainingPairs = 1_000_000
+	// Similarities seen in few users are shrunk towards 0: n co-interacting users keep n/(n+shrinkage) of it
+	similarityShrinkage = 5.0
+	// Added to the sum of the similarities when averaging, a movie close to a single watched title scores lower
+	// than one close to many of them
+	evidenceDamping = 1.0
+)
+
+// affinity is how much an interaction tells the user likes the movie, from -1 to 1.
+// A rating above 5.5 stars is positive and below negative, watching and saving for later are mildly positive,
+// "not interested" is negative.
+func affinity(interaction models.Interaction) float64 {
+	switch interaction.Kind {
+	case models.InteractionRating:
+		return (interaction.Value - 5.5) / 4.5
+	case models.InteractionWatch:
+		return 0.5
+	case models.InteractionWatchlist:
+		return 0.3
+	case models.InteractionDismiss:
+		return -0.5
+	}
+
+	return 0
+}
+
+// affinities adds up the interactions of a user with each movie, clamped to [-1, 1]
+type affinities map[string]float64
+
+func (a affinities) add(interaction models.Interaction) {
+	a[interaction.Imdb_id] = max(-1, min(1, a[interaction.Imdb_id]+affinity(interaction)))
+}
+
+// positives returns the number of movies the user likes
+func (a affinities) positives() int {
+	count := 0
+
+	for _, value := range a {
+		if value > 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
+// neighbour is a movie similar to another one
+type neighbour struct {
+	imdbID     string
+	similarity float64
+}
+
+// itemModel holds the most similar movies of every movie, computed from the interactions of all the users,
+// and the number of users who liked each movie recently
+type itemModel struct {
+	neighbours map[string][]neighbour
+	trending   map[string]int
+	// Highest count of trending, the trending signal of a movie is its count divided by it
+	maxTrending int
+}
+
+func newItemModel() *itemModel {
+	return &itemModel{neighbours: map[string][]neighbour{}, trending: map[string]int{}}
+}
+
+// trendingSignal returns the recent likes of the movie relative to the most liked movie, from 0 to 1
+func (m *itemModel) trendingSignal(imdbID string) float64 {
+	if m.maxTrending == 0 {
+		return 0
+	}
+
+	return float64(m.trending[imdbID]) / float64(m.maxTrending)
+}
+
+// mostTrending returns at most limit movies liked recently by the most users, leaving out the movies of the user
+func (m *itemModel) mostTrending(limit int, user affinities) []string {
+	ids := make([]string, 0, len(m.trending))
+
+	for id := range m.trending {
+		if _, known := user[id]; !known {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.SortFunc(ids, func(a, b string) int {
+		return cmp.Or(cmp.Compare(m.trending[b], m.trending[a]), cmp.Compare(a, b))
+	})
+
+	return ids[:min(len(ids), limit)]
+}
+
+// train computes the cosine similarity of the affinity vectors of every pair of movies some user interacted with,
+// shrunk when few users support it. Only positive similarities are kept.
+func train(users map[string]affinities) *itemModel {
+	type pair struct{ a, b string }
+
+	// Dot product of the affinities of the pair and number of users who interacted with both movies
+	type cooccurrence struct {
+		dot   float64
+		count int
+	}
+
+	// Movies of every user, the strongest opinions first
+	strongest := make([][]string, 0, len(users))
+	owners := make([]affinities, 0, len(users))
+
+	for _, items := range users {
+		ids := make([]string, 0, len(items))
+
+		for id := range items {
+			ids = append(ids, id)
+		}
+
+		// Then by imdb_id so training is deterministic
+		slices.SortFunc(ids, func(a, b string) int {
+			return cmp.Or(cmp.Compare(math.Abs(items[b]), math.Abs(items[a])), cmp.Compare(a, b))
+		})
+
+		strongest = append(strongest, ids)
+		owners = append(owners, items)
+	}
+
+	limit := itemsPerUser(strongest)
+	norms := map[string]float64{}
+	pairs := map[pair]cooccurrence{}
+
+	for u, items := range owners {
+		ids := strongest[u][:min(len(strongest[u]), limit)]
+		slices.Sort(ids)
+
+		for i, a := range ids {
+			norms[a] += items[a] * items[a]
+
+			for _, b := range ids[i+1:] {
+				key := pair{a, b}
+				pairs[key] = cooccurrence{dot: pairs[key].dot + items[a]*items[b], count: pairs[key].count + 1}
+			}
+		}
+	}
+
+	model := newItemModel()
+
+	for key, co := range pairs {
+		if co.dot <= 0 {
+			continue
+		}
+
+		n := float64(co.count)
+		similarity := co.dot / math.Sqrt(norms[key.a]*norms[key.b]) * n / (n + similarityShrinkage)
+
+		model.neighbours[key.a] = append(model.neighbours[key.a], neighbour{imdbID: key.b, similarity: similarity})
+		model.neighbours[key.b] = append(model.neighbours[key.b], neighbour{imdbID: key.a, similarity: similarity})
+	}
+
+	for id, list := range model.neighbours {
+		slices.SortFunc(list, func(x, y neighbour) int {
+			return cmp.Or(cmp.Compare(y.similarity, x.similarity), cmp.Compare(x.imdbID, y.imdbID))
+		})
+
+		model.neighbours[id] = list[:min(len(list), maxNeighbours)]
+	}
+
+	return model
+}
+
+// itemsPerUser returns how many of their strongest interactions every user is trained on, at most maxItemsPerUser
+// and few enough that the pairs of all the users stay within maxTrainingPairs
+func itemsPerUser(users [][]string) int {
+	pairs := func(limit int) int {
+		total := 0
+
+		for _, ids := range users {
+			k := min(len(ids), limit)
+			total += k * (k - 1) / 2
+		}
+
+		return total
+	}
+
+	// The largest limit within the budget, a single item per user adds no pair
+	low, high := 1, maxItemsPerUser
+
+	for low < high {
+		middle := (low + high + 1) / 2
+
+		if pairs(middle) <= maxTrainingPairs {
+			low = middle
+		} else {
+			high = middle - 1
+		}
+	}
+
+	return low
+}
+
+// prediction is the predicted affinity of the user for a movie and the movies of the user it comes from,
+// the largest contribution first
+type prediction struct {
+	score   float64
+	sources []models.ScoreSource
+}
+
+// predict predicts the affinity of the user for the movies similar to the ones they interacted with,
+// as the average of their affinities weighted by the similarities. The movies of the user are left out.
+func (m *itemModel) predict(user affinities) map[string]prediction {
+	weighted := map[string]float64{}
+	total := map[string]float64{}
+	sources := map[string][]models.ScoreSource{}
+
+	for id, value := range user {
+		for _, neighbour := range m.neighbours[id] {
+			if _, known := user[neighbour.imdbID]; known {
+				continue
+			}
+
+			weighted[neighbour.imdbID] += neighbour.similarity * value
+			total[neighbour.imdbID] += neighbour.similarity
+			sources[neighbour.imdbID] = append(sources[neighbour.imdbID], models.ScoreSource{Imdb_id: id, Similarity: round(neighbour.similarity), Affinity: round(value)})
+		}
+	}
+
+	predictions := make(map[string]prediction, len(weighted))
+
+	for id, sum := range weighted {
+		slices.SortFunc(sources[id], func(a, b models.ScoreSource) int {
+			return cmp.Or(cmp.Compare(b.Similarity*b.Affinity, a.Similarity*a.Affinity), cmp.Compare(a.Imdb_id, b.Imdb_id))
+		})
+
+		predictions[id] = prediction{score: sum / (total[id] + evidenceDamping), sources: sources[id]}
+	}
+
+	return predictions
+}
@@ -0,0 +1,55 @@
+package recommend
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTrainFindsTheMoviesLikedTogether(t *testing.T) {
+	users := map[string]affinities{}
+
+	for u := range 10 {
+		users[fmt.Sprint("user", u)] = affinities{"tt1": 1, "tt2": 0.8, "tt3": -1}
+	}
+
+	model := train(users)
+
+	if neighbours := model.neighbours["tt1"]; len(neighbours) != 1 || neighbours[0].imdbID != "tt2" {
+		t.Fatalf("got the neighbours %+v of tt1, want tt2 only", neighbours)
+	}
+
+	if len(model.neighbours["tt3"]) != 0 {
+		t.Fatalf("got the neighbours %+v of a disliked movie, want none", model.neighbours["tt3"])
+	}
+}
+
+func TestItemsPerUserBoundsThePairs(t *testing.T) {
+	users := make([][]string, 1000)
+
+	for u := range users {
+		for i := range maxItemsPerUser {
+			users[u] = append(users[u], fmt.Sprintf("tt%07d", i))
+		}
+	}
+
+	limit := itemsPerUser(users)
+
+	// 1000 users of 45 movies add 990 000 pairs, one more movie each would pass the budget
+	if limit != 45 {
+		t.Fatalf("got %d movies per user, want 45", limit)
+	}
+
+	if few := itemsPerUser(users[:2]); few != maxItemsPerUser {
+		t.Fatalf("got %d movies per user for two users, want maxItemsPerUser", few)
+	}
+
+	users = make([][]string, maxTrainingPairs+1)
+
+	for u := range users {
+		users[u] = []string{"tt1", "tt2"}
+	}
+
+	if single := itemsPerUser(users); single != 1 {
+		t.Fatalf("got %d movies per user past the budget with pairs only, want 1", single)
+	}
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// MemoryClassificationRepository is an in-memory ClassificationRepository, expired classifications are dropped when read
+type MemoryClassificationRepository struct {
+	mu              sync.Mutex
+	classifications map[string]models.Classification
+}
+
+func NewMemoryClassificationRepository() *MemoryClassificationRepository {
+	return &MemoryClassificationRepository{classifications: map[string]models.Classification{}}
+}
+
+func (r *MemoryClassificationRepository) Find(ctx context.Context, key string) (models.Classification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	classification, ok := r.classifications[key]
+
+	if !ok {
+		return models.Classification{}, ErrNotFound
+	}
+
+	if !time.Now().Before(classification.Expires_at) {
+		delete(r.classifications, key)
+		return models.Classification{}, ErrNotFound
+	}
+
+	return classification, nil
+}
+
+func (r *MemoryClassificationRepository) Save(ctx context.Context, classification models.Classification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.classifications[classification.Key] = classification
+
+	return nil
+}
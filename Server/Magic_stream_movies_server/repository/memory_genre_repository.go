@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// MemoryGenreRepository is an in-memory GenreRepository
+type MemoryGenreRepository struct {
+	mu     sync.RWMutex
+	genres []models.Genre
+}
+
+func NewMemoryGenreRepository(genres ...models.Genre) *MemoryGenreRepository {
+	return &MemoryGenreRepository{genres: slices.Clone(genres)}
+}
+
+func (r *MemoryGenreRepository) FindAll(ctx context.Context) ([]models.Genre, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	genres := append([]models.Genre{}, r.genres...)
+
+	slices.SortFunc(genres, func(a, b models.Genre) int {
+		return a.Genre_id - b.Genre_id
+	})
+
+	return genres, nil
+}
+
+func (r *MemoryGenreRepository) FindByID(ctx context.Context, genreID int) (models.Genre, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.indexOf(genreID)
+
+	if i < 0 {
+		return models.Genre{}, ErrNotFound
+	}
+
+	return r.genres[i], nil
+}
+
+func (r *MemoryGenreRepository) Insert(ctx context.Context, genre models.Genre) (models.Genre, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if genre.Genre_id == 0 {
+		for _, other := range r.genres {
+			genre.Genre_id = max(genre.Genre_id, other.Genre_id)
+		}
+
+		genre.Genre_id++
+	}
+
+	if r.indexOf(genre.Genre_id) >= 0 || r.nameTaken(genre.Genre_name, -1) {
+		return genre, ErrDuplicate
+	}
+
+	r.genres = append(r.genres, genre)
+
+	return genre, nil
+}
+
+func (r *MemoryGenreRepository) Rename(ctx context.Context, genreID int, name string) (models.Genre, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(genreID)
+
+	if i < 0 {
+		return models.Genre{}, ErrNotFound
+	}
+
+	if r.nameTaken(name, i) {
+		return r.genres[i], ErrDuplicate
+	}
+
+	r.genres[i].Genre_name = name
+
+	return r.genres[i], nil
+}
+
+func (r *MemoryGenreRepository) Delete(ctx context.Context, genreID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(genreID)
+
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	r.genres = slices.Delete(r.genres, i, i+1)
+
+	return nil
+}
+
+// indexOf returns the position of the genre, -1 if there is none, must be called with the lock held
+func (r *MemoryGenreRepository) indexOf(genreID int) int {
+	return slices.IndexFunc(r.genres, func(genre models.Genre) bool {
+		return genre.Genre_id == genreID
+	})
+}
+
+// nameTaken tells if another genre than the one at position skip has the name, whatever its case,
+// must be called with the lock held
+func (r *MemoryGenreRepository) nameTaken(name string, skip int) bool {
+	for i, genre := range r.genres {
+		if i != skip && strings.EqualFold(genre.Genre_name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renameGenre renames the copy of the genre in genres, it tells if there was one
+func renameGenre(genres []models.Genre, genre models.Genre) bool {
+	renamed := false
+
+	for i := range genres {
+		if genres[i].Genre_id == genre.Genre_id {
+			genres[i].Genre_name = genre.Genre_name
+			renamed = true
+		}
+	}
+
+	return renamed
+}
+
+// mergeGenre returns genres with the genre from replaced by into, without a second copy of into.
+// It tells if genres had the genre from.
+func mergeGenre(genres []models.Genre, from int, into models.Genre) ([]models.Genre, bool) {
+	if !slices.ContainsFunc(genres, func(genre models.Genre) bool { return genre.Genre_id == from }) {
+		return genres, false
+	}
+
+	merged := []models.Genre{}
+
+	for _, genre := range genres {
+		if genre.Genre_id == from {
+			genre = into
+		}
+
+		if !slices.ContainsFunc(merged, func(other models.Genre) bool { return other.Genre_id == genre.Genre_id }) {
+			merged = append(merged, genre)
+		}
+	}
+
+	return merged, true
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// MemoryIdempotencyRepository is an in-memory IdempotencyRepository, records expire like the Mongo TTL index
+type MemoryIdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyRecord
+}
+
+func NewMemoryIdempotencyRepository() *MemoryIdempotencyRepository {
+	return &MemoryIdempotencyRepository{records: map[string]models.IdempotencyRecord{}}
+}
+
+func idempotencyRecordKey(userID, key string) string {
+	return userID + "\x00" + key
+}
+
+func (r *MemoryIdempotencyRepository) Reserve(ctx context.Context, record models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := idempotencyRecordKey(record.User_id, record.Key)
+
+	if existing, ok := r.records[id]; ok && time.Since(existing.Created_at) < idempotencyKeyTTL && !abandoned(existing, time.Now()) {
+		return &existing, nil
+	}
+
+	record.Status = models.IdempotencyInProgress
+	record.Created_at = time.Now()
+
+	r.records[id] = record
+
+	return nil, nil
+}
+
+func (r *MemoryIdempotencyRepository) Complete(ctx context.Context, userID string, key string, status int, location string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := idempotencyRecordKey(userID, key)
+
+	if record, ok := r.records[id]; ok {
+		record.Status = models.IdempotencyCompleted
+		record.Response_status = status
+		record.Response_location = location
+		record.Response_body = append([]byte{}, body...)
+		r.records[id] = record
+	}
+
+	return nil
+}
+
+// abandoned tells whether the record is in progress with an expired lease
+func abandoned(record models.IdempotencyRecord, now time.Time) bool {
+	return record.Status == models.IdempotencyInProgress && now.After(record.Lease_expires_at)
+}
+
+func (r *MemoryIdempotencyRepository) Release(ctx context.Context, userID string, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.records, idempotencyRecordKey(userID, key))
+
+	return nil
+}
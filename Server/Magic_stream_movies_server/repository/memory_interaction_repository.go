@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// MemoryInteractionRepository is an in-memory InteractionRepository
+type MemoryInteractionRepository struct {
+	mu           sync.RWMutex
+	interactions []models.Interaction
+}
+
+func NewMemoryInteractionRepository(interactions ...models.Interaction) *MemoryInteractionRepository {
+	return &MemoryInteractionRepository{interactions: slices.Clone(interactions)}
+}
+
+// indexOf returns the position of the interaction, -1 if there is none, must be called with the lock held
+func (r *MemoryInteractionRepository) indexOf(userID string, imdbID string, kind string) int {
+	return slices.IndexFunc(r.interactions, func(interaction models.Interaction) bool {
+		return interaction.User_id == userID && interaction.Imdb_id == imdbID && interaction.Kind == kind
+	})
+}
+
+func (r *MemoryInteractionRepository) Record(ctx context.Context, interaction models.Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	interaction.Updated_at = time.Now()
+
+	if i := r.indexOf(interaction.User_id, interaction.Imdb_id, interaction.Kind); i >= 0 {
+		r.interactions[i] = interaction
+		return nil
+	}
+
+	r.interactions = append(r.interactions, interaction)
+
+	return nil
+}
+
+func (r *MemoryInteractionRepository) Remove(ctx context.Context, userID string, imdbID string, kind string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(userID, imdbID, kind)
+
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	r.interactions = slices.Delete(r.interactions, i, i+1)
+
+	return nil
+}
+
+func (r *MemoryInteractionRepository) FindByUser(ctx context.Context, userID string) ([]models.Interaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	interactions := []models.Interaction{}
+
+	for _, interaction := range r.interactions {
+		if interaction.User_id == userID {
+			interactions = append(interactions, interaction)
+		}
+	}
+
+	return interactions, nil
+}
+
+func (r *MemoryInteractionRepository) ListByUser(ctx context.Context, userID string, kind string, skip int64, limit int64) ([]models.Interaction, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matching := []models.Interaction{}
+
+	for _, interaction := range r.interactions {
+		if interaction.User_id == userID && interaction.Kind == kind {
+			matching = append(matching, interaction)
+		}
+	}
+
+	// Same order as the Mongo implementation, most recent first
+	slices.SortFunc(matching, func(a, b models.Interaction) int {
+		return cmp.Or(b.Updated_at.Compare(a.Updated_at), cmp.Compare(a.Imdb_id, b.Imdb_id))
+	})
+
+	total := int64(len(matching))
+	start := min(skip, total)
+	end := min(start+limit, total)
+
+	return matching[start:end], total, nil
+}
+
+func (r *MemoryInteractionRepository) Stream(ctx context.Context, fn func(models.Interaction) error) error {
+	r.mu.RLock()
+	interactions := slices.Clone(r.interactions)
+	r.mu.RUnlock()
+
+	for _, interaction := range interactions {
+		if err := fn(interaction); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
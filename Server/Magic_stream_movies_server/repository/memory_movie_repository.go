@@ -0,0 +1,559 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// MemoryMovieRepository is an in-memory MovieRepository, movies are kept in insertion order
+type MemoryMovieRepository struct {
+	mu     sync.RWMutex
+	movies []models.Movie
+}
+
+func NewMemoryMovieRepository(movies ...models.Movie) *MemoryMovieRepository {
+	return &MemoryMovieRepository{movies: slices.Clone(movies)}
+}
+
+// active returns the movies that are not soft deleted, must be called with the lock held
+func (r *MemoryMovieRepository) active() []models.Movie {
+	movies := []models.Movie{}
+
+	for _, movie := range r.movies {
+		if movie.Deleted_at == nil {
+			movies = append(movies, movie)
+		}
+	}
+
+	return movies
+}
+
+// indexOf returns the position of the movie, -1 if there is none, must be called with the lock held
+func (r *MemoryMovieRepository) indexOf(imdbID string, deleted bool) int {
+	return slices.IndexFunc(r.movies, func(movie models.Movie) bool {
+		return movie.Imbd_id == imdbID && (movie.Deleted_at != nil) == deleted
+	})
+}
+
+func (r *MemoryMovieRepository) FindAll(ctx context.Context) ([]models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.active(), nil
+}
+
+func (r *MemoryMovieRepository) Stream(ctx context.Context, filter MovieFilter, fn func(models.Movie) error) error {
+	r.mu.RLock()
+
+	matching := []models.Movie{}
+
+	for _, movie := range r.active() {
+		if filter.matches(movie) {
+			matching = append(matching, movie)
+		}
+	}
+
+	// fn runs without the lock so it may be slow or call the repository
+	r.mu.RUnlock()
+
+	slices.SortFunc(matching, func(a, b models.Movie) int {
+		return strings.Compare(a.Imbd_id, b.Imbd_id)
+	})
+
+	for _, movie := range matching {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(movie); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryMovieRepository) FindByImdbID(ctx context.Context, imdbID string) (models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if i := r.indexOf(imdbID, false); i >= 0 {
+		return r.movies[i], nil
+	}
+
+	return models.Movie{}, ErrNotFound
+}
+
+func (r *MemoryMovieRepository) Insert(ctx context.Context, movie models.Movie) (bson.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Same as the unique index on imdb_id, soft deleted movies included
+	if slices.ContainsFunc(r.movies, func(existing models.Movie) bool { return existing.Imbd_id == movie.Imbd_id }) {
+		return bson.NilObjectID, ErrDuplicate
+	}
+
+	if movie.ID.IsZero() {
+		movie.ID = bson.NewObjectID()
+	}
+
+	movie.Version = 1
+	movie.Deleted_at = nil
+	// The rating stats only come from the user reviews
+	movie.Rating_average, movie.Rating_count = 0, 0
+	movie.Rating_revision, movie.Rating_stats_revision = 0, 0
+
+	r.movies = append(r.movies, movie)
+
+	return movie.ID, nil
+}
+
+func (r *MemoryMovieRepository) SetPendingReview(ctx context.Context, imdbID string, review string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(imdbID, false)
+
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	r.movies[i].Admin_review = review
+	r.movies[i].Ranking_status = models.RankingStatusPending
+	r.movies[i].Version++
+
+	return nil
+}
+
+func (r *MemoryMovieRepository) UpdateReview(ctx context.Context, imdbID string, review string, ranking models.Ranking, audit models.RankingAudit) error {
+	return r.updateReviewed(imdbID, review, func(movie *models.Movie) {
+		movie.Ranking = ranking
+		movie.Ranking_audit = &audit
+		movie.Ranking_status = models.RankingStatusRanked
+	})
+}
+
+func (r *MemoryMovieRepository) FailReview(ctx context.Context, imdbID string, review string) error {
+	return r.updateReviewed(imdbID, review, func(movie *models.Movie) {
+		movie.Ranking_status = models.RankingStatusFailed
+	})
+}
+
+// updateReviewed changes the movie only while it still has the admin review being ranked
+func (r *MemoryMovieRepository) updateReviewed(imdbID string, review string, change func(movie *models.Movie)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(imdbID, false)
+
+	if i < 0 || r.movies[i].Admin_review != review {
+		return ErrNotFound
+	}
+
+	change(&r.movies[i])
+	r.movies[i].Version++
+
+	return nil
+}
+
+// reviewed returns the movies having an admin review in imdb_id order, must be called with the lock held
+func (r *MemoryMovieRepository) reviewed() []models.Movie {
+	movies := []models.Movie{}
+
+	for _, movie := range r.active() {
+		if movie.Admin_review != "" {
+			movies = append(movies, movie)
+		}
+	}
+
+	slices.SortFunc(movies, func(a, b models.Movie) int {
+		return strings.Compare(a.Imbd_id, b.Imbd_id)
+	})
+
+	return movies
+}
+
+func (r *MemoryMovieRepository) FindReviewed(ctx context.Context, afterImdbID string, limit int64) ([]models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	movies := []models.Movie{}
+
+	for _, movie := range r.reviewed() {
+		if movie.Imbd_id > afterImdbID && int64(len(movies)) < limit {
+			movies = append(movies, movie)
+		}
+	}
+
+	return movies, nil
+}
+
+func (r *MemoryMovieRepository) CountReviewed(ctx context.Context) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.reviewed())), nil
+}
+
+func (r *MemoryMovieRepository) CountByRanking(ctx context.Context, name string) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+
+	for _, movie := range r.movies {
+		if movie.Ranking.Ranking_name == name {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *MemoryMovieRepository) RemapRanking(ctx context.Context, from string, ranking models.Ranking) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	remapped := []string{}
+
+	for i := range r.movies {
+		if r.movies[i].Ranking.Ranking_name == from {
+			r.movies[i].Ranking = ranking
+			r.movies[i].Version++
+			remapped = append(remapped, r.movies[i].Imbd_id)
+		}
+	}
+
+	return remapped, nil
+}
+
+func (r *MemoryMovieRepository) RenameGenre(ctx context.Context, genre models.Genre) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	renamed := []string{}
+
+	for i := range r.movies {
+		// The genres are copied first, the slice may be shared with a movie returned earlier
+		genres := slices.Clone(r.movies[i].Genre)
+
+		if renameGenre(genres, genre) {
+			r.movies[i].Genre = genres
+			r.movies[i].Version++
+			renamed = append(renamed, r.movies[i].Imbd_id)
+		}
+	}
+
+	return renamed, nil
+}
+
+func (r *MemoryMovieRepository) MergeGenre(ctx context.Context, from int, into models.Genre) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	merged := []string{}
+
+	for i := range r.movies {
+		if genres, ok := mergeGenre(r.movies[i].Genre, from, into); ok {
+			r.movies[i].Genre = genres
+			r.movies[i].Version++
+			merged = append(merged, r.movies[i].Imbd_id)
+		}
+	}
+
+	return merged, nil
+}
+
+func (r *MemoryMovieRepository) FindByGenreIDs(ctx context.Context, genreIDs []int, excludeImdbIDs []string, limit int64) ([]models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	movies := []models.Movie{}
+
+	for _, movie := range r.active() {
+		if slices.Contains(excludeImdbIDs, movie.Imbd_id) {
+			continue
+		}
+
+		if slices.ContainsFunc(movie.Genre, func(genre models.Genre) bool {
+			return slices.Contains(genreIDs, genre.Genre_id)
+		}) {
+			movies = append(movies, movie)
+		}
+	}
+
+	// Same order as the Mongo implementation, lowest ranking value first
+	sort.SliceStable(movies, func(i, j int) bool {
+		return movies[i].Ranking.Ranking_value < movies[j].Ranking.Ranking_value
+	})
+
+	if limit > 0 && int64(len(movies)) > limit {
+		movies = movies[:limit]
+	}
+
+	return movies, nil
+}
+
+func (r *MemoryMovieRepository) FindByImdbIDs(ctx context.Context, imdbIDs []string) ([]models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	movies := []models.Movie{}
+
+	for _, movie := range r.active() {
+		if slices.Contains(imdbIDs, movie.Imbd_id) {
+			movies = append(movies, movie)
+		}
+	}
+
+	return movies, nil
+}
+
+func (r *MemoryMovieRepository) NextRatingRevision(ctx context.Context, imdbID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.movies, func(movie models.Movie) bool { return movie.Imbd_id == imdbID })
+
+	if i < 0 {
+		return 0, ErrNotFound
+	}
+
+	r.movies[i].Rating_revision++
+
+	return r.movies[i].Rating_revision, nil
+}
+
+func (r *MemoryMovieRepository) SetRatingStats(ctx context.Context, imdbID string, stats models.RatingStats, revision int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.movies, func(movie models.Movie) bool { return movie.Imbd_id == imdbID })
+
+	if i < 0 || r.movies[i].Rating_stats_revision >= revision {
+		return ErrNotFound
+	}
+
+	r.movies[i].Rating_average = stats.Average
+	r.movies[i].Rating_count = stats.Count
+	r.movies[i].Rating_stats_revision = revision
+
+	return nil
+}
+
+func (r *MemoryMovieRepository) List(ctx context.Context, query MovieQuery) (models.MoviePage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	page := models.MoviePage{Movies: []models.Movie{}, Limit: query.Limit}
+
+	matching := []models.Movie{}
+
+	for _, movie := range r.active() {
+		if query.matches(movie) {
+			matching = append(matching, movie)
+		}
+	}
+
+	page.Total = int64(len(matching))
+
+	order := func(a, b models.Movie) int {
+		if query.Descending {
+			return -compareMovies(query.Sort_by, a, b)
+		}
+		return compareMovies(query.Sort_by, a, b)
+	}
+
+	slices.SortStableFunc(matching, order)
+
+	start := int64(0)
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query)
+
+		if err != nil {
+			return page, err
+		}
+
+		last := models.Movie{Title: cursor.Title, Imbd_id: cursor.Imdb_id, Ranking: models.Ranking{Ranking_value: cursor.Ranking}}
+
+		// First movie sorted after the last movie of the previous page
+		start = int64(len(matching))
+
+		for i, movie := range matching {
+			if order(movie, last) > 0 {
+				start = int64(i)
+				break
+			}
+		}
+	} else {
+		page.Page = query.Page
+		start = min((query.Page-1)*query.Limit, int64(len(matching)))
+	}
+
+	end := min(start+query.Limit, int64(len(matching)))
+
+	page.Movies = append(page.Movies, matching[start:end]...)
+
+	if end < int64(len(matching)) && len(page.Movies) > 0 {
+		page.Next_cursor = encodeCursor(query, page.Movies[len(page.Movies)-1])
+	}
+
+	return page, nil
+}
+
+// versionedMovie returns the position of the movie to update, ErrVersionConflict if it is not at expectedVersion.
+// It must be called with the lock held.
+func (r *MemoryMovieRepository) versionedMovie(imdbID string, expectedVersion int64) (int, error) {
+	i := r.indexOf(imdbID, false)
+
+	if i < 0 {
+		return i, ErrNotFound
+	}
+
+	if r.movies[i].Version != expectedVersion {
+		return i, ErrVersionConflict
+	}
+
+	return i, nil
+}
+
+func (r *MemoryMovieRepository) Replace(ctx context.Context, movie models.Movie, expectedVersion int64) (models.Movie, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, err := r.versionedMovie(movie.Imbd_id, expectedVersion)
+
+	if err != nil {
+		return models.Movie{}, err
+	}
+
+	r.movies[i].Title = movie.Title
+	r.movies[i].Poster_path = movie.Poster_path
+	r.movies[i].YouTube_id = movie.YouTube_id
+	r.movies[i].Genre = slices.Clone(movie.Genre)
+	r.movies[i].Version = expectedVersion + 1
+
+	return r.movies[i], nil
+}
+
+func (r *MemoryMovieRepository) SoftDelete(ctx context.Context, imdbID string, expectedVersion int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, err := r.versionedMovie(imdbID, expectedVersion)
+
+	if err != nil {
+		return err
+	}
+
+	deletedAt := time.Now()
+
+	r.movies[i].Deleted_at = &deletedAt
+	r.movies[i].Version = expectedVersion + 1
+
+	return nil
+}
+
+func (r *MemoryMovieRepository) Restore(ctx context.Context, imdbID string) (models.Movie, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(imdbID, true)
+
+	if i < 0 {
+		return models.Movie{}, ErrNotFound
+	}
+
+	r.movies[i].Deleted_at = nil
+	r.movies[i].Version++
+
+	return r.movies[i], nil
+}
+
+func (r *MemoryMovieRepository) UpsertMany(ctx context.Context, movies []models.Movie) ([]UpsertResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := make([]UpsertResult, len(movies))
+
+	for i, movie := range movies {
+		results[i].Created, results[i].Err = r.upsert(movie)
+	}
+
+	return results, nil
+}
+
+// upsert inserts or updates one movie, must be called with the lock held
+func (r *MemoryMovieRepository) upsert(movie models.Movie) (bool, error) {
+	if r.indexOf(movie.Imbd_id, true) >= 0 {
+		return false, ErrDuplicate
+	}
+
+	i := r.indexOf(movie.Imbd_id, false)
+
+	if i < 0 {
+		if movie.ID.IsZero() {
+			movie.ID = bson.NewObjectID()
+		}
+
+		movie.Version = 1
+		movie.Deleted_at = nil
+		movie.Rating_average, movie.Rating_count = 0, 0
+		movie.Rating_revision, movie.Rating_stats_revision = 0, 0
+		movie.Rating_revision, movie.Rating_stats_revision = 0, 0
+		r.movies = append(r.movies, movie)
+
+		return true, nil
+	}
+
+	r.movies[i].Title = movie.Title
+	r.movies[i].Genre = slices.Clone(movie.Genre)
+	r.movies[i].Version++
+
+	// A row without review or ranking keeps the ones of the movie
+	if movie.Admin_review != "" {
+		r.movies[i].Admin_review = movie.Admin_review
+	}
+
+	if !movie.Ranking.Not_ranked {
+		r.movies[i].Ranking = movie.Ranking
+	}
+
+	// A placeholder only fills a new movie, the poster and trailer of an existing one are kept
+	if movie.Poster_path != models.PlaceholderPosterPath {
+		r.movies[i].Poster_path = movie.Poster_path
+	}
+
+	if movie.YouTube_id != models.PlaceholderYouTubeID {
+		r.movies[i].YouTube_id = movie.YouTube_id
+	}
+
+	return false, nil
+}
+
+func (r *MemoryMovieRepository) FindGenres(ctx context.Context) ([]models.Genre, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	genres := []models.Genre{}
+
+	for _, movie := range r.active() {
+		for _, genre := range movie.Genre {
+			if !slices.Contains(genres, genre) {
+				genres = append(genres, genre)
+			}
+		}
+	}
+
+	sort.SliceStable(genres, func(i, j int) bool { return genres[i].Genre_id < genres[j].Genre_id })
+
+	return genres, nil
+}
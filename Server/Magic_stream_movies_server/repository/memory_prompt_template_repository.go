@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// MemoryPromptTemplateRepository is an in-memory PromptTemplateRepository, versions are kept in insertion order
+type MemoryPromptTemplateRepository struct {
+	mu        sync.RWMutex
+	templates []models.PromptTemplate
+	active    int
+}
+
+func NewMemoryPromptTemplateRepository() *MemoryPromptTemplateRepository {
+	return &MemoryPromptTemplateRepository{}
+}
+
+func (r *MemoryPromptTemplateRepository) Insert(ctx context.Context, template models.PromptTemplate) (models.PromptTemplate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	template.Version = len(r.templates) + 1
+	template.Created_at = time.Now()
+	template.Active = false
+
+	r.templates = append(r.templates, template)
+
+	return template, nil
+}
+
+func (r *MemoryPromptTemplateRepository) FindAll(ctx context.Context) ([]models.PromptTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	templates := []models.PromptTemplate{}
+
+	for _, template := range slices.Backward(r.templates) {
+		template.Active = template.Version == r.active
+		templates = append(templates, template)
+	}
+
+	return templates, nil
+}
+
+func (r *MemoryPromptTemplateRepository) FindByVersion(ctx context.Context, version int) (models.PromptTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.find(version)
+}
+
+func (r *MemoryPromptTemplateRepository) FindActive(ctx context.Context) (models.PromptTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.find(r.active)
+}
+
+func (r *MemoryPromptTemplateRepository) Activate(ctx context.Context, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.find(version); err != nil {
+		return err
+	}
+
+	r.active = version
+
+	return nil
+}
+
+// find returns the version, versions are numbered from 1, must be called with the lock held
+func (r *MemoryPromptTemplateRepository) find(version int) (models.PromptTemplate, error) {
+	if version < 1 || version > len(r.templates) {
+		return models.PromptTemplate{}, ErrNotFound
+	}
+
+	template := r.templates[version-1]
+	template.Active = template.Version == r.active
+
+	return template, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// MemoryRankingRepository is an in-memory RankingRepository
+type MemoryRankingRepository struct {
+	mu       sync.RWMutex
+	rankings []models.Ranking
+}
+
+func NewMemoryRankingRepository(rankings ...models.Ranking) *MemoryRankingRepository {
+	return &MemoryRankingRepository{rankings: slices.Clone(rankings)}
+}
+
+func (r *MemoryRankingRepository) FindAll(ctx context.Context) ([]models.Ranking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rankings := append([]models.Ranking{}, r.rankings...)
+
+	slices.SortFunc(rankings, func(a, b models.Ranking) int {
+		return a.Ranking_value - b.Ranking_value
+	})
+
+	return rankings, nil
+}
+
+func (r *MemoryRankingRepository) FindByName(ctx context.Context, name string) (models.Ranking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.indexOf(name)
+
+	if i < 0 {
+		return models.Ranking{}, ErrNotFound
+	}
+
+	return r.rankings[i], nil
+}
+
+func (r *MemoryRankingRepository) Insert(ctx context.Context, ranking models.Ranking) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conflicts(ranking, -1) {
+		return ErrDuplicate
+	}
+
+	r.rankings = append(r.rankings, ranking)
+
+	return nil
+}
+
+func (r *MemoryRankingRepository) Update(ctx context.Context, name string, ranking models.Ranking) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(name)
+
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	if r.conflicts(ranking, i) {
+		return ErrDuplicate
+	}
+
+	r.rankings[i] = ranking
+
+	return nil
+}
+
+func (r *MemoryRankingRepository) Delete(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(name)
+
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	r.rankings = slices.Delete(r.rankings, i, i+1)
+
+	return nil
+}
+
+// indexOf returns the position of the ranking, -1 if there is none, must be called with the lock held
+func (r *MemoryRankingRepository) indexOf(name string) int {
+	return slices.IndexFunc(r.rankings, func(ranking models.Ranking) bool {
+		return ranking.Ranking_name == name
+	})
+}
+
+// conflicts tells if another ranking than the one at position skip has the same name or value,
+// or is also the "not ranked" ranking, like the unique indexes of the Mongo collection
+func (r *MemoryRankingRepository) conflicts(ranking models.Ranking, skip int) bool {
+	for i, other := range r.rankings {
+		if i == skip {
+			continue
+		}
+
+		if other.Ranking_name == ranking.Ranking_name || other.Ranking_value == ranking.Ranking_value || (other.Not_ranked && ranking.Not_ranked) {
+			return true
+		}
+	}
+
+	return false
+}
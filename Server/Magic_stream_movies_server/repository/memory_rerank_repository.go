@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// MemoryRerankRepository is an in-memory RerankRepository
+type MemoryRerankRepository struct {
+	mu      sync.Mutex
+	runs    []models.RerankRun
+	changes []models.RerankChange
+}
+
+func NewMemoryRerankRepository() *MemoryRerankRepository {
+	return &MemoryRerankRepository{}
+}
+
+func (r *MemoryRerankRepository) Insert(ctx context.Context, run models.RerankRun) (models.RerankRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run.ID = bson.NewObjectID()
+	run.Created_at = time.Now()
+	run.Updated_at = run.Created_at
+
+	r.runs = append(r.runs, run)
+
+	return run, nil
+}
+
+func (r *MemoryRerankRepository) FindByID(ctx context.Context, runID string) (models.RerankRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, run := range r.runs {
+		if run.ID.Hex() == runID {
+			return run, nil
+		}
+	}
+
+	return models.RerankRun{}, ErrNotFound
+}
+
+func (r *MemoryRerankRepository) FindByStatus(ctx context.Context, status string) ([]models.RerankRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := []models.RerankRun{}
+
+	for _, run := range r.runs {
+		if run.Status == status {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs, nil
+}
+
+func (r *MemoryRerankRepository) SaveProgress(ctx context.Context, run models.RerankRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.runs {
+		if r.runs[i].ID == run.ID {
+			run.Updated_at = time.Now()
+			r.runs[i] = run
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *MemoryRerankRepository) Claim(ctx context.Context, run models.RerankRun, owner string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.runs {
+		if r.runs[i].ID == run.ID {
+			if r.runs[i].Status != models.RerankRunning || r.runs[i].Owner != run.Owner {
+				return false, nil
+			}
+
+			r.runs[i].Owner = owner
+			r.runs[i].Updated_at = time.Now()
+
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *MemoryRerankRepository) SaveChanges(ctx context.Context, changes []models.RerankChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, change := range changes {
+		i := slices.IndexFunc(r.changes, func(existing models.RerankChange) bool {
+			return existing.Run_id == change.Run_id && existing.Imdb_id == change.Imdb_id
+		})
+
+		if i >= 0 {
+			r.changes[i] = change
+		} else {
+			r.changes = append(r.changes, change)
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryRerankRepository) ListChanges(ctx context.Context, runID bson.ObjectID, skip int64, limit int64) ([]models.RerankChange, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	matching := []models.RerankChange{}
+
+	for _, change := range r.changes {
+		if change.Run_id == runID {
+			matching = append(matching, change)
+		}
+	}
+
+	slices.SortFunc(matching, func(a, b models.RerankChange) int {
+		return strings.Compare(a.Imdb_id, b.Imdb_id)
+	})
+
+	total := int64(len(matching))
+	start := min(skip, total)
+	end := min(start+limit, total)
+
+	return matching[start:end], total, nil
+}
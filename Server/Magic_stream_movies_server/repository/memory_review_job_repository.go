@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// MemoryReviewJobRepository is an in-memory ReviewJobRepository, the jobs are lost when the process stops
+type MemoryReviewJobRepository struct {
+	mu   sync.Mutex
+	jobs []models.ReviewJob
+}
+
+func NewMemoryReviewJobRepository() *MemoryReviewJobRepository {
+	return &MemoryReviewJobRepository{}
+}
+
+func (r *MemoryReviewJobRepository) Insert(ctx context.Context, job models.ReviewJob) (models.ReviewJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	job.ID = bson.NewObjectID()
+	job.Status = models.ReviewJobPending
+	job.Next_run_at = now
+	job.Created_at = now
+	job.Updated_at = now
+
+	r.jobs = append(r.jobs, job)
+
+	return job, nil
+}
+
+func (r *MemoryReviewJobRepository) FindByID(ctx context.Context, jobID string) (models.ReviewJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, job := range r.jobs {
+		if job.ID.Hex() == jobID {
+			return job, nil
+		}
+	}
+
+	return models.ReviewJob{}, ErrNotFound
+}
+
+func (r *MemoryReviewJobRepository) Claim(ctx context.Context, now time.Time, lease time.Duration) (models.ReviewJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	best := -1
+
+	for i, job := range r.jobs {
+		runnable := (job.Status == models.ReviewJobPending && !job.Next_run_at.After(now)) ||
+			(job.Status == models.ReviewJobRunning && job.Locked_until != nil && !job.Locked_until.After(now))
+
+		if runnable && (best < 0 || job.Next_run_at.Before(r.jobs[best].Next_run_at)) {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		return models.ReviewJob{}, ErrNotFound
+	}
+
+	lockedUntil := now.Add(lease)
+
+	job := &r.jobs[best]
+	job.Status = models.ReviewJobRunning
+	job.Locked_until = &lockedUntil
+	job.Attempts++
+	job.Updated_at = now
+
+	return *job, nil
+}
+
+func (r *MemoryReviewJobRepository) Succeed(ctx context.Context, jobID bson.ObjectID, ranking models.Ranking, classifier string) error {
+	return r.update(jobID, func(job *models.ReviewJob) {
+		job.Status = models.ReviewJobSucceeded
+		job.Ranking = &ranking
+		job.Classifier = classifier
+		job.Last_error = ""
+	})
+}
+
+func (r *MemoryReviewJobRepository) Retry(ctx context.Context, jobID bson.ObjectID, nextRunAt time.Time, lastError string) error {
+	return r.update(jobID, func(job *models.ReviewJob) {
+		job.Status = models.ReviewJobPending
+		job.Next_run_at = nextRunAt
+		job.Last_error = lastError
+	})
+}
+
+func (r *MemoryReviewJobRepository) Finish(ctx context.Context, jobID bson.ObjectID, status string, lastError string) error {
+	return r.update(jobID, func(job *models.ReviewJob) {
+		job.Status = status
+		job.Last_error = lastError
+	})
+}
+
+// update changes the job and releases its lease
+func (r *MemoryReviewJobRepository) update(jobID bson.ObjectID, change func(job *models.ReviewJob)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.jobs {
+		if r.jobs[i].ID == jobID {
+			change(&r.jobs[i])
+			r.jobs[i].Locked_until = nil
+			r.jobs[i].Updated_at = time.Now()
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
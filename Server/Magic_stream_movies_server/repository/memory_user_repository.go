@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// MemoryUserRepository is an in-memory UserRepository
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users []models.User
+}
+
+func NewMemoryUserRepository(users ...models.User) *MemoryUserRepository {
+	return &MemoryUserRepository{users: slices.Clone(users)}
+}
+
+func (r *MemoryUserRepository) find(match func(models.User) bool) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+
+	return models.User{}, ErrNotFound
+}
+
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.find(func(user models.User) bool { return user.Email == email })
+}
+
+func (r *MemoryUserRepository) FindByID(ctx context.Context, userID string) (models.User, error) {
+	return r.find(func(user models.User) bool { return user.User_ID == userID })
+}
+
+func (r *MemoryUserRepository) CountByEmail(ctx context.Context, email string) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+
+	for _, user := range r.users {
+		if user.Email == email {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *MemoryUserRepository) Insert(ctx context.Context, user models.User) (bson.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user.ID.IsZero() {
+		user.ID = bson.NewObjectID()
+	}
+
+	r.users = append(r.users, user)
+
+	return user.ID, nil
+}
+
+func (r *MemoryUserRepository) UpdateTokens(ctx context.Context, userID string, token string, refreshToken string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Like UpdateOne, updating an unknown user is not an error
+	for i := range r.users {
+		if r.users[i].User_ID == userID {
+			r.users[i].Token = token
+			r.users[i].Refresh_token = refreshToken
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepository) RotateTokens(ctx context.Context, userID string, presented string, token string, refreshToken string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.users, func(user models.User) bool { return user.User_ID == userID })
+
+	if i < 0 || presented == "" || r.users[i].Refresh_token != presented {
+		return false, nil
+	}
+
+	r.users[i].Token = token
+	r.users[i].Refresh_token = refreshToken
+
+	return true, nil
+}
+
+func (r *MemoryUserRepository) SetRole(ctx context.Context, userID string, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.users, func(user models.User) bool { return user.User_ID == userID })
+
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	r.users[i].Role = role
+	r.users[i].Token = ""
+	r.users[i].Refresh_token = ""
+	r.users[i].Updated_at = time.Now()
+
+	return nil
+}
+
+func (r *MemoryUserRepository) RenameGenre(ctx context.Context, genre models.Genre) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+
+	for i := range r.users {
+		// The genres are copied first, the slice may be shared with a user returned earlier
+		genres := slices.Clone(r.users[i].Favourite_genres)
+
+		if renameGenre(genres, genre) {
+			r.users[i].Favourite_genres = genres
+			r.users[i].Updated_at = time.Now()
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *MemoryUserRepository) MergeGenre(ctx context.Context, from int, into models.Genre) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+
+	for i := range r.users {
+		if genres, ok := mergeGenre(r.users[i].Favourite_genres, from, into); ok {
+			r.users[i].Favourite_genres = genres
+			r.users[i].Updated_at = time.Now()
+			count++
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// MemoryUserReviewRepository is an in-memory UserReviewRepository
+type MemoryUserReviewRepository struct {
+	mu      sync.RWMutex
+	reviews []models.UserReview
+}
+
+func NewMemoryUserReviewRepository(reviews ...models.UserReview) *MemoryUserReviewRepository {
+	return &MemoryUserReviewRepository{reviews: slices.Clone(reviews)}
+}
+
+// indexOf returns the position of the review of the user, -1 if there is none, must be called with the lock held
+func (r *MemoryUserReviewRepository) indexOf(userID string, imdbID string) int {
+	return slices.IndexFunc(r.reviews, func(review models.UserReview) bool {
+		return review.User_id == userID && review.Imdb_id == imdbID
+	})
+}
+
+func (r *MemoryUserReviewRepository) Insert(ctx context.Context, review models.UserReview) (models.UserReview, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Same as the unique index on user_id and imdb_id
+	if r.indexOf(review.User_id, review.Imdb_id) >= 0 {
+		return review, ErrDuplicate
+	}
+
+	review.Created_at = time.Now()
+	review.Updated_at = review.Created_at
+	r.reviews = append(r.reviews, review)
+
+	return review, nil
+}
+
+func (r *MemoryUserReviewRepository) Update(ctx context.Context, userID string, imdbID string, rating int, text string) (models.UserReview, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(userID, imdbID)
+
+	if i < 0 {
+		return models.UserReview{}, ErrNotFound
+	}
+
+	r.reviews[i].Rating = rating
+	r.reviews[i].Review = text
+	r.reviews[i].Updated_at = time.Now()
+
+	return r.reviews[i], nil
+}
+
+func (r *MemoryUserReviewRepository) Delete(ctx context.Context, userID string, imdbID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(userID, imdbID)
+
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	r.reviews = slices.Delete(r.reviews, i, i+1)
+
+	return nil
+}
+
+func (r *MemoryUserReviewRepository) ListByMovie(ctx context.Context, imdbID string, skip int64, limit int64) ([]models.UserReview, int64, error) {
+	return r.list(func(review models.UserReview) bool { return review.Imdb_id == imdbID }, skip, limit)
+}
+
+func (r *MemoryUserReviewRepository) ListByUser(ctx context.Context, userID string, skip int64, limit int64) ([]models.UserReview, int64, error) {
+	return r.list(func(review models.UserReview) bool { return review.User_id == userID }, skip, limit)
+}
+
+// list returns a page of the reviews matching the filter, in the order of the Mongo implementation
+func (r *MemoryUserReviewRepository) list(matches func(models.UserReview) bool, skip int64, limit int64) ([]models.UserReview, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matching := []models.UserReview{}
+
+	for _, review := range r.reviews {
+		if matches(review) {
+			matching = append(matching, review)
+		}
+	}
+
+	slices.SortFunc(matching, func(a, b models.UserReview) int {
+		return cmp.Or(b.Updated_at.Compare(a.Updated_at), cmp.Compare(a.User_id, b.User_id), cmp.Compare(a.Imdb_id, b.Imdb_id))
+	})
+
+	total := int64(len(matching))
+	start := min(skip, total)
+	end := min(start+limit, total)
+
+	return matching[start:end], total, nil
+}
+
+func (r *MemoryUserReviewRepository) RatingStats(ctx context.Context, imdbID string) (models.RatingStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var stats models.RatingStats
+	sum := 0
+
+	for _, review := range r.reviews {
+		if review.Imdb_id == imdbID {
+			sum += review.Rating
+			stats.Count++
+		}
+	}
+
+	if stats.Count > 0 {
+		stats.Average = float64(sum) / float64(stats.Count)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// MemoryWatchlistRepository is an in-memory WatchlistRepository, the watchlists are kept by user_id
+type MemoryWatchlistRepository struct {
+	mu         sync.RWMutex
+	watchlists map[string]models.Watchlist
+}
+
+func NewMemoryWatchlistRepository() *MemoryWatchlistRepository {
+	return &MemoryWatchlistRepository{watchlists: map[string]models.Watchlist{}}
+}
+
+func (r *MemoryWatchlistRepository) Add(ctx context.Context, userID string, imdbID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	watchlist := r.watchlists[userID]
+
+	if watchlistIndex(watchlist.Items, imdbID) >= 0 {
+		return false, nil
+	}
+
+	if len(watchlist.Items) >= MaxWatchlistSize {
+		return false, ErrWatchlistFull
+	}
+
+	now := time.Now()
+
+	watchlist.User_id = userID
+	watchlist.Items = append(watchlist.Items, models.WatchlistItem{Imdb_id: imdbID, Added_at: now})
+	watchlist.Version++
+	watchlist.Updated_at = now
+	r.watchlists[userID] = watchlist
+
+	return true, nil
+}
+
+func (r *MemoryWatchlistRepository) Remove(ctx context.Context, userID string, imdbID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	watchlist := r.watchlists[userID]
+	i := watchlistIndex(watchlist.Items, imdbID)
+
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	watchlist.Items = slices.Delete(watchlist.Items, i, i+1)
+	watchlist.Version++
+	watchlist.Updated_at = time.Now()
+	r.watchlists[userID] = watchlist
+
+	return nil
+}
+
+func (r *MemoryWatchlistRepository) List(ctx context.Context, userID string, skip int64, limit int64) ([]models.WatchlistItem, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := r.watchlists[userID].Items
+	total := int64(len(items))
+	start := min(skip, total)
+	end := min(start+limit, total)
+
+	// A copy, the watchlist may change once the lock is released
+	return slices.Clone(items[start:end]), total, nil
+}
+
+func (r *MemoryWatchlistRepository) Move(ctx context.Context, userID string, imdbID string, position int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	watchlist := r.watchlists[userID]
+
+	items, position, err := moveWatchlistItem(watchlist.Items, imdbID, position)
+
+	if err != nil {
+		return 0, err
+	}
+
+	watchlist.Items = items
+	watchlist.Version++
+	watchlist.Updated_at = time.Now()
+	r.watchlists[userID] = watchlist
+
+	return position, nil
+}
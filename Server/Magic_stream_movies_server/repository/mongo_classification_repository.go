@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoClassificationRepository is the ClassificationRepository backed by the "classifications" collection
+type MongoClassificationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoClassificationRepository(collection *mongo.Collection) *MongoClassificationRepository {
+	return &MongoClassificationRepository{collection: collection}
+}
+
+// EnsureIndexes lets Mongo delete the classifications once they expire.
+// The lifetime is stored in each document, so changing CLASSIFICATION_CACHE_TTL does not conflict with the index.
+func (r *MongoClassificationRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	return err
+}
+
+func (r *MongoClassificationRepository) Find(ctx context.Context, key string) (models.Classification, error) {
+	var classification models.Classification
+
+	// The TTL monitor only runs every minute, an expired document may still be there
+	err := r.collection.FindOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$gt": time.Now()}}).Decode(&classification)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return classification, ErrNotFound
+	}
+
+	return classification, err
+}
+
+func (r *MongoClassificationRepository) Save(ctx context.Context, classification models.Classification) error {
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": classification.Key}, classification, options.Replace().SetUpsert(true))
+
+	return err
+}
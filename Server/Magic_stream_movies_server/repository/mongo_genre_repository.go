@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoGenreRepository is the GenreRepository backed by the "genres" collection
+type MongoGenreRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoGenreRepository(collection *mongo.Collection) *MongoGenreRepository {
+	return &MongoGenreRepository{collection: collection}
+}
+
+// EnsureIndexes makes the ids and the names of the genres unique, names are compared ignoring the case
+func (r *MongoGenreRepository) EnsureIndexes(ctx context.Context) error {
+	caseInsensitive := &options.Collation{Locale: "en", Strength: 2}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "genre_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "genre_name", Value: 1}}, Options: options.Index().SetUnique(true).SetCollation(caseInsensitive)},
+	})
+
+	return err
+}
+
+func (r *MongoGenreRepository) FindAll(ctx context.Context) ([]models.Genre, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "genre_id", Value: 1}}))
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	genres := []models.Genre{}
+
+	if err := cursor.All(ctx, &genres); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
+
+func (r *MongoGenreRepository) FindByID(ctx context.Context, genreID int) (models.Genre, error) {
+	var genre models.Genre
+
+	err := r.collection.FindOne(ctx, bson.D{{Key: "genre_id", Value: genreID}}).Decode(&genre)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return genre, ErrNotFound
+	}
+
+	return genre, err
+}
+
+func (r *MongoGenreRepository) Insert(ctx context.Context, genre models.Genre) (models.Genre, error) {
+	if genre.Genre_id == 0 {
+		var last models.Genre
+
+		err := r.collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "genre_id", Value: -1}})).Decode(&last)
+
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return genre, err
+		}
+
+		// Two concurrent inserts may pick the same id, the unique index rejects the second one
+		genre.Genre_id = last.Genre_id + 1
+	}
+
+	_, err := r.collection.InsertOne(ctx, genre)
+
+	if mongo.IsDuplicateKeyError(err) {
+		return genre, ErrDuplicate
+	}
+
+	return genre, err
+}
+
+func (r *MongoGenreRepository) Rename(ctx context.Context, genreID int, name string) (models.Genre, error) {
+	genre := models.Genre{Genre_id: genreID, Genre_name: name}
+
+	result, err := r.collection.UpdateOne(ctx, bson.D{{Key: "genre_id", Value: genreID}}, bson.M{"$set": bson.M{"genre_name": name}})
+
+	if mongo.IsDuplicateKeyError(err) {
+		return genre, ErrDuplicate
+	}
+
+	if err != nil {
+		return genre, err
+	}
+
+	if result.MatchedCount == 0 {
+		return genre, ErrNotFound
+	}
+
+	return genre, nil
+}
+
+func (r *MongoGenreRepository) Delete(ctx context.Context, genreID int) error {
+	result, err := r.collection.DeleteOne(ctx, bson.D{{Key: "genre_id", Value: genreID}})
+
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// mergeEmbeddedGenre replaces the genre from by into in the genre array field of the documents of the collection.
+// The documents already having into only lose from, the others get into in place of from.
+// extra is merged in both updates, to bump a version or a date.
+func mergeEmbeddedGenre(ctx context.Context, collection *mongo.Collection, field string, from int, into models.Genre, extra bson.M) error {
+	withBoth := bson.D{{Key: field + ".genre_id", Value: bson.M{"$all": bson.A{from, into.Genre_id}}}}
+
+	pull := bson.M{"$pull": bson.M{field: bson.M{"genre_id": from}}}
+
+	if _, err := collection.UpdateMany(ctx, withBoth, mergeUpdate(pull, extra)); err != nil {
+		return err
+	}
+
+	replace := bson.M{"$set": bson.M{field + ".$[g]": into}}
+	updateOptions := options.UpdateMany().SetArrayFilters([]any{bson.M{"g.genre_id": from}})
+
+	_, err := collection.UpdateMany(ctx, bson.D{{Key: field + ".genre_id", Value: from}}, mergeUpdate(replace, extra), updateOptions)
+
+	return err
+}
+
+// mergeUpdate adds the operators of extra to the update, the fields of an operator present in both are combined
+func mergeUpdate(update bson.M, extra bson.M) bson.M {
+	for operator, fields := range extra {
+		existing, ok := update[operator].(bson.M)
+
+		if !ok {
+			update[operator] = fields
+			continue
+		}
+
+		for key, value := range fields.(bson.M) {
+			existing[key] = value
+		}
+	}
+
+	return update
+}
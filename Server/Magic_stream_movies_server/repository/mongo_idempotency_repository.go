@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// How long an Idempotency-Key is remembered
+const idempotencyKeyTTL = 24 * time.Hour
+
+// MongoIdempotencyRepository is the IdempotencyRepository backed by the "idempotency_keys" collection
+type MongoIdempotencyRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoIdempotencyRepository(collection *mongo.Collection) *MongoIdempotencyRepository {
+	return &MongoIdempotencyRepository{collection: collection}
+}
+
+// EnsureIndexes makes the key unique per user and lets Mongo expire the records after a day
+func (r *MongoIdempotencyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(idempotencyKeyTTL.Seconds()))},
+	})
+
+	return err
+}
+
+func (r *MongoIdempotencyRepository) Reserve(ctx context.Context, record models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	record.Status = models.IdempotencyInProgress
+	record.Created_at = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, record)
+
+	if err == nil {
+		return nil, nil
+	}
+
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	// The key was already used, a record left in progress by a stopped server is taken over.
+	// The records stored before the leases have none and are taken over too.
+	abandoned := bson.M{
+		"user_id":          record.User_id,
+		"key":              record.Key,
+		"status":           models.IdempotencyInProgress,
+		"lease_expires_at": bson.M{"$not": bson.M{"$gte": record.Created_at}},
+	}
+
+	result, err := r.collection.ReplaceOne(ctx, abandoned, record)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if result.MatchedCount == 1 {
+		return nil, nil
+	}
+
+	// Otherwise return the stored record
+	var existing models.IdempotencyRecord
+
+	if err := r.collection.FindOne(ctx, bson.M{"user_id": record.User_id, "key": record.Key}).Decode(&existing); err != nil {
+		return nil, err
+	}
+
+	return &existing, nil
+}
+
+func (r *MongoIdempotencyRepository) Complete(ctx context.Context, userID string, key string, status int, location string, body []byte) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":            models.IdempotencyCompleted,
+			"response_status":   status,
+			"response_location": location,
+			"response_body":     body,
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userID, "key": key}, update)
+
+	return err
+}
+
+func (r *MongoIdempotencyRepository) Release(ctx context.Context, userID string, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID, "key": key})
+
+	return err
+}
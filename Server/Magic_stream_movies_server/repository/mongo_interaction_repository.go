@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoInteractionRepository is the InteractionRepository backed by the "interactions" collection
+type MongoInteractionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoInteractionRepository(collection *mongo.Collection) *MongoInteractionRepository {
+	return &MongoInteractionRepository{collection: collection}
+}
+
+// EnsureIndexes keeps one interaction per user, movie and kind, the unique index also serves FindByUser.
+// The second index lists the interactions of a kind, most recent first.
+func (r *MongoInteractionRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "imdb_id", Value: 1}, {Key: "kind", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "kind", Value: 1}, {Key: "updated_at", Value: -1}}},
+	})
+
+	return err
+}
+
+func (r *MongoInteractionRepository) Record(ctx context.Context, interaction models.Interaction) error {
+	interaction.Updated_at = time.Now()
+
+	filter := bson.M{"user_id": interaction.User_id, "imdb_id": interaction.Imdb_id, "kind": interaction.Kind}
+
+	_, err := r.collection.ReplaceOne(ctx, filter, interaction, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+func (r *MongoInteractionRepository) Remove(ctx context.Context, userID string, imdbID string, kind string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID, "imdb_id": imdbID, "kind": kind})
+
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *MongoInteractionRepository) FindByUser(ctx context.Context, userID string) ([]models.Interaction, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID})
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	interactions := []models.Interaction{}
+
+	if err := cursor.All(ctx, &interactions); err != nil {
+		return nil, err
+	}
+
+	return interactions, nil
+}
+
+func (r *MongoInteractionRepository) ListByUser(ctx context.Context, userID string, kind string, skip int64, limit int64) ([]models.Interaction, int64, error) {
+	filter := bson.M{"user_id": userID, "kind": kind}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}, {Key: "imdb_id", Value: 1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	interactions := []models.Interaction{}
+
+	if err := cursor.All(ctx, &interactions); err != nil {
+		return nil, 0, err
+	}
+
+	return interactions, total, nil
+}
+
+func (r *MongoInteractionRepository) Stream(ctx context.Context, fn func(models.Interaction) error) error {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetBatchSize(streamBatchSize))
+
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	// Decode one document at a time, only the current batch is held in memory
+	for cursor.Next(ctx) {
+		var interaction models.Interaction
+
+		if err := cursor.Decode(&interaction); err != nil {
+			return err
+		}
+
+		if err := fn(interaction); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
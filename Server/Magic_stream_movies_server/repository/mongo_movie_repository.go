@@ -0,0 +1,648 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoMovieRepository is the MovieRepository backed by the "movies" collection
+type MongoMovieRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoMovieRepository(collection *mongo.Collection) *MongoMovieRepository {
+	return &MongoMovieRepository{collection: collection}
+}
+
+// notDeleted is added to every filter so soft deleted movies are ignored
+var notDeleted = bson.E{Key: "deleted_at", Value: bson.M{"$exists": false}}
+
+// atVersion matches the expected version, movies stored before versioning have no version field and count as version 0
+func atVersion(version int64) bson.E {
+	if version == 0 {
+		return bson.E{Key: "version", Value: bson.M{"$in": bson.A{0, nil}}}
+	}
+	return bson.E{Key: "version", Value: version}
+}
+
+func (r *MongoMovieRepository) FindAll(ctx context.Context) ([]models.Movie, error) {
+	// Every movie that is not deleted
+	cursor, err := r.collection.Find(ctx, bson.D{notDeleted})
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	movies := []models.Movie{}
+
+	if err := cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// Number of movies fetched from the server per round trip while streaming
+const streamBatchSize = 500
+
+func (r *MongoMovieRepository) Stream(ctx context.Context, filter MovieFilter, fn func(models.Movie) error) error {
+	// Sorted on the unique imdb_id index so the export order is stable
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "imdb_id", Value: 1}}).
+		SetBatchSize(streamBatchSize)
+
+	cursor, err := r.collection.Find(ctx, movieFilter(filter), findOptions)
+
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	// Decode one document at a time, only the current batch is held in memory
+	for cursor.Next(ctx) {
+		var movie models.Movie
+
+		if err := cursor.Decode(&movie); err != nil {
+			return err
+		}
+
+		if err := fn(movie); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (r *MongoMovieRepository) FindByImdbID(ctx context.Context, imdbID string) (models.Movie, error) {
+	var movie models.Movie
+
+	err := r.collection.FindOne(ctx, bson.D{{Key: "imdb_id", Value: imdbID}, notDeleted}).Decode(&movie)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return movie, ErrNotFound
+	}
+
+	return movie, err
+}
+
+func (r *MongoMovieRepository) Insert(ctx context.Context, movie models.Movie) (bson.ObjectID, error) {
+	movie.Version = 1
+	movie.Deleted_at = nil
+	// The rating stats only come from the user reviews
+	movie.Rating_average, movie.Rating_count = 0, 0
+	movie.Rating_revision, movie.Rating_stats_revision = 0, 0
+
+	result, err := r.collection.InsertOne(ctx, movie)
+
+	if mongo.IsDuplicateKeyError(err) {
+		return bson.NilObjectID, ErrDuplicate
+	}
+
+	if err != nil {
+		return bson.NilObjectID, err
+	}
+
+	id, _ := result.InsertedID.(bson.ObjectID)
+
+	return id, nil
+}
+
+func (r *MongoMovieRepository) SetPendingReview(ctx context.Context, imdbID string, review string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"admin_review":   review,
+			"ranking_status": models.RankingStatusPending,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	return r.updateMovie(ctx, bson.D{{Key: "imdb_id", Value: imdbID}, notDeleted}, update)
+}
+
+func (r *MongoMovieRepository) UpdateReview(ctx context.Context, imdbID string, review string, ranking models.Ranking, audit models.RankingAudit) error {
+	update := bson.M{
+		"$set": bson.M{
+			"ranking":        ranking,
+			"ranking_audit":  audit,
+			"ranking_status": models.RankingStatusRanked,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	return r.updateMovie(ctx, reviewFilter(imdbID, review), update)
+}
+
+func (r *MongoMovieRepository) FailReview(ctx context.Context, imdbID string, review string) error {
+	update := bson.M{
+		"$set": bson.M{"ranking_status": models.RankingStatusFailed},
+		"$inc": bson.M{"version": 1},
+	}
+
+	return r.updateMovie(ctx, reviewFilter(imdbID, review), update)
+}
+
+// reviewFilter matches the movie only while it still has the admin review being ranked
+func reviewFilter(imdbID string, review string) bson.D {
+	return bson.D{{Key: "imdb_id", Value: imdbID}, {Key: "admin_review", Value: review}, notDeleted}
+}
+
+// updateMovie applies the update to the movie matching the filter, ErrNotFound if there is none
+func (r *MongoMovieRepository) updateMovie(ctx context.Context, filter bson.D, update bson.M) error {
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// reviewedFilter matches the movies having an admin review
+func reviewedFilter() bson.D {
+	return bson.D{{Key: "admin_review", Value: bson.M{"$gt": ""}}, notDeleted}
+}
+
+func (r *MongoMovieRepository) FindReviewed(ctx context.Context, afterImdbID string, limit int64) ([]models.Movie, error) {
+	filter := append(reviewedFilter(), bson.E{Key: "imdb_id", Value: bson.M{"$gt": afterImdbID}})
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "imdb_id", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	movies := []models.Movie{}
+
+	if err := cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+func (r *MongoMovieRepository) CountReviewed(ctx context.Context) (int64, error) {
+	return r.collection.CountDocuments(ctx, reviewedFilter())
+}
+
+func (r *MongoMovieRepository) CountByRanking(ctx context.Context, name string) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.D{{Key: "ranking.ranking_name", Value: name}})
+}
+
+func (r *MongoMovieRepository) RemapRanking(ctx context.Context, from string, ranking models.Ranking) ([]string, error) {
+	filter := bson.D{{Key: "ranking.ranking_name", Value: from}}
+
+	var remapped []string
+
+	if err := r.collection.Distinct(ctx, "imdb_id", filter).Decode(&remapped); err != nil {
+		return nil, err
+	}
+
+	// Only the movies found above, a movie ranked meanwhile keeps its new ranking
+	filter = append(filter, bson.E{Key: "imdb_id", Value: bson.M{"$in": remapped}})
+
+	update := bson.M{
+		"$set": bson.M{"ranking": ranking},
+		"$inc": bson.M{"version": 1},
+	}
+
+	if _, err := r.collection.UpdateMany(ctx, filter, update); err != nil {
+		return nil, err
+	}
+
+	return remapped, nil
+}
+
+func (r *MongoMovieRepository) RenameGenre(ctx context.Context, genre models.Genre) ([]string, error) {
+	filter := bson.D{{Key: "genre.genre_id", Value: genre.Genre_id}}
+
+	var renamed []string
+
+	if err := r.collection.Distinct(ctx, "imdb_id", filter).Decode(&renamed); err != nil {
+		return nil, err
+	}
+
+	update := bson.M{
+		"$set": bson.M{"genre.$[g].genre_name": genre.Genre_name},
+		"$inc": bson.M{"version": 1},
+	}
+
+	updateOptions := options.UpdateMany().SetArrayFilters([]any{bson.M{"g.genre_id": genre.Genre_id}})
+
+	if _, err := r.collection.UpdateMany(ctx, filter, update, updateOptions); err != nil {
+		return nil, err
+	}
+
+	return renamed, nil
+}
+
+func (r *MongoMovieRepository) MergeGenre(ctx context.Context, from int, into models.Genre) ([]string, error) {
+	filter := bson.D{{Key: "genre.genre_id", Value: from}}
+
+	var merged []string
+
+	if err := r.collection.Distinct(ctx, "imdb_id", filter).Decode(&merged); err != nil {
+		return nil, err
+	}
+
+	if err := mergeEmbeddedGenre(ctx, r.collection, "genre", from, into, bson.M{"$inc": bson.M{"version": 1}}); err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+func (r *MongoMovieRepository) FindByGenreIDs(ctx context.Context, genreIDs []int, excludeImdbIDs []string, limit int64) ([]models.Movie, error) {
+	findOptions := options.Find()
+
+	// Lowest ranking value is the best ranking
+	findOptions.SetSort(bson.D{{Key: "ranking.ranking_value", Value: 1}})
+	findOptions.SetLimit(limit)
+
+	filter := bson.D{{Key: "genre.genre_id", Value: bson.M{"$in": genreIDs}}, notDeleted}
+
+	// The limit applies after the exclusion, so the next best movies take the place of the excluded ones
+	if len(excludeImdbIDs) > 0 {
+		filter = append(filter, bson.E{Key: "imdb_id", Value: bson.M{"$nin": excludeImdbIDs}})
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	movies := []models.Movie{}
+
+	if err := cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+func (r *MongoMovieRepository) FindByImdbIDs(ctx context.Context, imdbIDs []string) ([]models.Movie, error) {
+	cursor, err := r.collection.Find(ctx, bson.D{{Key: "imdb_id", Value: bson.M{"$in": imdbIDs}}, notDeleted})
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	movies := []models.Movie{}
+
+	if err := cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+func (r *MongoMovieRepository) NextRatingRevision(ctx context.Context, imdbID string) (int64, error) {
+	var movie models.Movie
+
+	update := bson.M{"$inc": bson.M{"rating_revision": 1}}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"rating_revision": 1})
+
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"imdb_id": imdbID}, update, findOptions).Decode(&movie)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, ErrNotFound
+	}
+
+	return movie.Rating_revision, err
+}
+
+// SetRatingStats does not increment the version, the ratings of the users must not conflict with the edits of the admins
+func (r *MongoMovieRepository) SetRatingStats(ctx context.Context, imdbID string, stats models.RatingStats, revision int64) error {
+	update := bson.M{
+		"$set": bson.M{
+			"rating_average":        stats.Average,
+			"rating_count":          stats.Count,
+			"rating_stats_revision": revision,
+		},
+	}
+
+	// Also matches the movies stored before the revisions, which have none
+	filter := bson.D{
+		{Key: "imdb_id", Value: imdbID},
+		{Key: "rating_stats_revision", Value: bson.M{"$not": bson.M{"$gte": revision}}},
+	}
+
+	return r.updateMovie(ctx, filter, update)
+}
+
+// EnsureIndexes creates the unique index on imdb_id and the indexes used to filter and sort the catalogue,
+// then flags the "not ranked" ranking of the movies stored before the not_ranked flag.
+// Creating the unique index fails if the collection already holds duplicated imdb_id, they must be removed first.
+func (r *MongoMovieRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "imdb_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "title", Value: 1}, {Key: "imdb_id", Value: 1}}},
+		{Keys: bson.D{{Key: "ranking.ranking_value", Value: 1}, {Key: "imdb_id", Value: 1}}},
+		{Keys: bson.D{{Key: "genre.genre_name", Value: 1}}},
+		{Keys: bson.D{{Key: "genre.genre_id", Value: 1}}},
+		{Keys: bson.D{{Key: "ranking.ranking_name", Value: 1}}},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// The movies stored before the not_ranked flag carry the "not ranked" ranking as its legacy value
+	_, err = r.collection.UpdateMany(ctx,
+		bson.D{{Key: "ranking.ranking_value", Value: legacyNotRankedValue}, {Key: "ranking.not_ranked", Value: bson.M{"$exists": false}}},
+		bson.M{"$set": bson.M{"ranking.not_ranked": true}})
+
+	return err
+}
+
+// movieFilter translates the filter of a query to a Mongo filter
+func movieFilter(filter MovieFilter) bson.D {
+	conditions := bson.D{notDeleted}
+
+	if filter.Genre_name != "" && filter.Genre_id != 0 {
+		// Both must match the same embedded genre
+		conditions = append(conditions, bson.E{Key: "genre", Value: bson.M{"$elemMatch": bson.M{"genre_name": filter.Genre_name, "genre_id": filter.Genre_id}}})
+	} else if filter.Genre_name != "" {
+		conditions = append(conditions, bson.E{Key: "genre.genre_name", Value: filter.Genre_name})
+	} else if filter.Genre_id != 0 {
+		conditions = append(conditions, bson.E{Key: "genre.genre_id", Value: filter.Genre_id})
+	}
+
+	ranking := bson.M{}
+
+	if filter.Min_ranking != nil {
+		ranking["$gte"] = *filter.Min_ranking
+	}
+
+	if filter.Max_ranking != nil {
+		ranking["$lte"] = *filter.Max_ranking
+	}
+
+	if len(ranking) > 0 {
+		conditions = append(conditions, bson.E{Key: "ranking.ranking_value", Value: ranking})
+	}
+
+	return conditions
+}
+
+func (r *MongoMovieRepository) List(ctx context.Context, query MovieQuery) (models.MoviePage, error) {
+	page := models.MoviePage{Movies: []models.Movie{}, Limit: query.Limit}
+
+	filter := movieFilter(query.MovieFilter)
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+
+	if err != nil {
+		return page, err
+	}
+
+	page.Total = total
+
+	sortField := "title"
+
+	if query.Sort_by == SortByRanking {
+		sortField = "ranking.ranking_value"
+	}
+
+	direction, operator := 1, "$gt"
+
+	if query.Descending {
+		direction, operator = -1, "$lt"
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: direction}, {Key: "imdb_id", Value: direction}}).
+		// One more movie than requested tells if there is a next page
+		SetLimit(query.Limit + 1)
+
+	pageFilter := filter
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query)
+
+		if err != nil {
+			return page, err
+		}
+
+		var last any = cursor.Title
+
+		if query.Sort_by == SortByRanking {
+			last = cursor.Ranking
+		}
+
+		// Keyset pagination: movies sorted after the last movie of the previous page
+		after := bson.M{"$or": bson.A{
+			bson.M{sortField: bson.M{operator: last}},
+			bson.M{sortField: last, "imdb_id": bson.M{operator: cursor.Imdb_id}},
+		}}
+
+		pageFilter = bson.D{{Key: "$and", Value: bson.A{filter, after}}}
+	} else {
+		page.Page = query.Page
+		findOptions.SetSkip((query.Page - 1) * query.Limit)
+	}
+
+	mongoCursor, err := r.collection.Find(ctx, pageFilter, findOptions)
+
+	if err != nil {
+		return page, err
+	}
+	defer mongoCursor.Close(ctx)
+
+	if err := mongoCursor.All(ctx, &page.Movies); err != nil {
+		return page, err
+	}
+
+	if int64(len(page.Movies)) > query.Limit {
+		page.Movies = page.Movies[:query.Limit]
+		page.Next_cursor = encodeCursor(query, page.Movies[len(page.Movies)-1])
+	}
+
+	return page, nil
+}
+
+// versionedUpdate applies the update to the movie if it is still at expectedVersion.
+// When nothing matches it tells a missing movie from a version conflict.
+func (r *MongoMovieRepository) versionedUpdate(ctx context.Context, imdbID string, expectedVersion int64, update bson.M) (models.Movie, error) {
+	var movie models.Movie
+
+	filter := bson.D{{Key: "imdb_id", Value: imdbID}, notDeleted, atVersion(expectedVersion)}
+
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&movie)
+
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return movie, err
+	}
+
+	if _, err := r.FindByImdbID(ctx, imdbID); err != nil {
+		return movie, err
+	}
+
+	return movie, ErrVersionConflict
+}
+
+func (r *MongoMovieRepository) Replace(ctx context.Context, movie models.Movie, expectedVersion int64) (models.Movie, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"title":       movie.Title,
+			"poster_path": movie.Poster_path,
+			"youtube_id":  movie.YouTube_id,
+			"genre":       movie.Genre,
+			"version":     expectedVersion + 1,
+		},
+	}
+
+	return r.versionedUpdate(ctx, movie.Imbd_id, expectedVersion, update)
+}
+
+func (r *MongoMovieRepository) SoftDelete(ctx context.Context, imdbID string, expectedVersion int64) error {
+	update := bson.M{
+		"$set": bson.M{
+			"deleted_at": time.Now(),
+			"version":    expectedVersion + 1,
+		},
+	}
+
+	_, err := r.versionedUpdate(ctx, imdbID, expectedVersion, update)
+
+	return err
+}
+
+func (r *MongoMovieRepository) Restore(ctx context.Context, imdbID string) (models.Movie, error) {
+	var movie models.Movie
+
+	filter := bson.M{"imdb_id": imdbID, "deleted_at": bson.M{"$exists": true}}
+
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&movie)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return movie, ErrNotFound
+	}
+
+	return movie, err
+}
+
+func (r *MongoMovieRepository) UpsertMany(ctx context.Context, movies []models.Movie) ([]UpsertResult, error) {
+	results := make([]UpsertResult, len(movies))
+
+	if len(movies) == 0 {
+		return results, nil
+	}
+
+	writes := make([]mongo.WriteModel, len(movies))
+
+	for i, movie := range movies {
+		set := bson.M{"title": movie.Title, "genre": movie.Genre}
+		setOnInsert := bson.M{}
+
+		// A row without review or ranking keeps the ones of an existing movie, a new movie gets them empty and "not ranked"
+		if movie.Admin_review != "" {
+			set["admin_review"] = movie.Admin_review
+		} else {
+			setOnInsert["admin_review"] = movie.Admin_review
+		}
+
+		if !movie.Ranking.Not_ranked {
+			set["ranking"] = movie.Ranking
+		} else {
+			setOnInsert["ranking"] = movie.Ranking
+		}
+
+		// A placeholder only fills a new movie, the poster and trailer of an existing one are kept
+		placeholderField(set, setOnInsert, "poster_path", movie.Poster_path, models.PlaceholderPosterPath)
+		placeholderField(set, setOnInsert, "youtube_id", movie.YouTube_id, models.PlaceholderYouTubeID)
+
+		update := bson.M{
+			"$set":         set,
+			"$setOnInsert": setOnInsert,
+			// Sets version 1 on insert
+			"$inc": bson.M{"version": 1},
+		}
+
+		// A soft deleted movie is not matched, the insert then hits the unique index on imdb_id
+		filter := bson.D{{Key: "imdb_id", Value: movie.Imbd_id}, notDeleted}
+
+		writes[i] = mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true)
+	}
+
+	// Unordered, a failed movie does not stop the next ones
+	result, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+
+	var bulkErr mongo.BulkWriteException
+
+	if err != nil && (!errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil) {
+		return nil, err
+	}
+
+	for _, writeErr := range bulkErr.WriteErrors {
+		results[writeErr.Index].Err = writeErr
+
+		if mongo.IsDuplicateKeyError(writeErr.WriteError) {
+			results[writeErr.Index].Err = ErrDuplicate
+		}
+	}
+
+	for i := range result.UpsertedIDs {
+		results[i].Created = true
+	}
+
+	return results, nil
+}
+
+// placeholderField sets the field on insert only when its value is the placeholder, on every upsert otherwise
+func placeholderField(set bson.M, setOnInsert bson.M, field string, value string, placeholder string) {
+	if value == placeholder {
+		setOnInsert[field] = value
+	} else {
+		set[field] = value
+	}
+}
+
+func (r *MongoMovieRepository) FindGenres(ctx context.Context) ([]models.Genre, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{notDeleted}}},
+		{{Key: "$unwind", Value: "$genre"}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{"genre_id": "$genre.genre_id", "genre_name": "$genre.genre_name"}}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$_id"}}},
+		{{Key: "$sort", Value: bson.M{"genre_id": 1}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	genres := []models.Genre{}
+
+	if err := cursor.All(ctx, &genres); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// _id of the document of the "settings" collection holding the active prompt template version
+const activePromptTemplateSetting = "active_prompt_template"
+
+// MongoPromptTemplateRepository is the PromptTemplateRepository backed by the "prompt_templates" collection,
+// the active version is pointed to by a document of the "settings" collection
+type MongoPromptTemplateRepository struct {
+	templates *mongo.Collection
+	settings  *mongo.Collection
+}
+
+func NewMongoPromptTemplateRepository(templates *mongo.Collection, settings *mongo.Collection) *MongoPromptTemplateRepository {
+	return &MongoPromptTemplateRepository{templates: templates, settings: settings}
+}
+
+// EnsureIndexes makes the versions unique
+func (r *MongoPromptTemplateRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.templates.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return err
+}
+
+func (r *MongoPromptTemplateRepository) Insert(ctx context.Context, template models.PromptTemplate) (models.PromptTemplate, error) {
+	template.Created_at = time.Now()
+
+	// Two concurrent inserts may pick the same version, the loser takes the next one
+	for {
+		var last models.PromptTemplate
+
+		err := r.templates.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})).Decode(&last)
+
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return template, err
+		}
+
+		template.Version = last.Version + 1
+
+		_, err = r.templates.InsertOne(ctx, template)
+
+		if !mongo.IsDuplicateKeyError(err) {
+			return template, err
+		}
+	}
+}
+
+func (r *MongoPromptTemplateRepository) FindAll(ctx context.Context) ([]models.PromptTemplate, error) {
+	cursor, err := r.templates.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "version", Value: -1}}))
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	templates := []models.PromptTemplate{}
+
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, err
+	}
+
+	active, err := r.activeVersion(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range templates {
+		templates[i].Active = templates[i].Version == active
+	}
+
+	return templates, nil
+}
+
+func (r *MongoPromptTemplateRepository) FindByVersion(ctx context.Context, version int) (models.PromptTemplate, error) {
+	var template models.PromptTemplate
+
+	err := r.templates.FindOne(ctx, bson.D{{Key: "version", Value: version}}).Decode(&template)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return template, ErrNotFound
+	}
+
+	if err != nil {
+		return template, err
+	}
+
+	active, err := r.activeVersion(ctx)
+	template.Active = template.Version == active
+
+	return template, err
+}
+
+func (r *MongoPromptTemplateRepository) FindActive(ctx context.Context) (models.PromptTemplate, error) {
+	active, err := r.activeVersion(ctx)
+
+	if err != nil {
+		return models.PromptTemplate{}, err
+	}
+
+	if active == 0 {
+		return models.PromptTemplate{}, ErrNotFound
+	}
+
+	return r.FindByVersion(ctx, active)
+}
+
+func (r *MongoPromptTemplateRepository) Activate(ctx context.Context, version int) error {
+	count, err := r.templates.CountDocuments(ctx, bson.D{{Key: "version", Value: version}})
+
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	_, err = r.settings.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: activePromptTemplateSetting}},
+		bson.M{"$set": bson.M{"version": version, "updated_at": time.Now()}},
+		options.UpdateOne().SetUpsert(true))
+
+	return err
+}
+
+// activeVersion returns the active version, 0 when none was activated
+func (r *MongoPromptTemplateRepository) activeVersion(ctx context.Context) (int, error) {
+	var setting struct {
+		Version int `bson:"version"`
+	}
+
+	err := r.settings.FindOne(ctx, bson.D{{Key: "_id", Value: activePromptTemplateSetting}}).Decode(&setting)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+
+	return setting.Version, err
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Value of the "not ranked" ranking before the not_ranked flag existed
+const legacyNotRankedValue = 999
+
+// MongoRankingRepository is the RankingRepository backed by the "rankings" collection
+type MongoRankingRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRankingRepository(collection *mongo.Collection) *MongoRankingRepository {
+	return &MongoRankingRepository{collection: collection}
+}
+
+// EnsureIndexes flags the legacy "not ranked" ranking, then makes the names and values unique
+// and allows a single "not ranked" ranking. Creating the indexes fails if the collection already holds duplicates.
+func (r *MongoRankingRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.D{{Key: "ranking_value", Value: legacyNotRankedValue}, {Key: "not_ranked", Value: bson.M{"$exists": false}}},
+		bson.M{"$set": bson.M{"not_ranked": true}})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ranking_name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "ranking_value", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{
+			Keys:    bson.D{{Key: "not_ranked", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"not_ranked": true}),
+		},
+	})
+
+	return err
+}
+
+func (r *MongoRankingRepository) FindAll(ctx context.Context) ([]models.Ranking, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "ranking_value", Value: 1}}))
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rankings := []models.Ranking{}
+
+	if err := cursor.All(ctx, &rankings); err != nil {
+		return nil, err
+	}
+
+	return rankings, nil
+}
+
+func (r *MongoRankingRepository) FindByName(ctx context.Context, name string) (models.Ranking, error) {
+	var ranking models.Ranking
+
+	err := r.collection.FindOne(ctx, bson.D{{Key: "ranking_name", Value: name}}).Decode(&ranking)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ranking, ErrNotFound
+	}
+
+	return ranking, err
+}
+
+func (r *MongoRankingRepository) Insert(ctx context.Context, ranking models.Ranking) error {
+	_, err := r.collection.InsertOne(ctx, ranking)
+
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+
+	return err
+}
+
+func (r *MongoRankingRepository) Update(ctx context.Context, name string, ranking models.Ranking) error {
+	result, err := r.collection.ReplaceOne(ctx, bson.D{{Key: "ranking_name", Value: name}}, ranking)
+
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *MongoRankingRepository) Delete(ctx context.Context, name string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.D{{Key: "ranking_name", Value: name}})
+
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
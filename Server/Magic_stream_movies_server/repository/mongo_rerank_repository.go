@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoRerankRepository is the RerankRepository backed by the "rerank_runs" and "rerank_changes" collections
+type MongoRerankRepository struct {
+	runs    *mongo.Collection
+	changes *mongo.Collection
+}
+
+func NewMongoRerankRepository(runs *mongo.Collection, changes *mongo.Collection) *MongoRerankRepository {
+	return &MongoRerankRepository{runs: runs, changes: changes}
+}
+
+// EnsureIndexes keeps a single change per movie and run, listed in imdb_id order
+func (r *MongoRerankRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.changes.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "run_id", Value: 1}, {Key: "imdb_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return err
+}
+
+func (r *MongoRerankRepository) Insert(ctx context.Context, run models.RerankRun) (models.RerankRun, error) {
+	run.ID = bson.NewObjectID()
+	run.Created_at = time.Now()
+	run.Updated_at = run.Created_at
+
+	_, err := r.runs.InsertOne(ctx, run)
+
+	return run, err
+}
+
+func (r *MongoRerankRepository) FindByID(ctx context.Context, runID string) (models.RerankRun, error) {
+	var run models.RerankRun
+
+	id, err := bson.ObjectIDFromHex(runID)
+
+	if err != nil {
+		return run, ErrNotFound
+	}
+
+	err = r.runs.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&run)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return run, ErrNotFound
+	}
+
+	return run, err
+}
+
+func (r *MongoRerankRepository) FindByStatus(ctx context.Context, status string) ([]models.RerankRun, error) {
+	cursor, err := r.runs.Find(ctx, bson.D{{Key: "status", Value: status}})
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	runs := []models.RerankRun{}
+
+	if err := cursor.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+func (r *MongoRerankRepository) SaveProgress(ctx context.Context, run models.RerankRun) error {
+	run.Updated_at = time.Now()
+
+	_, err := r.runs.ReplaceOne(ctx, bson.D{{Key: "_id", Value: run.ID}}, run)
+
+	return err
+}
+
+func (r *MongoRerankRepository) Claim(ctx context.Context, run models.RerankRun, owner string) (bool, error) {
+	var previousOwner any = run.Owner
+
+	// The runs stored before they had an owner have no owner field
+	if run.Owner == "" {
+		previousOwner = bson.D{{Key: "$in", Value: bson.A{"", nil}}}
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: run.ID},
+		{Key: "status", Value: models.RerankRunning},
+		{Key: "owner", Value: previousOwner},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "owner", Value: owner},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+
+	result, err := r.runs.UpdateOne(ctx, filter, update)
+
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+func (r *MongoRerankRepository) SaveChanges(ctx context.Context, changes []models.RerankChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, len(changes))
+
+	for i, change := range changes {
+		writes[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{Key: "run_id", Value: change.Run_id}, {Key: "imdb_id", Value: change.Imdb_id}}).
+			SetReplacement(change).
+			SetUpsert(true)
+	}
+
+	_, err := r.changes.BulkWrite(ctx, writes)
+
+	return err
+}
+
+func (r *MongoRerankRepository) ListChanges(ctx context.Context, runID bson.ObjectID, skip int64, limit int64) ([]models.RerankChange, int64, error) {
+	filter := bson.D{{Key: "run_id", Value: runID}}
+
+	total, err := r.changes.CountDocuments(ctx, filter)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "imdb_id", Value: 1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	cursor, err := r.changes.Find(ctx, filter, findOptions)
+
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	changes := []models.RerankChange{}
+
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, 0, err
+	}
+
+	return changes, total, nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoReviewJobRepository is the ReviewJobRepository backed by the "review_jobs" collection.
+// Claim is a single atomic update, so several servers can share the queue.
+type MongoReviewJobRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoReviewJobRepository(collection *mongo.Collection) *MongoReviewJobRepository {
+	return &MongoReviewJobRepository{collection: collection}
+}
+
+// EnsureIndexes creates the index used by Claim to find the runnable jobs
+func (r *MongoReviewJobRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_run_at", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "locked_until", Value: 1}}},
+	})
+
+	return err
+}
+
+func (r *MongoReviewJobRepository) Insert(ctx context.Context, job models.ReviewJob) (models.ReviewJob, error) {
+	now := time.Now()
+
+	job.ID = bson.NewObjectID()
+	job.Status = models.ReviewJobPending
+	job.Next_run_at = now
+	job.Created_at = now
+	job.Updated_at = now
+
+	_, err := r.collection.InsertOne(ctx, job)
+
+	return job, err
+}
+
+func (r *MongoReviewJobRepository) FindByID(ctx context.Context, jobID string) (models.ReviewJob, error) {
+	var job models.ReviewJob
+
+	id, err := bson.ObjectIDFromHex(jobID)
+
+	if err != nil {
+		return job, ErrNotFound
+	}
+
+	err = r.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&job)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return job, ErrNotFound
+	}
+
+	return job, err
+}
+
+func (r *MongoReviewJobRepository) Claim(ctx context.Context, now time.Time, lease time.Duration) (models.ReviewJob, error) {
+	var job models.ReviewJob
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.ReviewJobPending, "next_run_at": bson.M{"$lte": now}},
+		bson.M{"status": models.ReviewJobRunning, "locked_until": bson.M{"$lte": now}},
+	}}
+
+	update := bson.M{
+		"$set": bson.M{"status": models.ReviewJobRunning, "locked_until": now.Add(lease), "updated_at": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_run_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&job)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return job, ErrNotFound
+	}
+
+	return job, err
+}
+
+func (r *MongoReviewJobRepository) Succeed(ctx context.Context, jobID bson.ObjectID, ranking models.Ranking, classifier string) error {
+	return r.update(ctx, jobID, bson.M{
+		"status":     models.ReviewJobSucceeded,
+		"ranking":    ranking,
+		"classifier": classifier,
+		"last_error": "",
+	})
+}
+
+func (r *MongoReviewJobRepository) Retry(ctx context.Context, jobID bson.ObjectID, nextRunAt time.Time, lastError string) error {
+	return r.update(ctx, jobID, bson.M{
+		"status":      models.ReviewJobPending,
+		"next_run_at": nextRunAt,
+		"last_error":  lastError,
+	})
+}
+
+func (r *MongoReviewJobRepository) Finish(ctx context.Context, jobID bson.ObjectID, status string, lastError string) error {
+	return r.update(ctx, jobID, bson.M{"status": status, "last_error": lastError})
+}
+
+// update sets the fields and releases the lease of the job
+func (r *MongoReviewJobRepository) update(ctx context.Context, jobID bson.ObjectID, fields bson.M) error {
+	fields["updated_at"] = time.Now()
+
+	_, err := r.collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: jobID}}, bson.M{
+		"$set":   fields,
+		"$unset": bson.M{"locked_until": ""},
+	})
+
+	return err
+}
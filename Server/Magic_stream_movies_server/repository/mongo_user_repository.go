@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoUserRepository is the UserRepository backed by the "users" collection
+type MongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserRepository(collection *mongo.Collection) *MongoUserRepository {
+	return &MongoUserRepository{collection: collection}
+}
+
+func (r *MongoUserRepository) findOne(ctx context.Context, filter bson.M) (models.User, error) {
+	var user models.User
+
+	err := r.collection.FindOne(ctx, filter).Decode(&user)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return user, ErrNotFound
+	}
+
+	return user, err
+}
+
+func (r *MongoUserRepository) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.findOne(ctx, bson.M{"email": email})
+}
+
+func (r *MongoUserRepository) FindByID(ctx context.Context, userID string) (models.User, error) {
+	return r.findOne(ctx, bson.M{"user_id": userID})
+}
+
+func (r *MongoUserRepository) CountByEmail(ctx context.Context, email string) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"email": email})
+}
+
+func (r *MongoUserRepository) Insert(ctx context.Context, user models.User) (bson.ObjectID, error) {
+	result, err := r.collection.InsertOne(ctx, user)
+
+	if err != nil {
+		return bson.NilObjectID, err
+	}
+
+	id, _ := result.InsertedID.(bson.ObjectID)
+
+	return id, nil
+}
+
+func (r *MongoUserRepository) UpdateTokens(ctx context.Context, userID string, token string, refreshToken string) error {
+	updateData := bson.M{
+		"$set": bson.M{
+			"token":         token,
+			"refresh_token": refreshToken,
+			"updated_at":    time.Now(),
+		},
+		// Older versions wrote the date to a misspelled field
+		"$unset": bson.M{"update_at": ""},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userID}, updateData)
+
+	return err
+}
+
+func (r *MongoUserRepository) RotateTokens(ctx context.Context, userID string, presented string, token string, refreshToken string) (bool, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"token":         token,
+			"refresh_token": refreshToken,
+			"updated_at":    time.Now(),
+		},
+		"$unset": bson.M{"update_at": ""},
+	}
+
+	// Two requests presenting the same refresh token can not both match, the second one sees the rotated token
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userID, "refresh_token": presented}, update)
+
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+func (r *MongoUserRepository) SetRole(ctx context.Context, userID string, role string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"role":          role,
+			"token":         "",
+			"refresh_token": "",
+			"updated_at":    time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userID}, update)
+
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *MongoUserRepository) RenameGenre(ctx context.Context, genre models.Genre) (int64, error) {
+	update := bson.M{"$set": bson.M{"favourite_genres.$[g].genre_name": genre.Genre_name, "updated_at": time.Now()}}
+	updateOptions := options.UpdateMany().SetArrayFilters([]any{bson.M{"g.genre_id": genre.Genre_id}})
+
+	result, err := r.collection.UpdateMany(ctx, bson.M{"favourite_genres.genre_id": genre.Genre_id}, update, updateOptions)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+func (r *MongoUserRepository) MergeGenre(ctx context.Context, from int, into models.Genre) (int64, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"favourite_genres.genre_id": from})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, mergeEmbeddedGenre(ctx, r.collection, "favourite_genres", from, into, bson.M{"$set": bson.M{"updated_at": time.Now()}})
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoUserReviewRepository is the UserReviewRepository backed by the "user_reviews" collection
+type MongoUserReviewRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserReviewRepository(collection *mongo.Collection) *MongoUserReviewRepository {
+	return &MongoUserReviewRepository{collection: collection}
+}
+
+// EnsureIndexes keeps one review per user and movie, the other indexes list the reviews of a movie and of a user
+func (r *MongoUserReviewRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "imdb_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "imdb_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+	})
+
+	return err
+}
+
+func (r *MongoUserReviewRepository) Insert(ctx context.Context, review models.UserReview) (models.UserReview, error) {
+	review.Created_at = time.Now()
+	review.Updated_at = review.Created_at
+
+	_, err := r.collection.InsertOne(ctx, review)
+
+	// The unique index on user_id and imdb_id rejects a second review
+	if mongo.IsDuplicateKeyError(err) {
+		return review, ErrDuplicate
+	}
+
+	return review, err
+}
+
+func (r *MongoUserReviewRepository) Update(ctx context.Context, userID string, imdbID string, rating int, text string) (models.UserReview, error) {
+	var review models.UserReview
+
+	update := bson.M{
+		"$set": bson.M{
+			"rating":     rating,
+			"review":     text,
+			"updated_at": time.Now(),
+		},
+	}
+
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"user_id": userID, "imdb_id": imdbID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&review)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return review, ErrNotFound
+	}
+
+	return review, err
+}
+
+func (r *MongoUserReviewRepository) Delete(ctx context.Context, userID string, imdbID string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID, "imdb_id": imdbID})
+
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *MongoUserReviewRepository) ListByMovie(ctx context.Context, imdbID string, skip int64, limit int64) ([]models.UserReview, int64, error) {
+	return r.list(ctx, bson.M{"imdb_id": imdbID}, skip, limit)
+}
+
+func (r *MongoUserReviewRepository) ListByUser(ctx context.Context, userID string, skip int64, limit int64) ([]models.UserReview, int64, error) {
+	return r.list(ctx, bson.M{"user_id": userID}, skip, limit)
+}
+
+// list returns a page of the reviews matching the filter, most recently updated first, and their total number
+func (r *MongoUserReviewRepository) list(ctx context.Context, filter bson.M, skip int64, limit int64) ([]models.UserReview, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, filter)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}, {Key: "user_id", Value: 1}, {Key: "imdb_id", Value: 1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	reviews := []models.UserReview{}
+
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, 0, err
+	}
+
+	return reviews, total, nil
+}
+
+func (r *MongoUserReviewRepository) RatingStats(ctx context.Context, imdbID string) (models.RatingStats, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"imdb_id": imdbID}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "average": bson.M{"$avg": "$rating"}, "count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+
+	if err != nil {
+		return models.RatingStats{}, err
+	}
+	defer cursor.Close(ctx)
+
+	// A movie without reviews gives no group at all
+	stats := []models.RatingStats{}
+
+	if err := cursor.All(ctx, &stats); err != nil || len(stats) == 0 {
+		return models.RatingStats{}, err
+	}
+
+	return stats[0], nil
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// MongoWatchlistRepository is the WatchlistRepository backed by the "watchlists" collection,
+// the _id of a watchlist is the user_id so no other index is needed
+type MongoWatchlistRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoWatchlistRepository(collection *mongo.Collection) *MongoWatchlistRepository {
+	return &MongoWatchlistRepository{collection: collection}
+}
+
+func (r *MongoWatchlistRepository) Add(ctx context.Context, userID string, imdbID string) (bool, error) {
+	now := time.Now()
+
+	// Only pushed when the movie is missing and there is room left
+	filter := bson.M{
+		"_id":           userID,
+		"items.imdb_id": bson.M{"$ne": imdbID},
+		"items." + strconv.Itoa(MaxWatchlistSize-1): bson.M{"$exists": false},
+	}
+
+	update := bson.M{
+		"$push": bson.M{"items": models.WatchlistItem{Imdb_id: imdbID, Added_at: now}},
+		"$set":  bson.M{"updated_at": now},
+		"$inc":  bson.M{"version": 1},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+
+	if err != nil || result.MatchedCount > 0 {
+		return err == nil, err
+	}
+
+	watchlist, err := r.find(ctx, userID)
+
+	if errors.Is(err, ErrNotFound) {
+		// First movie of the user, a concurrent first add hits the _id and is sent again
+		_, err = r.collection.InsertOne(ctx, models.Watchlist{User_id: userID, Items: []models.WatchlistItem{{Imdb_id: imdbID, Added_at: now}}, Version: 1, Updated_at: now})
+
+		if mongo.IsDuplicateKeyError(err) {
+			return r.Add(ctx, userID, imdbID)
+		}
+
+		return err == nil, err
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if watchlistIndex(watchlist.Items, imdbID) >= 0 {
+		return false, nil
+	}
+
+	return false, ErrWatchlistFull
+}
+
+func (r *MongoWatchlistRepository) Remove(ctx context.Context, userID string, imdbID string) error {
+	update := bson.M{
+		"$pull": bson.M{"items": bson.M{"imdb_id": imdbID}},
+		"$set":  bson.M{"updated_at": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID, "items.imdb_id": imdbID}, update)
+
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *MongoWatchlistRepository) List(ctx context.Context, userID string, skip int64, limit int64) ([]models.WatchlistItem, int64, error) {
+	// Only the page of the items is sent back, with the size of the whole watchlist
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": userID}}},
+		{{Key: "$project", Value: bson.M{
+			"total": bson.M{"$size": "$items"},
+			"items": bson.M{"$slice": bson.A{"$items", skip, limit}},
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var pages []struct {
+		Total int64                  `bson:"total"`
+		Items []models.WatchlistItem `bson:"items"`
+	}
+
+	if err := cursor.All(ctx, &pages); err != nil {
+		return nil, 0, err
+	}
+
+	// A user who never added a movie has no watchlist
+	if len(pages) == 0 {
+		return []models.WatchlistItem{}, 0, nil
+	}
+
+	return pages[0].Items, pages[0].Total, nil
+}
+
+// Move reads the watchlist and writes the new order only if the version did not change meanwhile
+func (r *MongoWatchlistRepository) Move(ctx context.Context, userID string, imdbID string, position int) (int, error) {
+	watchlist, err := r.find(ctx, userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	items, position, err := moveWatchlistItem(watchlist.Items, imdbID, position)
+
+	if err != nil {
+		return 0, err
+	}
+
+	update := bson.M{
+		"$set": bson.M{"items": items, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID, "version": watchlist.Version}, update)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if result.MatchedCount == 0 {
+		return 0, ErrVersionConflict
+	}
+
+	return position, nil
+}
+
+// find returns the watchlist of the user or ErrNotFound
+func (r *MongoWatchlistRepository) find(ctx context.Context, userID string) (models.Watchlist, error) {
+	var watchlist models.Watchlist
+
+	err := r.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&watchlist)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return watchlist, ErrNotFound
+	}
+
+	return watchlist, err
+}
+
+// watchlistIndex returns the position of the movie in the items, -1 if it is not in them
+func watchlistIndex(items []models.WatchlistItem, imdbID string) int {
+	return slices.IndexFunc(items, func(item models.WatchlistItem) bool { return item.Imdb_id == imdbID })
+}
+
+// moveWatchlistItem returns a copy of the items with the movie at the position, the last one when it is past the end,
+// and the position it got. ErrNotFound if the movie is not in the items. It is shared by the Mongo and memory repositories.
+func moveWatchlistItem(items []models.WatchlistItem, imdbID string, position int) ([]models.WatchlistItem, int, error) {
+	i := watchlistIndex(items, imdbID)
+
+	if i < 0 {
+		return nil, 0, ErrNotFound
+	}
+
+	item := items[i]
+	moved := slices.Delete(slices.Clone(items), i, i+1)
+	position = min(max(position, 0), len(moved))
+
+	return slices.Insert(moved, position, item), position, nil
+}
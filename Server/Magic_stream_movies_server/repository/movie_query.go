@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// ErrInvalidCursor is returned when the cursor of a MovieQuery can not be decoded
+// or was issued for another sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Sort orders supported by MovieRepository.List
+const (
+	SortByTitle   = "title"
+	SortByRanking = "ranking"
+)
+
+// MovieFilter selects movies of the catalogue, zero values mean "no filter"
+type MovieFilter struct {
+	Genre_name  string
+	Genre_id    int
+	Min_ranking *int
+	Max_ranking *int
+}
+
+// MovieQuery describes one page of movies.
+// When Cursor is set the page starts right after the movie it was issued for and Page is ignored.
+type MovieQuery struct {
+	MovieFilter
+	Sort_by    string
+	Descending bool
+	Page       int64
+	Limit      int64
+	Cursor     string
+}
+
+// movieCursor is the content of the opaque cursor: the sort values of the last movie of a page.
+// imdb_id breaks the ties between movies with the same title or ranking.
+type movieCursor struct {
+	Sort_by    string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Title      string `json:"t,omitempty"`
+	Ranking    int    `json:"r,omitempty"`
+	Imdb_id    string `json:"i"`
+}
+
+func encodeCursor(query MovieQuery, movie models.Movie) string {
+	cursor := movieCursor{Sort_by: query.Sort_by, Descending: query.Descending, Imdb_id: movie.Imbd_id}
+
+	if query.Sort_by == SortByRanking {
+		cursor.Ranking = movie.Ranking.Ranking_value
+	} else {
+		cursor.Title = movie.Title
+	}
+
+	raw, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(query MovieQuery) (movieCursor, error) {
+	var cursor movieCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+
+	if err != nil || json.Unmarshal(raw, &cursor) != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	if cursor.Sort_by != query.Sort_by || cursor.Descending != query.Descending || cursor.Imdb_id == "" {
+		return cursor, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// matches applies the filter to a movie, used by the in-memory implementation
+func (f MovieFilter) matches(movie models.Movie) bool {
+	if f.Genre_name != "" || f.Genre_id != 0 {
+		found := false
+
+		for _, genre := range movie.Genre {
+			if (f.Genre_name == "" || genre.Genre_name == f.Genre_name) &&
+				(f.Genre_id == 0 || genre.Genre_id == f.Genre_id) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if f.Min_ranking != nil && movie.Ranking.Ranking_value < *f.Min_ranking {
+		return false
+	}
+
+	if f.Max_ranking != nil && movie.Ranking.Ranking_value > *f.Max_ranking {
+		return false
+	}
+
+	return true
+}
+
+// compareMovies orders two movies like the Mongo sort of List, before applying Descending
+func compareMovies(sortBy string, a, b models.Movie) int {
+	if sortBy == SortByRanking {
+		if a.Ranking.Ranking_value != b.Ranking.Ranking_value {
+			if a.Ranking.Ranking_value < b.Ranking.Ranking_value {
+				return -1
+			}
+			return 1
+		}
+	} else if c := strings.Compare(a.Title, b.Title); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.Imbd_id, b.Imbd_id)
+}
@@ -0,0 +1,384 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ErrNotFound is returned when no document matches the lookup or the update filter
+var ErrNotFound = errors.New("document not found")
+
+// ErrDuplicate is returned when an insert would break a unique index, e.g. a second movie with the same imdb_id
+var ErrDuplicate = errors.New("document already exists")
+
+// ErrVersionConflict is returned when a document was changed since the version an update is based on
+var ErrVersionConflict = errors.New("document was modified by another request")
+
+// ErrWatchlistFull is returned when a movie is added to a watchlist already holding MaxWatchlistSize movies
+var ErrWatchlistFull = errors.New("watchlist is full")
+
+// Number of movies a watchlist can hold, it keeps the watchlist document of a user small
+const MaxWatchlistSize = 500
+
+// UpsertResult is the outcome of the upsert of one movie by MovieRepository.UpsertMany
+type UpsertResult struct {
+	Created bool
+	Err     error
+}
+
+// MovieRepository gives access to the "movies" collection.
+// Soft deleted movies are ignored by every method except Restore.
+type MovieRepository interface {
+	// FindAll returns every movie of the catalogue
+	FindAll(ctx context.Context) ([]models.Movie, error)
+	// List returns one page of the movies matching the query and the total number of matching movies
+	List(ctx context.Context, query MovieQuery) (models.MoviePage, error)
+	// Stream calls fn for every movie matching the filter, in imdb_id order, without loading them all in memory.
+	// It stops at the first error returned by fn and returns it.
+	Stream(ctx context.Context, filter MovieFilter, fn func(models.Movie) error) error
+	// FindByImdbID returns the movie with the given imdb_id or ErrNotFound
+	FindByImdbID(ctx context.Context, imdbID string) (models.Movie, error)
+	// Insert adds a movie at version 1 and returns the id of the new document,
+	// ErrDuplicate if a movie (even soft deleted) already has this imdb_id
+	Insert(ctx context.Context, movie models.Movie) (bson.ObjectID, error)
+	// Replace stores the title, poster, trailer and genres of the movie if it is still at expectedVersion
+	// and returns the updated movie. ErrVersionConflict if it changed meanwhile, ErrNotFound if it does not exist.
+	Replace(ctx context.Context, movie models.Movie, expectedVersion int64) (models.Movie, error)
+	// SoftDelete hides the movie if it is still at expectedVersion, same errors as Replace
+	SoftDelete(ctx context.Context, imdbID string, expectedVersion int64) error
+	// Restore brings back a soft deleted movie, ErrNotFound if there is no deleted movie with this imdb_id
+	Restore(ctx context.Context, imdbID string) (models.Movie, error)
+	// UpsertMany inserts every movie, or updates the title, poster, trailer, genres, admin review and ranking of the movie
+	// with the same imdb_id, in a single round trip. An empty admin review, the "not ranked" ranking and the placeholder
+	// poster and trailer (models.PlaceholderPosterPath, models.PlaceholderYouTubeID) are only set on insert. The results
+	// follow the order of the movies, a movie whose existing copy is soft deleted gets ErrDuplicate. The error is for a
+	// write that failed as a whole.
+	UpsertMany(ctx context.Context, movies []models.Movie) ([]UpsertResult, error)
+	// FindGenres returns the distinct genres used by the movies of the catalogue
+	FindGenres(ctx context.Context) ([]models.Genre, error)
+	// SetPendingReview stores the admin review with the ranking status pending, its ranking is set later by UpdateReview.
+	// ErrNotFound if the movie does not exist.
+	SetPendingReview(ctx context.Context, imdbID string, review string) error
+	// UpdateReview sets the ranking of the admin review, how it was chosen and the ranking status ranked.
+	// ErrNotFound if the movie does not exist or its admin review is no longer this review.
+	UpdateReview(ctx context.Context, imdbID string, review string, ranking models.Ranking, audit models.RankingAudit) error
+	// FailReview sets the ranking status failed, with the same conditions as UpdateReview
+	FailReview(ctx context.Context, imdbID string, review string) error
+	// FindReviewed returns at most limit movies having an admin review, in imdb_id order, after the given imdb_id
+	FindReviewed(ctx context.Context, afterImdbID string, limit int64) ([]models.Movie, error)
+	// CountReviewed returns the number of movies having an admin review
+	CountReviewed(ctx context.Context) (int64, error)
+	// CountByRanking returns the number of movies, soft deleted ones included, ranked with the ranking named name
+	CountByRanking(ctx context.Context, name string) (int64, error)
+	// RemapRanking gives the ranking to every movie, soft deleted ones included, ranked with the ranking named from.
+	// It returns the imdb_id of the updated movies.
+	RemapRanking(ctx context.Context, from string, ranking models.Ranking) ([]string, error)
+	// RenameGenre gives the name of the genre to its copies in the movies, soft deleted ones included.
+	// It returns the imdb_id of the updated movies.
+	RenameGenre(ctx context.Context, genre models.Genre) ([]string, error)
+	// MergeGenre replaces the genre with id from by the genre into in the movies, soft deleted ones included,
+	// a movie having both keeps only into. It returns the imdb_id of the updated movies.
+	MergeGenre(ctx context.Context, from int, into models.Genre) ([]string, error)
+	// FindByGenreIDs returns at most limit movies having one of the genres, best ranked first,
+	// leaving out the movies of excludeImdbIDs
+	FindByGenreIDs(ctx context.Context, genreIDs []int, excludeImdbIDs []string, limit int64) ([]models.Movie, error)
+	// FindByImdbIDs returns the movies having one of the imdb_id, in no particular order, unknown ones are skipped
+	FindByImdbIDs(ctx context.Context, imdbIDs []string) ([]models.Movie, error)
+	// NextRatingRevision increments the rating revision of the movie, soft deleted or not, and returns it.
+	// It is called after a user review is written and before the rating stats are recomputed. ErrNotFound if the movie does not exist.
+	NextRatingRevision(ctx context.Context, imdbID string) (int64, error)
+	// SetRatingStats stores the average rating by the users and the number of ratings of the movie computed at the revision,
+	// soft deleted or not, without changing its version. ErrNotFound if the movie does not exist
+	// or already has the stats of the same or a later revision.
+	SetRatingStats(ctx context.Context, imdbID string, stats models.RatingStats, revision int64) error
+}
+
+// UserRepository gives access to the "users" collection
+type UserRepository interface {
+	// FindByEmail returns the user registered with the email address or ErrNotFound
+	FindByEmail(ctx context.Context, email string) (models.User, error)
+	// FindByID returns the user with the given user_id or ErrNotFound
+	FindByID(ctx context.Context, userID string) (models.User, error)
+	// CountByEmail returns the number of users registered with the email address
+	CountByEmail(ctx context.Context, email string) (int64, error)
+	// Insert adds a user and returns the id of the new document
+	Insert(ctx context.Context, user models.User) (bson.ObjectID, error)
+	// UpdateTokens stores the access and refresh tokens of the user
+	UpdateTokens(ctx context.Context, userID string, token string, refreshToken string) error
+	// RotateTokens stores the new tokens only if the stored refresh token is still presented, in a single update.
+	// false when it is not, the presented token was already rotated out or revoked.
+	RotateTokens(ctx context.Context, userID string, presented string, token string, refreshToken string) (bool, error)
+	// SetRole changes the role of the user and clears their tokens, which carry the previous role,
+	// so the new role applies from their next login. ErrNotFound if the user does not exist.
+	SetRole(ctx context.Context, userID string, role string) error
+	// RenameGenre gives the name of the genre to its copies in the favourite genres and returns the number of updated users
+	RenameGenre(ctx context.Context, genre models.Genre) (int64, error)
+	// MergeGenre replaces the genre with id from by the genre into in the favourite genres,
+	// a user having both keeps only into. It returns the number of updated users.
+	MergeGenre(ctx context.Context, from int, into models.Genre) (int64, error)
+}
+
+// InteractionRepository gives access to the "interactions" collection,
+// the ratings, watches, watchlist adds and dismissals the recommender learns from
+type InteractionRepository interface {
+	// Record stores the interaction, replacing the one of the same user, movie and kind
+	Record(ctx context.Context, interaction models.Interaction) error
+	// Remove deletes the interaction of the user with the movie of the given kind, ErrNotFound if there is none
+	Remove(ctx context.Context, userID string, imdbID string, kind string) error
+	// FindByUser returns every interaction of the user
+	FindByUser(ctx context.Context, userID string) ([]models.Interaction, error)
+	// ListByUser returns a page of the interactions of the user of the given kind, most recent first, and their total number
+	ListByUser(ctx context.Context, userID string, kind string, skip int64, limit int64) ([]models.Interaction, int64, error)
+	// Stream calls fn for every interaction without loading them all in memory, it stops at the first error of fn
+	Stream(ctx context.Context, fn func(models.Interaction) error) error
+}
+
+// UserReviewRepository gives access to the "user_reviews" collection, the ratings and reviews of the movies by the users
+type UserReviewRepository interface {
+	// Insert adds the review, ErrDuplicate if the user already reviewed the movie
+	Insert(ctx context.Context, review models.UserReview) (models.UserReview, error)
+	// Update changes the rating and the text of the review of the user, ErrNotFound if there is none
+	Update(ctx context.Context, userID string, imdbID string, rating int, text string) (models.UserReview, error)
+	// Delete removes the review of the user, ErrNotFound if there is none
+	Delete(ctx context.Context, userID string, imdbID string) error
+	// ListByMovie returns a page of the reviews of the movie, most recently updated first, and their total number
+	ListByMovie(ctx context.Context, imdbID string, skip int64, limit int64) ([]models.UserReview, int64, error)
+	// ListByUser returns a page of the reviews of the user, most recently updated first, and their total number
+	ListByUser(ctx context.Context, userID string, skip int64, limit int64) ([]models.UserReview, int64, error)
+	// RatingStats returns the average rating of the movie and the number of reviews, zero when it has none
+	RatingStats(ctx context.Context, imdbID string) (models.RatingStats, error)
+}
+
+// WatchlistRepository gives access to the "watchlists" collection, the movies the users saved for later
+type WatchlistRepository interface {
+	// Add appends the movie to the watchlist of the user, created if needed. It returns false when the movie
+	// was already in the watchlist, which is left unchanged, and ErrWatchlistFull when it holds MaxWatchlistSize movies.
+	Add(ctx context.Context, userID string, imdbID string) (bool, error)
+	// Remove takes the movie out of the watchlist of the user, ErrNotFound if it is not in it
+	Remove(ctx context.Context, userID string, imdbID string) error
+	// List returns a page of the watchlist of the user in its order and the number of movies in it
+	List(ctx context.Context, userID string, skip int64, limit int64) ([]models.WatchlistItem, int64, error)
+	// Move puts the movie at the position, from 0, the last position when it is past the end, and returns its new position.
+	// ErrNotFound if the movie is not in the watchlist, ErrVersionConflict if the watchlist changed meanwhile.
+	Move(ctx context.Context, userID string, imdbID string, position int) (int, error)
+}
+
+// GenreRepository gives access to the "genres" collection, the catalogue of the genres movies and users can have
+type GenreRepository interface {
+	// FindAll returns every genre, ordered by genre_id
+	FindAll(ctx context.Context) ([]models.Genre, error)
+	// FindByID returns the genre or ErrNotFound
+	FindByID(ctx context.Context, genreID int) (models.Genre, error)
+	// Insert adds the genre, with the next free genre_id when it has none, and returns it.
+	// ErrDuplicate if its id or name is taken, names are compared ignoring the case.
+	Insert(ctx context.Context, genre models.Genre) (models.Genre, error)
+	// Rename changes the name of the genre, ErrNotFound if it does not exist, ErrDuplicate if the name is taken
+	Rename(ctx context.Context, genreID int, name string) (models.Genre, error)
+	// Delete removes the genre, ErrNotFound if it does not exist
+	Delete(ctx context.Context, genreID int) error
+}
+
+// RankingRepository gives access to the "rankings" collection
+type RankingRepository interface {
+	// FindAll returns every ranking, ordered by value
+	FindAll(ctx context.Context) ([]models.Ranking, error)
+	// FindByName returns the ranking with the given name or ErrNotFound
+	FindByName(ctx context.Context, name string) (models.Ranking, error)
+	// Insert adds a ranking, ErrDuplicate if its name or value is taken or if it is a second "not ranked" ranking
+	Insert(ctx context.Context, ranking models.Ranking) error
+	// Update replaces the ranking named name, same errors as Insert and ErrNotFound
+	Update(ctx context.Context, name string, ranking models.Ranking) error
+	// Delete removes the ranking named name, ErrNotFound if there is none
+	Delete(ctx context.Context, name string) error
+}
+
+// PromptTemplateRepository stores the versions of the prompt template and which one is active
+type PromptTemplateRepository interface {
+	// Insert adds the template as the next version and returns it
+	Insert(ctx context.Context, template models.PromptTemplate) (models.PromptTemplate, error)
+	// FindAll returns every version, newest first
+	FindAll(ctx context.Context) ([]models.PromptTemplate, error)
+	// FindByVersion returns the version or ErrNotFound
+	FindByVersion(ctx context.Context, version int) (models.PromptTemplate, error)
+	// FindActive returns the active version, ErrNotFound if none was activated
+	FindActive(ctx context.Context) (models.PromptTemplate, error)
+	// Activate makes the version the one the reviews are ranked with, ErrNotFound if it does not exist
+	Activate(ctx context.Context, version int) error
+}
+
+// ClassificationRepository caches the rankings given by the LLM to the reviews, by content hash
+type ClassificationRepository interface {
+	// Find returns the classification stored under the key, ErrNotFound if there is none or it expired
+	Find(ctx context.Context, key string) (models.Classification, error)
+	// Save stores the classification under its key, replacing the previous one
+	Save(ctx context.Context, classification models.Classification) error
+}
+
+// IdempotencyRepository stores the responses of the requests sent with an Idempotency-Key
+type IdempotencyRepository interface {
+	// Reserve records the key as in progress until the lease of the record expires. If the user already used the key
+	// it returns the existing record instead, unless it is in progress with an expired lease: the record is then taken over.
+	Reserve(ctx context.Context, record models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	// Complete stores the response of the request made with the key
+	Complete(ctx context.Context, userID string, key string, status int, location string, body []byte) error
+	// Release forgets the key, so the request can be retried after a failure
+	Release(ctx context.Context, userID string, key string) error
+}
+
+// ReviewJobRepository stores the review classification jobs
+type ReviewJobRepository interface {
+	// Insert adds a pending job, runnable at once, and returns it with its id
+	Insert(ctx context.Context, job models.ReviewJob) (models.ReviewJob, error)
+	// FindByID returns the job or ErrNotFound
+	FindByID(ctx context.Context, jobID string) (models.ReviewJob, error)
+	// Claim marks the oldest runnable job as running until now+lease, counts the attempt and returns it.
+	// Pending jobs whose next run is due and running jobs whose lease expired (their worker died) are runnable.
+	// ErrNotFound when no job is runnable.
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (models.ReviewJob, error)
+	// Succeed stores the ranking given to the review
+	Succeed(ctx context.Context, jobID bson.ObjectID, ranking models.Ranking, classifier string) error
+	// Retry makes the job pending again at nextRunAt after a failed attempt
+	Retry(ctx context.Context, jobID bson.ObjectID, nextRunAt time.Time, lastError string) error
+	// Finish ends the job with the failed or superseded status
+	Finish(ctx context.Context, jobID bson.ObjectID, status string, lastError string) error
+}
+
+// RerankRepository stores the re-ranking runs and the changes they found
+type RerankRepository interface {
+	// Insert adds the run and returns it with its id
+	Insert(ctx context.Context, run models.RerankRun) (models.RerankRun, error)
+	// FindByID returns the run or ErrNotFound
+	FindByID(ctx context.Context, runID string) (models.RerankRun, error)
+	// FindByStatus returns the runs in the given state
+	FindByStatus(ctx context.Context, status string) ([]models.RerankRun, error)
+	// SaveProgress stores the status, counters and checkpoint of the run
+	SaveProgress(ctx context.Context, run models.RerankRun) error
+	// Claim gives the run to owner if it is still running with the owner it was read with,
+	// false when another server claimed it first
+	Claim(ctx context.Context, run models.RerankRun, owner string) (bool, error)
+	// SaveChanges stores the changes, a change found again for the same movie replaces the previous one
+	SaveChanges(ctx context.Context, changes []models.RerankChange) error
+	// ListChanges returns a page of the changes of the run in imdb_id order and their total number
+	ListChanges(ctx context.Context, runID bson.ObjectID, skip int64, limit int64) ([]models.RerankChange, int64, error)
+}
+
+// Repositories groups the repositories the handlers are built with
+type Repositories struct {
+	Movies MovieRepository
+	Users  UserRepository
+	// Ratings, watches and watchlist adds of the users, the input of the recommender
+	Interactions InteractionRepository
+	// Ratings and reviews of the movies by the users
+	UserReviews UserReviewRepository
+	Watchlists  WatchlistRepository
+	Rankings    RankingRepository
+	Genres      GenreRepository
+	Prompts     PromptTemplateRepository
+	// Rankings given by the LLM, reused for the same review, rankings, prompt template and model
+	Classifications ClassificationRepository
+	Idempotency     IdempotencyRepository
+	ReviewJobs      ReviewJobRepository
+	Reranks         RerankRepository
+}
+
+// indexCreator is implemented by the repositories needing indexes in the database
+type indexCreator interface {
+	EnsureIndexes(ctx context.Context) error
+}
+
+// EnsureIndexes creates the indexes the repositories rely on, it is called once at startup
+func (r *Repositories) EnsureIndexes(ctx context.Context) error {
+	for _, repo := range []any{r.Movies, r.Users, r.Interactions, r.UserReviews, r.Watchlists, r.Rankings, r.Genres, r.Prompts, r.Classifications, r.Idempotency, r.ReviewJobs, r.Reranks} {
+		if creator, ok := repo.(indexCreator); ok {
+			if err := creator.EnsureIndexes(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// SeedGenres fills an empty genres collection with the genres of the movies,
+// so a database created before the collection existed keeps accepting its genres. It is called once at startup.
+func (r *Repositories) SeedGenres(ctx context.Context) error {
+	existing, err := r.Genres.FindAll(ctx)
+
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+
+	genres, err := r.Movies.FindGenres(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for _, genre := range genres {
+		// The first of two movie genres sharing an id or a name wins
+		if _, err := r.Genres.Insert(ctx, genre); err != nil && !errors.Is(err, ErrDuplicate) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SeedPromptTemplate stores the template of BASE_PROMPT_TEMPLATE as the active version 1
+// when no version exists yet. It is called once at startup.
+func (r *Repositories) SeedPromptTemplate(ctx context.Context, template string) error {
+	existing, err := r.Prompts.FindAll(ctx)
+
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+
+	seeded, err := r.Prompts.Insert(ctx, models.PromptTemplate{Template: template, Description: "BASE_PROMPT_TEMPLATE"})
+
+	if err != nil {
+		return err
+	}
+
+	return r.Prompts.Activate(ctx, seeded.Version)
+}
+
+// NewMongoRepositories returns repositories backed by the collections of the Mongo database
+func NewMongoRepositories(db *mongo.Database) *Repositories {
+	return &Repositories{
+		Movies:          NewMongoMovieRepository(db.Collection("movies")),
+		Users:           NewMongoUserRepository(db.Collection("users")),
+		Interactions:    NewMongoInteractionRepository(db.Collection("interactions")),
+		UserReviews:     NewMongoUserReviewRepository(db.Collection("user_reviews")),
+		Watchlists:      NewMongoWatchlistRepository(db.Collection("watchlists")),
+		Rankings:        NewMongoRankingRepository(db.Collection("rankings")),
+		Genres:          NewMongoGenreRepository(db.Collection("genres")),
+		Prompts:         NewMongoPromptTemplateRepository(db.Collection("prompt_templates"), db.Collection("settings")),
+		Classifications: NewMongoClassificationRepository(db.Collection("classifications")),
+		Idempotency:     NewMongoIdempotencyRepository(db.Collection("idempotency_keys")),
+		ReviewJobs:      NewMongoReviewJobRepository(db.Collection("review_jobs")),
+		Reranks:         NewMongoRerankRepository(db.Collection("rerank_runs"), db.Collection("rerank_changes")),
+	}
+}
+
+// NewMemoryRepositories returns empty in-memory repositories, used to run the API without a database
+func NewMemoryRepositories() *Repositories {
+	return &Repositories{
+		Movies:          NewMemoryMovieRepository(),
+		Users:           NewMemoryUserRepository(),
+		Interactions:    NewMemoryInteractionRepository(),
+		UserReviews:     NewMemoryUserReviewRepository(),
+		Watchlists:      NewMemoryWatchlistRepository(),
+		Rankings:        NewMemoryRankingRepository(),
+		Genres:          NewMemoryGenreRepository(),
+		Prompts:         NewMemoryPromptTemplateRepository(),
+		Classifications: NewMemoryClassificationRepository(),
+		Idempotency:     NewMemoryIdempotencyRepository(),
+		ReviewJobs:      NewMemoryReviewJobRepository(),
+		Reranks:         NewMemoryRerankRepository(),
+	}
+}
@@ -0,0 +1,466 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestMalformedAuthorizationHeaders(t *testing.T) {
+	api := newTestAPI(t)
+
+	for _, header := range []string{"Bear", "Token " + api.user.token, api.user.token, "Bearer "} {
+		req := httptest.NewRequest(http.MethodGet, "/movie/"+testMovieID, nil)
+		req.Header.Set("Authorization", header)
+
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("got status %d for the Authorization header %q, want 401", w.Code, header)
+		}
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	api := newTestAPI(t)
+
+	var refreshed models.UserResponse
+	api.expect(api.do(http.MethodPost, "/refresh", `{"refresh_token":"`+api.refreshToken(api.user)+`"}`, ""), http.StatusOK, &refreshed)
+
+	// The two requests race with the same refresh token, a single one rotates it and the other one is a reuse
+	var group sync.WaitGroup
+	codes := make([]int, 2)
+
+	for i := range codes {
+		group.Go(func() {
+			codes[i] = api.do(http.MethodPost, "/refresh", `{"refresh_token":"`+refreshed.Refresh_token+`"}`, "").Code
+		})
+	}
+
+	group.Wait()
+	slices.Sort(codes)
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusUnauthorized {
+		t.Fatalf("got the statuses %v for two refreshes with the same token, want one 200 and one 401", codes)
+	}
+
+	// The reuse revoked every token of the user
+	if api.refreshToken(api.user) != "" {
+		t.Fatal("the tokens were not revoked after a reuse")
+	}
+
+	api.expect(api.do(http.MethodGet, "/movie/"+testMovieID, "", refreshed.Token), http.StatusUnauthorized, nil)
+}
+
+// refreshToken returns the refresh token stored for the user
+func (api *testAPI) refreshToken(user testUser) string {
+	api.t.Helper()
+
+	stored, err := api.deps.Repos.Users.FindByID(context.Background(), user.id)
+	api.check(err)
+
+	return stored.Refresh_token
+}
+
+func TestUserRatingsUpdateTheAverage(t *testing.T) {
+	api := newTestAPI(t)
+	path := "/movie/" + testMovieID + "/reviews"
+
+	api.expect(api.do(http.MethodPost, path, `{"rating":8,"review":"Nice"}`, api.user.token), http.StatusCreated, nil)
+
+	var created struct {
+		Movie_rating models.RatingStats `json:"movie_rating"`
+	}
+	api.expect(api.do(http.MethodPost, path, `{"rating":5}`, api.other.token), http.StatusCreated, &created)
+
+	if created.Movie_rating != (models.RatingStats{Average: 6.5, Count: 2}) {
+		t.Fatalf("got %+v after two ratings, want an average of 6.5 over 2", created.Movie_rating)
+	}
+
+	// A user reviews a movie once
+	api.expect(api.do(http.MethodPost, path, `{"rating":3}`, api.user.token), http.StatusConflict, nil)
+
+	api.expect(api.do(http.MethodPut, path, `{"rating":10}`, api.user.token), http.StatusOK, nil)
+	api.expectRating(7.5, 2)
+
+	api.expect(api.do(http.MethodDelete, path, "", api.other.token), http.StatusOK, nil)
+	api.expectRating(10, 1)
+
+	// Only admins delete the review of another user
+	api.expect(api.do(http.MethodDelete, path+"?user_id="+api.user.id, "", api.other.token), http.StatusForbidden, nil)
+	api.expect(api.do(http.MethodDelete, path+"?user_id="+api.user.id, "", api.admin.token), http.StatusOK, nil)
+	api.expectRating(0, 0)
+}
+
+func TestStaleRatingStatsAreNotStored(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	movies := api.deps.Repos.Movies
+
+	// Two reviews written concurrently: the first one reads the stats before the second review is written
+	stale, err := movies.NextRatingRevision(ctx, testMovieID)
+	api.check(err)
+
+	latest, err := movies.NextRatingRevision(ctx, testMovieID)
+	api.check(err)
+
+	api.check(movies.SetRatingStats(ctx, testMovieID, models.RatingStats{Average: 6.5, Count: 2}, latest))
+
+	if err := movies.SetRatingStats(ctx, testMovieID, models.RatingStats{Average: 8, Count: 1}, stale); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound for the stats of an earlier revision", err)
+	}
+
+	api.expectRating(6.5, 2)
+}
+
+// expectRating checks the average rating and the number of ratings returned with the test movie
+func (api *testAPI) expectRating(average float64, count int64) {
+	api.t.Helper()
+
+	var movie models.Movie
+	api.expect(api.do(http.MethodGet, "/movie/"+testMovieID, "", api.user.token), http.StatusOK, &movie)
+
+	if movie.Rating_average != average || movie.Rating_count != count {
+		api.t.Fatalf("got an average of %v over %d, want %v over %d", movie.Rating_average, movie.Rating_count, average, count)
+	}
+}
+
+func TestWatchlistOrder(t *testing.T) {
+	api := newTestAPI(t)
+	api.addMovie(models.Movie{Imbd_id: "tt0000004", Title: "The Fourth Movie", Genre: []models.Genre{{Genre_id: 1, Genre_name: "Drama"}}})
+
+	for _, imdbID := range []string{testMovieID, otherMovieID, "tt0000004"} {
+		api.expect(api.do(http.MethodPost, "/watchlist/"+imdbID, "", api.user.token), http.StatusCreated, nil)
+	}
+
+	// Adding a movie again leaves it in place
+	api.expect(api.do(http.MethodPost, "/watchlist/"+testMovieID, "", api.user.token), http.StatusOK, nil)
+	api.expectWatchlist(testMovieID, otherMovieID, "tt0000004")
+
+	api.expect(api.do(http.MethodPatch, "/watchlist/tt0000004", `{"position":1}`, api.user.token), http.StatusOK, nil)
+	api.expectWatchlist("tt0000004", testMovieID, otherMovieID)
+
+	// Past the end the movie becomes the last one
+	var moved struct {
+		Position int `json:"position"`
+	}
+	api.expect(api.do(http.MethodPatch, "/watchlist/"+testMovieID, `{"position":99}`, api.user.token), http.StatusOK, &moved)
+
+	if moved.Position != 3 {
+		t.Fatalf("moved to position %d, want 3", moved.Position)
+	}
+
+	api.expectWatchlist("tt0000004", otherMovieID, testMovieID)
+
+	api.expect(api.do(http.MethodDelete, "/watchlist/"+otherMovieID, "", api.user.token), http.StatusOK, nil)
+	api.expectWatchlist("tt0000004", testMovieID)
+
+	api.expect(api.do(http.MethodPatch, "/watchlist/"+otherMovieID, `{"position":1}`, api.user.token), http.StatusNotFound, nil)
+	api.expect(api.do(http.MethodPost, "/watchlist/"+deletedMovieID, "", api.user.token), http.StatusNotFound, nil)
+
+	// Every user has their own watchlist
+	var empty struct {
+		Total int64 `json:"total"`
+	}
+	api.expect(api.do(http.MethodGet, "/watchlist", "", api.other.token), http.StatusOK, &empty)
+
+	if empty.Total != 0 {
+		t.Fatalf("the watchlist of another user holds %d movies", empty.Total)
+	}
+}
+
+// expectWatchlist checks the movies of the watchlist of the user, in order
+func (api *testAPI) expectWatchlist(imdbIDs ...string) {
+	api.t.Helper()
+
+	var watchlist struct {
+		Items []models.WatchlistEntry `json:"items"`
+	}
+	api.expect(api.do(http.MethodGet, "/watchlist", "", api.user.token), http.StatusOK, &watchlist)
+
+	var got []string
+
+	for i, entry := range watchlist.Items {
+		got = append(got, entry.Imdb_id)
+
+		if entry.Position != i+1 || entry.Movie == nil {
+			api.t.Fatalf("entry %d is %+v, want position %d with the movie", i, entry, i+1)
+		}
+	}
+
+	if !slices.Equal(got, imdbIDs) {
+		api.t.Fatalf("got the watchlist %v, want %v", got, imdbIDs)
+	}
+}
+
+func TestRerankDryRunDiff(t *testing.T) {
+	api := newTestAPI(t)
+
+	// The fake classifier ranks a review with the ranking it names, the test movie ("A great movie", Good)
+	// falls back to Excellent while this one keeps its ranking
+	api.addMovie(models.Movie{Imbd_id: "tt0000004", Title: "The Fourth Movie", Genre: []models.Genre{{Genre_id: 1, Genre_name: "Drama"}},
+		Admin_review: "A Terrible movie", Ranking: models.Ranking{Ranking_value: 5, Ranking_name: "Terrible"}})
+
+	var run models.RerankRun
+	api.expect(api.do(http.MethodPost, "/rerankruns", `{"dry_run":true}`, api.admin.token), http.StatusAccepted, &run)
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for run.Status == models.RerankRunning && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		api.expect(api.do(http.MethodGet, "/rerankruns/"+run.ID.Hex(), "", api.admin.token), http.StatusOK, &run)
+	}
+
+	if run.Status != models.RerankCompleted || run.Processed != 2 || run.Changed != 1 {
+		t.Fatalf("got the run %+v, want it completed with 2 movies processed and 1 changed", run)
+	}
+
+	var diff struct {
+		Changes []models.RerankChange `json:"changes"`
+		Total   int64                 `json:"total"`
+	}
+	api.expect(api.do(http.MethodGet, "/rerankruns/"+run.ID.Hex()+"/changes", "", api.admin.token), http.StatusOK, &diff)
+
+	if diff.Total != 1 || len(diff.Changes) != 1 {
+		t.Fatalf("got %d changes, want 1", diff.Total)
+	}
+
+	// (page-1)*limit would overflow
+	api.expect(api.do(http.MethodGet, "/rerankruns/"+run.ID.Hex()+"/changes?page=9223372036854775807&limit=2", "", api.admin.token), http.StatusBadRequest, nil)
+
+	change := diff.Changes[0]
+
+	if change.Imdb_id != testMovieID || change.Previous.Ranking_name != "Good" || change.Ranking == nil || change.Ranking.Ranking_name != "Excellent" || change.Applied {
+		t.Fatalf("got the change %+v, want %s from Good to Excellent, not applied", change, testMovieID)
+	}
+
+	// A dry run leaves the movies unchanged
+	var movie models.Movie
+	api.expect(api.do(http.MethodGet, "/movie/"+testMovieID, "", api.user.token), http.StatusOK, &movie)
+
+	if movie.Ranking.Ranking_name != "Good" {
+		t.Fatalf("the dry run changed the ranking to %s", movie.Ranking.Ranking_name)
+	}
+}
+
+func TestCancelRerankOfAnUnknownRun(t *testing.T) {
+	api := newTestAPI(t)
+
+	api.expect(api.do(http.MethodPost, "/rerankruns/"+bson.NewObjectID().Hex()+"/cancel", "", api.admin.token), http.StatusNotFound, nil)
+	api.expect(api.do(http.MethodPost, "/rerankruns/not-an-id/cancel", "", api.admin.token), http.StatusNotFound, nil)
+
+	// A known run that is not running can not be canceled
+	api.expect(api.do(http.MethodPost, "/rerankruns/"+api.insertRun(models.RerankCompleted)+"/cancel", "", api.admin.token), http.StatusConflict, nil)
+}
+
+func TestGenreRenameCascades(t *testing.T) {
+	api := newTestAPI(t)
+
+	var renamed struct {
+		Movies_updated int   `json:"movies_updated"`
+		Users_updated  int64 `json:"users_updated"`
+	}
+	api.expect(api.do(http.MethodPut, "/genres/1", `{"genre_name":"Dramas"}`, api.admin.token), http.StatusOK, &renamed)
+
+	// The test movie and the three users of the fixture have the genre
+	if renamed.Movies_updated != 1 || renamed.Users_updated != 3 {
+		t.Fatalf("got %+v, want 1 movie and 3 users updated", renamed)
+	}
+
+	var movie models.Movie
+	api.expect(api.do(http.MethodGet, "/movie/"+testMovieID, "", api.user.token), http.StatusOK, &movie)
+
+	if len(movie.Genre) != 1 || movie.Genre[0] != (models.Genre{Genre_id: 1, Genre_name: "Dramas"}) {
+		t.Fatalf("got the genres %+v on the movie, want Dramas", movie.Genre)
+	}
+
+	user, err := api.deps.Repos.Users.FindByID(context.Background(), api.user.id)
+	api.check(err)
+
+	if user.Favourite_genres[0].Genre_name != "Dramas" {
+		t.Fatalf("got the favourite genres %+v, want Dramas", user.Favourite_genres)
+	}
+
+	// The filters of the catalogue use the new name
+	var page models.MoviePage
+	api.expect(api.do(http.MethodGet, "/movies?genre=Dramas", "", ""), http.StatusOK, &page)
+
+	if page.Total != 1 {
+		t.Fatalf("got %d movies of the renamed genre, want 1", page.Total)
+	}
+
+	// Names are unique, ignoring the case
+	api.expect(api.do(http.MethodPut, "/genres/2", `{"genre_name":"dramas"}`, api.admin.token), http.StatusConflict, nil)
+}
+
+func TestRecommendationsLeaveOutWatchedAndDismissed(t *testing.T) {
+	api := newTestAPI(t)
+
+	for _, imdbID := range []string{"tt0000004", "tt0000005", "tt0000006"} {
+		api.addMovie(models.Movie{Imbd_id: imdbID, Title: "Drama " + imdbID, Genre: []models.Genre{{Genre_id: 1, Genre_name: "Drama"}}})
+	}
+
+	api.expect(api.do(http.MethodPost, "/watchhistory/"+testMovieID, "", api.user.token), http.StatusOK, nil)
+	api.expect(api.do(http.MethodPost, "/dismissals/tt0000004", "", api.user.token), http.StatusOK, nil)
+	api.check(api.deps.Recommender.Refresh(context.Background()))
+
+	recommended := api.recommendations(api.user, "")
+
+	for _, imdbID := range []string{testMovieID, "tt0000004"} {
+		if slices.Contains(recommended, imdbID) {
+			t.Errorf("%s is recommended after being watched or dismissed: %v", imdbID, recommended)
+		}
+	}
+
+	if !slices.Contains(recommended, "tt0000005") || !slices.Contains(recommended, "tt0000006") {
+		t.Errorf("got the recommendations %v, want the dramas not watched nor dismissed", recommended)
+	}
+
+	// Another user with the same favourite genre still gets them
+	if other := api.recommendations(api.other, ""); !slices.Contains(other, testMovieID) || !slices.Contains(other, "tt0000004") {
+		t.Errorf("got the recommendations %v for another user, want the movies watched and dismissed by the first one", other)
+	}
+
+	// Undoing the dismissal brings the movie back
+	api.expect(api.do(http.MethodDelete, "/dismissals/tt0000004", "", api.user.token), http.StatusOK, nil)
+
+	if recommended := api.recommendations(api.user, ""); !slices.Contains(recommended, "tt0000004") {
+		t.Errorf("got the recommendations %v, want tt0000004 back after undoing the dismissal", recommended)
+	}
+}
+
+func TestRecommendationPages(t *testing.T) {
+	api := newTestAPI(t)
+
+	// More movies of the favourite genre than the default page
+	for i := range 12 {
+		api.addMovie(models.Movie{Imbd_id: fmt.Sprintf("tt00001%02d", i), Title: "Drama", Genre: []models.Genre{{Genre_id: 1, Genre_name: "Drama"}}})
+	}
+
+	api.check(api.deps.Recommender.Refresh(context.Background()))
+
+	all := api.recommendations(api.user, "?limit=20")
+
+	if len(all) != 13 {
+		t.Fatalf("got %d recommendations, want the 13 dramas", len(all))
+	}
+
+	var paged []string
+
+	for page := 1; page <= 3; page++ {
+		paged = append(paged, api.recommendations(api.user, fmt.Sprintf("?page=%d&limit=5", page))...)
+	}
+
+	if !slices.Equal(paged, all) {
+		t.Fatalf("got the pages %v, want %v", paged, all)
+	}
+
+	if page := api.recommendations(api.user, ""); len(page) != int(api.deps.Config.Recommended_movie_limit) {
+		t.Fatalf("got %d recommendations by default, want RECOMMENDED_MOVIE_LIMIT", len(page))
+	}
+
+	api.expect(api.do(http.MethodGet, "/recommendedmovies?limit=0", "", api.user.token), http.StatusBadRequest, nil)
+
+	// (page-1)*limit would overflow
+	api.expect(api.do(http.MethodGet, "/recommendedmovies?page=9223372036854775807&limit=100", "", api.user.token), http.StatusBadRequest, nil)
+	api.expect(api.do(http.MethodGet, "/watchhistory?page=9223372036854775807&limit=2", "", api.user.token), http.StatusBadRequest, nil)
+}
+
+// recommendations returns the imdb_id of the movies recommended to the user, query is the page asked
+func (api *testAPI) recommendations(user testUser, query string) []string {
+	api.t.Helper()
+
+	var recommendations []models.Recommendation
+	api.expect(api.do(http.MethodGet, "/recommendedmovies"+query, "", user.token), http.StatusOK, &recommendations)
+
+	ids := make([]string, len(recommendations))
+
+	for i, recommendation := range recommendations {
+		ids[i] = recommendation.Imbd_id
+	}
+
+	return ids
+}
+
+func TestUpdateRankingRetriesAFailedRemap(t *testing.T) {
+	api := newTestAPI(t)
+
+	movies := &failingRemapRepository{MovieRepository: api.deps.Repos.Movies, fail: true}
+	api.deps.Repos.Movies = movies
+	api.router = SetUpRouter(api.deps)
+
+	body := `{"ranking_value":2,"ranking_name":"Great"}`
+	api.expect(api.do(http.MethodPut, "/rankings/Good", body, api.admin.token), http.StatusInternalServerError, nil)
+
+	// Nothing was replaced, the request can be sent again
+	_, err := api.deps.Repos.Rankings.FindByName(context.Background(), "Good")
+	api.check(err)
+
+	movies.fail = false
+
+	var updated struct {
+		Movies_updated int `json:"movies_updated"`
+	}
+	api.expect(api.do(http.MethodPut, "/rankings/Good", body, api.admin.token), http.StatusOK, &updated)
+
+	var movie models.Movie
+	api.expect(api.do(http.MethodGet, "/movie/"+testMovieID, "", api.user.token), http.StatusOK, &movie)
+
+	if updated.Movies_updated != 1 || movie.Ranking.Ranking_name != "Great" {
+		t.Fatalf("got %d movies updated and the ranking %s, want 1 and Great", updated.Movies_updated, movie.Ranking.Ranking_name)
+	}
+
+	// A conflict leaves the movies alone
+	api.expect(api.do(http.MethodPut, "/rankings/Great", `{"ranking_value":2,"ranking_name":"Excellent"}`, api.admin.token), http.StatusConflict, nil)
+	api.expect(api.do(http.MethodGet, "/movie/"+testMovieID, "", api.user.token), http.StatusOK, &movie)
+
+	if movie.Ranking.Ranking_name != "Great" {
+		t.Fatalf("a conflicting update moved the movie to %s", movie.Ranking.Ranking_name)
+	}
+}
+
+// failingRemapRepository fails RemapRanking while fail is set
+type failingRemapRepository struct {
+	repository.MovieRepository
+	fail bool
+}
+
+func (r *failingRemapRepository) RemapRanking(ctx context.Context, from string, ranking models.Ranking) ([]string, error) {
+	if r.fail {
+		return nil, errors.New("remap failed")
+	}
+
+	return r.MovieRepository.RemapRanking(ctx, from, ranking)
+}
+
+func TestAddMovieResolvesTheRanking(t *testing.T) {
+	api := newTestAPI(t)
+
+	movie := func(imdbID string, ranking string) string {
+		return `{"imdb_id":"` + imdbID + `","title":"A New Movie","poster_path":"https://example.com/new.jpg","youtube_id":"ytnew",
+			"genre":[{"genre_id":1,"genre_name":"Drama"}],"ranking":` + ranking + `}`
+	}
+
+	api.expect(api.do(http.MethodPost, "/addmovie", movie("tt0000009", `{"ranking_value":7,"ranking_name":"Superb"}`), api.admin.token), http.StatusBadRequest, nil)
+
+	// The value comes from the rankings collection
+	api.expect(api.do(http.MethodPost, "/addmovie", movie("tt0000009", `{"ranking_value":42,"ranking_name":"Bad"}`), api.admin.token), http.StatusCreated, nil)
+
+	var added models.Movie
+	api.expect(api.do(http.MethodGet, "/movie/tt0000009", "", api.user.token), http.StatusOK, &added)
+
+	if added.Ranking != (models.Ranking{Ranking_value: 4, Ranking_name: "Bad"}) {
+		t.Fatalf("got the ranking %+v, want Bad with the value 4", added.Ranking)
+	}
+}
@@ -0,0 +1,265 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/cache"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/classifier"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/jobs"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/middleware"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/recommend"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/search"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Movies of the fixture: a ranked movie with an admin review, a second movie and a soft deleted one
+const (
+	testMovieID    = "tt0000001"
+	otherMovieID   = "tt0000002"
+	deletedMovieID = "tt0000003"
+)
+
+// holdReview is an admin review the re-rankings of the tests never finish classifying, so a run stays running
+const holdReview = "hold this review"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	// The request logs of gin.Default would drown the test output
+	gin.DefaultWriter = io.Discard
+
+	os.Exit(m.Run())
+}
+
+// testUser is a user of the fixture with a valid access token
+type testUser struct {
+	id    string
+	token string
+}
+
+// testAPI is the whole API built on the memory repositories, with a user, an admin and a few movies
+type testAPI struct {
+	t      *testing.T
+	deps   *Dependencies
+	router *gin.Engine
+	user   testUser
+	admin  testUser
+	// A second USER, the target of the admin endpoints acting on another user
+	other testUser
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+
+	t.Setenv("MONGODB_URI", "mongodb://localhost:27017")
+	t.Setenv("DATABASE_NAME", "magic_stream_movies_test")
+	t.Setenv("SECRET_KEY", "test-secret")
+	t.Setenv("SECRET_REFRESH_KEY", "test-refresh-secret")
+	t.Setenv("BASE_PROMPT_TEMPLATE", "Rank the review with one of {rankings}: ")
+	t.Setenv("LLM_PROVIDER", classifier.ProviderFake)
+
+	cfg, err := config.Load("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	repos := repository.NewMemoryRepositories()
+	repos.Rankings = repository.NewMemoryRankingRepository(
+		models.Ranking{Ranking_value: 1, Ranking_name: "Excellent"},
+		models.Ranking{Ranking_value: 2, Ranking_name: "Good"},
+		models.Ranking{Ranking_value: 3, Ranking_name: "Okay"},
+		models.Ranking{Ranking_value: 4, Ranking_name: "Bad"},
+		models.Ranking{Ranking_value: 5, Ranking_name: "Terrible"},
+		models.Ranking{Ranking_value: 999, Ranking_name: "Not_Ranked", Not_ranked: true},
+	)
+	repos.Genres = repository.NewMemoryGenreRepository(
+		models.Genre{Genre_id: 1, Genre_name: "Drama"},
+		models.Genre{Genre_id: 2, Genre_name: "Comedy"},
+		models.Genre{Genre_id: 3, Genre_name: "Action"},
+	)
+
+	if err := repos.SeedPromptTemplate(ctx, cfg.Base_prompt_template); err != nil {
+		t.Fatal(err)
+	}
+
+	index := search.NewMemoryIndex()
+	repos.Movies = search.NewIndexedMovieRepository(repos.Movies, index)
+
+	sentimentClassifier, err := classifier.New(cfg, nil, repos.Prompts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue := jobs.NewReviewQueue(cfg, repos.ReviewJobs, repos.Movies, repos.Rankings, sentimentClassifier)
+	wait := queue.Start(ctx)
+
+	// The workers stop with the test
+	t.Cleanup(func() {
+		cancel()
+		wait()
+	})
+
+	deps := &Dependencies{
+		Config:      cfg,
+		Repos:       repos,
+		Search:      index,
+		ReviewQueue: queue,
+		Reranker:    jobs.NewReranker(ctx, repos.Reranks, repos.Movies, repos.Rankings, holdClassifier{sentimentClassifier}),
+		CacheStats:  &cache.Stats{},
+		Recommender: recommend.New(cfg, repos.Interactions, repos.Movies, repos.Rankings),
+	}
+
+	api := &testAPI{t: t, deps: deps, router: SetUpRouter(deps)}
+
+	api.addMovie(models.Movie{Imbd_id: testMovieID, Title: "The First Movie", Genre: []models.Genre{{Genre_id: 1, Genre_name: "Drama"}}, Admin_review: "A great movie", Ranking: models.Ranking{Ranking_value: 2, Ranking_name: "Good"}})
+	api.addMovie(models.Movie{Imbd_id: otherMovieID, Title: "The Second Movie", Genre: []models.Genre{{Genre_id: 2, Genre_name: "Comedy"}}})
+	api.addMovie(models.Movie{Imbd_id: deletedMovieID, Title: "The Deleted Movie", Genre: []models.Genre{{Genre_id: 3, Genre_name: "Action"}}})
+
+	if err := repos.Movies.SoftDelete(ctx, deletedMovieID, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	api.user = api.addUser("user@example.com", middleware.RoleUser)
+	api.admin = api.addUser("admin@example.com", middleware.RoleAdmin)
+	api.other = api.addUser("other@example.com", middleware.RoleUser)
+
+	return api
+}
+
+// addMovie inserts the movie at version 1, with a poster and a trailer, ranked Not_Ranked when it has no ranking
+func (api *testAPI) addMovie(movie models.Movie) {
+	api.t.Helper()
+
+	movie.Poster_path = "https://example.com/" + movie.Imbd_id + ".jpg"
+	movie.YouTube_id = "yt" + movie.Imbd_id
+
+	if movie.Ranking.Ranking_name == "" {
+		movie.Ranking = models.Ranking{Ranking_value: 999, Ranking_name: "Not_Ranked", Not_ranked: true}
+	}
+
+	if _, err := api.deps.Repos.Movies.Insert(context.Background(), movie); err != nil {
+		api.t.Fatal(err)
+	}
+}
+
+// addUser inserts a user with the role and signs them in, without going through the password hashing of /login
+func (api *testAPI) addUser(email string, role string) testUser {
+	api.t.Helper()
+
+	ctx := context.Background()
+	user := models.User{
+		User_ID:          bson.NewObjectID().Hex(),
+		First_name:       "Test",
+		Last_name:        strings.ToUpper(role[:1]) + strings.ToLower(role[1:]),
+		Email:            email,
+		Role:             role,
+		Created_at:       time.Now(),
+		Updated_at:       time.Now(),
+		Favourite_genres: []models.Genre{{Genre_id: 1, Genre_name: "Drama"}},
+	}
+
+	if _, err := api.deps.Repos.Users.Insert(ctx, user); err != nil {
+		api.t.Fatal(err)
+	}
+
+	return api.login(user.User_ID)
+}
+
+// login issues new tokens to the user with their current role
+func (api *testAPI) login(userID string) testUser {
+	api.t.Helper()
+
+	user, err := api.deps.Repos.Users.FindByID(context.Background(), userID)
+
+	if err != nil {
+		api.t.Fatal(err)
+	}
+
+	token, refreshToken, err := utils.GenerateAllTokens(api.deps.Config, user.Email, user.First_name, user.Last_name, user.Role, user.User_ID)
+
+	if err != nil {
+		api.t.Fatal(err)
+	}
+
+	if err := utils.UpdateAllTokens(user.User_ID, token, refreshToken, api.deps.Repos.Users); err != nil {
+		api.t.Fatal(err)
+	}
+
+	return testUser{id: user.User_ID, token: token}
+}
+
+// do sends the request to the router, with the token as bearer token unless it is empty
+func (api *testAPI) do(method string, path string, body string, token string) *httptest.ResponseRecorder {
+	api.t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, req)
+
+	return w
+}
+
+// expect fails the test when the response does not have the status, and decodes its JSON body into out unless it is nil
+func (api *testAPI) expect(w *httptest.ResponseRecorder, status int, out any) {
+	api.t.Helper()
+
+	if w.Code != status {
+		api.t.Fatalf("got status %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+
+	if out == nil {
+		return
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+		api.t.Fatalf("decoding %s: %v", w.Body.String(), err)
+	}
+}
+
+// check fails the test on a fixture error
+func (api *testAPI) check(err error) {
+	api.t.Helper()
+
+	if err != nil {
+		api.t.Fatal(err)
+	}
+}
+
+// holdClassifier never answers for holdReview, until the run is canceled or the test ends
+type holdClassifier struct {
+	classifier.SentimentClassifier
+}
+
+func (h holdClassifier) Classify(ctx context.Context, review string, rankings []models.Ranking) (classifier.Result, error) {
+	if review == holdReview {
+		<-ctx.Done()
+		return classifier.Result{}, ctx.Err()
+	}
+
+	return h.SentimentClassifier.Classify(ctx, review, rankings)
+}
@@ -4,35 +4,157 @@ import (
 	// Custom package import. This package contains the **handler functions** (Controllers)
 	// that implement the **business logic**, which will use the MongoDB connection setup in the 'database' package.
 	controller "github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/controllers"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/importer"
 	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/middleware"
 	"github.com/gin-gonic/gin" // The Gin web framework
 )
 
-func SetUpProtectedRoutes(router *gin.Engine) {
+func SetUpProtectedRoutes(router *gin.Engine, deps *Dependencies) {
 	// Excecution of code will abort if the token is not valid i.e. the user is not a valid registered user or they're not logged in
-	router.Use(middleware.AuthMiddleware())
+	router.Use(middleware.AuthMiddleware(deps.Config, deps.Repos.Users))
+
+	// Excecution of code will abort with a 403 if the role of the user is not allowed on the route (see ProtectedRoutePolicy)
+	router.Use(middleware.Authorize(ProtectedRoutePolicy))
 
 	// Protected endpoint
 	// Define a GET route for the path "/movie/:imdb_id"
 	// ":imdb_id" is a **path parameter** that captures a value from the URL (e.g., /movie/tt0133093)
 	// This route is handled by the GetMovie function from the 'controller' package
 	// Retrieves a single movie's details based on its ID by calling the database functions.
-	router.GET("/movie/:imdb_id", controller.GetMovie())
+	router.GET("/movie/:imdb_id", controller.GetMovie(deps.Repos.Movies))
 
-	// Protected endpoint
+	// Protected endpoint, admin only
 	// Define a POST route for the path "/addmovie"
 	// This route is handled by the AddMovie function from the 'controller' package
 	// Adds a single movie'to the movie collection in the database functions.
-	router.POST("/addmovie", controller.AddMovie())
+	// Retries sent with the same Idempotency-Key header get the original response back.
+	router.POST("/addmovie", middleware.Idempotency(deps.Repos.Idempotency), controller.AddMovie(deps.Repos.Movies, deps.Repos.Genres, deps.Repos.Rankings))
+
+	// Protected endpoints, admin only
+	// Define PUT and PATCH routes for the path "/movie/:imdb_id"
+	// These routes are handled by the UpdateMovie function from the 'controller' package
+	// PUT replaces the title, poster, trailer and genres of the movie, PATCH only changes the given fields.
+	// Both need the current version of the movie and answer 409 if it was modified meanwhile.
+	router.PUT("/movie/:imdb_id", controller.UpdateMovie(deps.Repos.Movies, deps.Repos.Genres, false))
+	router.PATCH("/movie/:imdb_id", controller.UpdateMovie(deps.Repos.Movies, deps.Repos.Genres, true))
+
+	// Define a DELETE route for the path "/movie/:imdb_id"
+	// This route is handled by the DeleteMovie function from the 'controller' package
+	// The movie is soft deleted: hidden from every listing until it is restored
+	router.DELETE("/movie/:imdb_id", controller.DeleteMovie(deps.Repos.Movies))
+
+	// Define a POST route for the path "/movie/:imdb_id/restore"
+	// This route is handled by the RestoreMovie function from the 'controller' package
+	// It brings back a soft deleted movie
+	router.POST("/movie/:imdb_id/restore", controller.RestoreMovie(deps.Repos.Movies))
+
+	// Protected endpoint, admin only
+	// Define a GET route for the path "/movies/export"
+	// This route is handled by the ExportMovies function from the 'controller' package
+	// Streams the movies matching the GET /movies filters as NDJSON, CSV or a JSON array
+	router.GET("/movies/export", controller.ExportMovies(deps.Repos.Movies))
+
+	// Protected endpoint, admin only
+	// Define a POST route for the path "/movies/import"
+	// This route is handled by the ImportMovies function from the 'controller' package
+	// Loads a CSV, NDJSON or IMDb TSV catalogue, upserting the movies by imdb_id (dry_run=true only reports)
+	router.POST("/movies/import", controller.ImportMovies(importer.New(deps.Repos.Movies, deps.Repos.Rankings, deps.Repos.Genres)))
 
 	// Protected endpoint
 	// Define a GET route for the path "/recommendedmovies"
 	// This route is handled by the GetRecommendedMovies function from the 'controller' package
 	// Returns an array of recommended movies for the user, based on the user id, limited to 5 documents
-	router.GET("/recommendedmovies", controller.GetRecommendedMovies())
+	// The movies liked by the users with the same tastes are blended with the favourite genres of the user
+	router.GET("/recommendedmovies", controller.GetRecommendedMovies(deps.Config, deps.Recommender, deps.Repos.Users))
 
+	// Protected endpoint, admin only
 	// Define a PATCH route for the path "/updatereview/:imdb_id"
 	// This route is handled by the AdminReviewUpdate function from the 'controller' package
-	// It updates the review and ranking of the movie imdb_id passed in parameters
-	router.PATCH("/updatereview/:imdb_id", controller.AdminReviewUpdate())
+	// It saves the review of the movie imdb_id passed in parameters and queues the job ranking it
+	router.PATCH("/updatereview/:imdb_id", controller.AdminReviewUpdate(deps.ReviewQueue))
+
+	// Protected endpoint, admin only
+	// Define a GET route for the path "/reviewjobs/:job_id"
+	// This route is handled by the GetReviewJob function from the 'controller' package
+	// Returns the status of the job ranking a review, and its ranking once done
+	router.GET("/reviewjobs/:job_id", controller.GetReviewJob(deps.Repos.ReviewJobs))
+
+	// Protected endpoints, admin only
+	// Re-rank every admin review against the current rankings, follow the progress, read the changes (the diff of a dry run)
+	// and cancel or resume a run. These routes are handled by the Rerank functions from the 'controller' package
+	router.POST("/rerankruns", controller.StartRerank(deps.Reranker))
+	router.GET("/rerankruns/:run_id", controller.GetRerank(deps.Repos.Reranks))
+	router.GET("/rerankruns/:run_id/changes", controller.GetRerankChanges(deps.Repos.Reranks))
+	router.POST("/rerankruns/:run_id/cancel", controller.CancelRerank(deps.Reranker))
+	router.POST("/rerankruns/:run_id/resume", controller.ResumeRerank(deps.Reranker))
+
+	// Protected endpoints, admin only
+	// List, create, update and delete the rankings given to the admin reviews
+	// These routes are handled by the Ranking functions from the 'controller' package
+	// Updating a ranking updates the movies ranked with it, deleting one still in use needs ?remap_to=<ranking name>
+	router.GET("/rankings", controller.GetRankings(deps.Repos.Rankings))
+	router.POST("/rankings", controller.CreateRanking(deps.Repos.Rankings))
+	router.PUT("/rankings/:ranking_name", controller.UpdateRanking(deps.Repos.Rankings, deps.Repos.Movies))
+	router.DELETE("/rankings/:ranking_name", controller.DeleteRanking(deps.Repos.Rankings, deps.Repos.Movies))
+
+	// Protected endpoints, admin only
+	// Create, rename and merge the genres of the genres collection (GET /genres is public)
+	// These routes are handled by the Genre functions from the 'controller' package
+	// Renaming and merging also update the copies of the genre in the movies and the users
+	router.POST("/genres", controller.CreateGenre(deps.Repos.Genres))
+	router.PUT("/genres/:genre_id", controller.RenameGenre(deps.Repos.Genres, deps.Repos.Movies, deps.Repos.Users))
+	router.POST("/genres/:genre_id/merge", controller.MergeGenre(deps.Repos.Genres, deps.Repos.Movies, deps.Repos.Users))
+
+	// Protected endpoints, admin only
+	// Versions of the prompt template the LLM ranks the reviews with: list, read, add and activate one
+	// These routes are handled by the PromptTemplate functions from the 'controller' package
+	router.GET("/prompttemplates", controller.GetPromptTemplates(deps.Repos.Prompts))
+	router.POST("/prompttemplates", controller.CreatePromptTemplate(deps.Repos.Prompts))
+	router.GET("/prompttemplates/:version", controller.GetPromptTemplate(deps.Repos.Prompts))
+	router.POST("/prompttemplates/:version/activate", controller.ActivatePromptTemplate(deps.Repos.Prompts))
+
+	// Protected endpoints
+	// Watch history and "not interested" dismissals of the logged in user, both are left out of the recommendations
+	// These routes are handled by the watch and dismissal functions from the 'controller' package
+	router.POST("/watchhistory/:imdb_id", controller.RecordWatch(deps.Repos.Interactions, deps.Repos.Movies))
+	router.GET("/watchhistory", controller.GetWatchHistory(deps.Repos.Interactions, deps.Repos.Movies))
+	router.DELETE("/watchhistory/:imdb_id", controller.DeleteWatch(deps.Repos.Interactions))
+	router.POST("/dismissals/:imdb_id", controller.DismissMovie(deps.Repos.Interactions, deps.Repos.Movies))
+	router.GET("/dismissals", controller.GetDismissals(deps.Repos.Interactions, deps.Repos.Movies))
+	router.DELETE("/dismissals/:imdb_id", controller.UndoDismissal(deps.Repos.Interactions))
+
+	// Protected endpoints
+	// Watchlist of the logged in user, the movies saved for later in the order the user chose
+	// These routes are handled by the watchlist functions from the 'controller' package
+	// Adding a movie is a positive signal for the recommendations
+	router.POST("/watchlist/:imdb_id", controller.AddToWatchlist(deps.Repos.Watchlists, deps.Repos.Movies, deps.Repos.Interactions))
+	router.GET("/watchlist", controller.GetWatchlist(deps.Repos.Watchlists, deps.Repos.Movies))
+	router.PATCH("/watchlist/:imdb_id", controller.MoveInWatchlist(deps.Repos.Watchlists))
+	router.DELETE("/watchlist/:imdb_id", controller.RemoveFromWatchlist(deps.Repos.Watchlists, deps.Repos.Interactions))
+
+	// Protected endpoints
+	// Ratings from 1 to 10 stars and reviews of the movies by the users, one per user and movie
+	// These routes are handled by the user review functions from the 'controller' package
+	// Every write updates the average rating of the movie, admins can delete any review with ?user_id=
+	router.GET("/movie/:imdb_id/reviews", controller.GetMovieReviews(deps.Repos.UserReviews, deps.Repos.Movies))
+	router.POST("/movie/:imdb_id/reviews", controller.CreateUserReview(deps.Repos.UserReviews, deps.Repos.Movies, deps.Repos.Users, deps.Repos.Interactions))
+	router.PUT("/movie/:imdb_id/reviews", controller.UpdateUserReview(deps.Repos.UserReviews, deps.Repos.Movies, deps.Repos.Interactions))
+	router.DELETE("/movie/:imdb_id/reviews", controller.DeleteUserReview(deps.Repos.UserReviews, deps.Repos.Movies, deps.Repos.Interactions))
+	router.GET("/users/:user_id/reviews", controller.GetUserReviews(deps.Repos.UserReviews))
+
+	// Protected endpoint, admin only
+	// Hits and misses of the rankings and classification caches since the server started
+	// This route is handled by the GetCacheStats function from the 'controller' package
+	router.GET("/cachestats", controller.GetCacheStats(deps.CacheStats))
+
+	// Define a POST route for the path "/logout"
+	// This route is handled by the LogoutUser function from the 'controller' package
+	// It revokes the tokens of the logged in user so they can not be used anymore
+	router.POST("/logout", controller.LogoutUser(deps.Repos.Users))
+
+	// Protected endpoint, admin only
+	// Define a PUT route for the path "/users/:user_id/role"
+	// This route is handled by the SetUserRole function from the 'controller' package
+	// Promotes a user to ADMIN or back to USER, registration always gives the USER role
+	router.PUT("/users/:user_id/role", controller.SetUserRole(deps.Repos.Users))
 }
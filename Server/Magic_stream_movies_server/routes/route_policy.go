@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/middleware"
+)
+
+// Roles allowed on every protected route.
+// Each route registered in SetUpProtectedRoutes needs an entry here, otherwise it answers 403.
+var ProtectedRoutePolicy = middleware.Policy{
+	"GET /movie/:imdb_id":                     {middleware.RoleUser, middleware.RoleAdmin},
+	"GET /recommendedmovies":                  {middleware.RoleUser, middleware.RoleAdmin},
+	"POST /logout":                            {middleware.RoleUser, middleware.RoleAdmin},
+	"POST /addmovie":                          {middleware.RoleAdmin},
+	"PATCH /updatereview/:imdb_id":            {middleware.RoleAdmin},
+	"GET /reviewjobs/:job_id":                 {middleware.RoleAdmin},
+	"PUT /movie/:imdb_id":                     {middleware.RoleAdmin},
+	"PATCH /movie/:imdb_id":                   {middleware.RoleAdmin},
+	"DELETE /movie/:imdb_id":                  {middleware.RoleAdmin},
+	"POST /movie/:imdb_id/restore":            {middleware.RoleAdmin},
+	"POST /movies/import":                     {middleware.RoleAdmin},
+	"GET /movies/export":                      {middleware.RoleAdmin},
+	"POST /rerankruns":                        {middleware.RoleAdmin},
+	"GET /rerankruns/:run_id":                 {middleware.RoleAdmin},
+	"GET /rerankruns/:run_id/changes":         {middleware.RoleAdmin},
+	"POST /rerankruns/:run_id/cancel":         {middleware.RoleAdmin},
+	"POST /rerankruns/:run_id/resume":         {middleware.RoleAdmin},
+	"GET /rankings":                           {middleware.RoleAdmin},
+	"POST /rankings":                          {middleware.RoleAdmin},
+	"PUT /rankings/:ranking_name":             {middleware.RoleAdmin},
+	"DELETE /rankings/:ranking_name":          {middleware.RoleAdmin},
+	"POST /genres":                            {middleware.RoleAdmin},
+	"PUT /genres/:genre_id":                   {middleware.RoleAdmin},
+	"POST /genres/:genre_id/merge":            {middleware.RoleAdmin},
+	"GET /prompttemplates":                    {middleware.RoleAdmin},
+	"POST /prompttemplates":                   {middleware.RoleAdmin},
+	"GET /prompttemplates/:version":           {middleware.RoleAdmin},
+	"POST /prompttemplates/:version/activate": {middleware.RoleAdmin},
+	"GET /cachestats":                         {middleware.RoleAdmin},
+	"POST /watchhistory/:imdb_id":             {middleware.RoleUser, middleware.RoleAdmin},
+	"GET /watchhistory":                       {middleware.RoleUser, middleware.RoleAdmin},
+	"DELETE /watchhistory/:imdb_id":           {middleware.RoleUser, middleware.RoleAdmin},
+	"POST /dismissals/:imdb_id":               {middleware.RoleUser, middleware.RoleAdmin},
+	"GET /dismissals":                         {middleware.RoleUser, middleware.RoleAdmin},
+	"DELETE /dismissals/:imdb_id":             {middleware.RoleUser, middleware.RoleAdmin},
+	"GET /movie/:imdb_id/reviews":             {middleware.RoleUser, middleware.RoleAdmin},
+	"POST /movie/:imdb_id/reviews":            {middleware.RoleUser, middleware.RoleAdmin},
+	"PUT /movie/:imdb_id/reviews":             {middleware.RoleUser, middleware.RoleAdmin},
+	"DELETE /movie/:imdb_id/reviews":          {middleware.RoleUser, middleware.RoleAdmin},
+	"GET /users/:user_id/reviews":             {middleware.RoleUser, middleware.RoleAdmin},
+	"POST /watchlist/:imdb_id":                {middleware.RoleUser, middleware.RoleAdmin},
+	"GET /watchlist":                          {middleware.RoleUser, middleware.RoleAdmin},
+	"PATCH /watchlist/:imdb_id":               {middleware.RoleUser, middleware.RoleAdmin},
+	"DELETE /watchlist/:imdb_id":              {middleware.RoleUser, middleware.RoleAdmin},
+	"PUT /users/:user_id/role":                {middleware.RoleAdmin},
+}
@@ -0,0 +1,308 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/controllers"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/middleware"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// routeRequest is a request the route answers without a 401 or 403 on the fixture of newTestAPI.
+// prepare, when set, creates what the request needs for the caller and returns the path to call.
+type routeRequest struct {
+	path    string
+	body    string
+	prepare func(api *testAPI, caller testUser) string
+}
+
+// routeRequests holds a successful request for every protected route, TestEveryProtectedRouteIsCovered
+// fails when a route is added without one
+var routeRequests = map[string]routeRequest{
+	"GET /movie/:imdb_id":    {path: "/movie/" + testMovieID},
+	"GET /recommendedmovies": {path: "/recommendedmovies"},
+	"POST /logout":           {path: "/logout"},
+	"POST /addmovie": {path: "/addmovie", body: `{"imdb_id":"tt0000009","title":"A New Movie","poster_path":"https://example.com/new.jpg","youtube_id":"ytnew",
+		"genre":[{"genre_id":1,"genre_name":"Drama"}],"ranking":{"ranking_value":2,"ranking_name":"Good"}}`},
+	"PATCH /updatereview/:imdb_id": {path: "/updatereview/" + testMovieID, body: `{"admin_review":"A fine movie"}`},
+	"GET /reviewjobs/:job_id": {prepare: func(api *testAPI, caller testUser) string {
+		job, err := api.deps.ReviewQueue.Enqueue(context.Background(), testMovieID, "A fine movie")
+		api.check(err)
+		return "/reviewjobs/" + job.ID.Hex()
+	}},
+	"PUT /movie/:imdb_id": {path: "/movie/" + testMovieID, body: `{"title":"The Renamed Movie","poster_path":"https://example.com/renamed.jpg","youtube_id":"ytrenamed",
+		"genre":[{"genre_id":2,"genre_name":"Comedy"}],"version":1}`},
+	"PATCH /movie/:imdb_id":        {path: "/movie/" + testMovieID, body: `{"title":"The Renamed Movie","version":1}`},
+	"DELETE /movie/:imdb_id":       {path: "/movie/" + testMovieID + "?version=1"},
+	"POST /movie/:imdb_id/restore": {path: "/movie/" + deletedMovieID + "/restore"},
+	"POST /movies/import": {path: "/movies/import?format=ndjson&dry_run=true", body: `{"imdb_id":"tt0000009","title":"A New Movie","poster_path":"https://example.com/new.jpg",` +
+		`"youtube_id":"ytnew","genre":[{"genre_id":1,"genre_name":"Drama"}]}`},
+	"GET /movies/export": {path: "/movies/export"},
+	"POST /rerankruns":   {path: "/rerankruns", body: `{"dry_run":true}`},
+	"GET /rerankruns/:run_id": {prepare: func(api *testAPI, caller testUser) string {
+		return "/rerankruns/" + api.insertRun(models.RerankCompleted)
+	}},
+	"GET /rerankruns/:run_id/changes": {prepare: func(api *testAPI, caller testUser) string {
+		return "/rerankruns/" + api.insertRun(models.RerankCompleted) + "/changes"
+	}},
+	"POST /rerankruns/:run_id/cancel": {prepare: func(api *testAPI, caller testUser) string {
+		// The run stays running on the review it can not classify
+		api.check(api.deps.Repos.Movies.SetPendingReview(context.Background(), testMovieID, holdReview))
+		run, err := api.deps.Reranker.Start(context.Background(), true, 1)
+		api.check(err)
+		return "/rerankruns/" + run.ID.Hex() + "/cancel"
+	}},
+	"POST /rerankruns/:run_id/resume": {prepare: func(api *testAPI, caller testUser) string {
+		return "/rerankruns/" + api.insertRun(models.RerankCanceled) + "/resume"
+	}},
+	"GET /rankings":                           {path: "/rankings"},
+	"POST /rankings":                          {path: "/rankings", body: `{"ranking_value":6,"ranking_name":"Awful"}`},
+	"PUT /rankings/:ranking_name":             {path: "/rankings/Okay", body: `{"ranking_value":3,"ranking_name":"Average"}`},
+	"DELETE /rankings/:ranking_name":          {path: "/rankings/Okay"},
+	"POST /genres":                            {path: "/genres", body: `{"genre_name":"Horror"}`},
+	"PUT /genres/:genre_id":                   {path: "/genres/2", body: `{"genre_name":"Comedies"}`},
+	"POST /genres/:genre_id/merge":            {path: "/genres/3/merge", body: `{"into":1}`},
+	"GET /prompttemplates":                    {path: "/prompttemplates"},
+	"POST /prompttemplates":                   {path: "/prompttemplates", body: `{"template":"Rank the review with {rankings}: "}`},
+	"GET /prompttemplates/:version":           {path: "/prompttemplates/1"},
+	"POST /prompttemplates/:version/activate": {path: "/prompttemplates/1/activate"},
+	"GET /cachestats":                         {path: "/cachestats"},
+	"POST /watchhistory/:imdb_id":             {path: "/watchhistory/" + testMovieID},
+	"GET /watchhistory":                       {path: "/watchhistory"},
+	"DELETE /watchhistory/:imdb_id": {prepare: func(api *testAPI, caller testUser) string {
+		api.record(caller, models.InteractionWatch)
+		return "/watchhistory/" + testMovieID
+	}},
+	"POST /dismissals/:imdb_id": {path: "/dismissals/" + testMovieID},
+	"GET /dismissals":           {path: "/dismissals"},
+	"DELETE /dismissals/:imdb_id": {prepare: func(api *testAPI, caller testUser) string {
+		api.record(caller, models.InteractionDismiss)
+		return "/dismissals/" + testMovieID
+	}},
+	"GET /movie/:imdb_id/reviews":  {path: "/movie/" + testMovieID + "/reviews"},
+	"POST /movie/:imdb_id/reviews": {path: "/movie/" + testMovieID + "/reviews", body: `{"rating":8,"review":"Nice"}`},
+	"PUT /movie/:imdb_id/reviews": {body: `{"rating":6,"review":"Fine"}`, prepare: func(api *testAPI, caller testUser) string {
+		api.insertReview(caller)
+		return "/movie/" + testMovieID + "/reviews"
+	}},
+	"DELETE /movie/:imdb_id/reviews": {prepare: func(api *testAPI, caller testUser) string {
+		api.insertReview(caller)
+		return "/movie/" + testMovieID + "/reviews"
+	}},
+	"GET /users/:user_id/reviews": {prepare: func(api *testAPI, caller testUser) string {
+		return "/users/" + api.user.id + "/reviews"
+	}},
+	"POST /watchlist/:imdb_id": {path: "/watchlist/" + testMovieID},
+	"GET /watchlist":           {path: "/watchlist"},
+	"PATCH /watchlist/:imdb_id": {body: `{"position":1}`, prepare: func(api *testAPI, caller testUser) string {
+		_, err := api.deps.Repos.Watchlists.Add(context.Background(), caller.id, testMovieID)
+		api.check(err)
+		return "/watchlist/" + testMovieID
+	}},
+	"DELETE /watchlist/:imdb_id": {prepare: func(api *testAPI, caller testUser) string {
+		_, err := api.deps.Repos.Watchlists.Add(context.Background(), caller.id, testMovieID)
+		api.check(err)
+		return "/watchlist/" + testMovieID
+	}},
+	"PUT /users/:user_id/role": {body: `{"role":"ADMIN"}`, prepare: func(api *testAPI, caller testUser) string {
+		return "/users/" + api.other.id + "/role"
+	}},
+}
+
+// protectedRoutes returns the "METHOD /path" of the routes registered by SetUpProtectedRoutes
+func protectedRoutes(api *testAPI) []string {
+	public := gin.New()
+	SetUpUnprotectedRoutes(public, api.deps)
+
+	unprotected := []string{"GET /hello"}
+
+	for _, route := range public.Routes() {
+		unprotected = append(unprotected, route.Method+" "+route.Path)
+	}
+
+	var protected []string
+
+	for _, route := range api.router.Routes() {
+		if key := route.Method + " " + route.Path; !slices.Contains(unprotected, key) {
+			protected = append(protected, key)
+		}
+	}
+
+	return protected
+}
+
+func TestEveryProtectedRouteHasAPolicy(t *testing.T) {
+	api := newTestAPI(t)
+	protected := protectedRoutes(api)
+
+	for _, route := range protected {
+		if len(ProtectedRoutePolicy[route]) == 0 {
+			t.Errorf("%s has no entry in ProtectedRoutePolicy, it answers 403 to everyone", route)
+		}
+	}
+
+	// An entry without its route is a typo hiding a missing entry
+	for route := range ProtectedRoutePolicy {
+		if !slices.Contains(protected, route) {
+			t.Errorf("ProtectedRoutePolicy has an entry for %s which is not a registered route", route)
+		}
+	}
+}
+
+func TestEveryProtectedRouteIsCovered(t *testing.T) {
+	for _, route := range protectedRoutes(newTestAPI(t)) {
+		if _, ok := routeRequests[route]; !ok {
+			t.Errorf("%s has no request in routeRequests, the role checks do not cover it", route)
+		}
+	}
+}
+
+// TestProtectedRoutePolicy calls every route of the policy without a token, as a USER and as an ADMIN:
+// 401 without a token, let through (neither 401 nor 403) for an allowed role and 403 otherwise
+func TestProtectedRoutePolicy(t *testing.T) {
+	routes := make([]string, 0, len(ProtectedRoutePolicy))
+
+	for route := range ProtectedRoutePolicy {
+		routes = append(routes, route)
+	}
+
+	slices.Sort(routes)
+
+	for _, route := range routes {
+		method, _, _ := strings.Cut(route, " ")
+		req := routeRequests[route]
+
+		for _, role := range []string{"", middleware.RoleUser, middleware.RoleAdmin} {
+			name := role
+
+			if name == "" {
+				name = "NO_TOKEN"
+			}
+
+			t.Run(route+"/"+name, func(t *testing.T) {
+				api := newTestAPI(t)
+
+				var caller testUser
+
+				switch role {
+				case middleware.RoleUser:
+					caller = api.user
+				case middleware.RoleAdmin:
+					caller = api.admin
+				}
+
+				path := req.path
+
+				if req.prepare != nil {
+					path = req.prepare(api, caller)
+				}
+
+				w := api.do(method, path, req.body, caller.token)
+
+				switch {
+				case role == "":
+					if w.Code != http.StatusUnauthorized {
+						t.Errorf("got %d without a token, want 401: %s", w.Code, w.Body.String())
+					}
+				case slices.Contains(ProtectedRoutePolicy[route], role):
+					if w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden {
+						t.Errorf("got %d for an allowed role: %s", w.Code, w.Body.String())
+					}
+				default:
+					if w.Code != http.StatusForbidden {
+						t.Errorf("got %d for a role not allowed, want 403: %s", w.Code, w.Body.String())
+					}
+				}
+			})
+		}
+	}
+}
+
+// expectAdmin fails the test unless the token is let through the admin routes, it changes the role of
+// the fixture user to the USER role they already have
+func (api *testAPI) expectAdmin(token string, status int) {
+	api.t.Helper()
+
+	api.expect(api.do(http.MethodPut, "/users/"+api.user.id+"/role", `{"role":"USER"}`, token), status, nil)
+}
+
+func TestRegisterIgnoresTheRole(t *testing.T) {
+	api := newTestAPI(t)
+
+	w := api.do(http.MethodPost, "/register", `{"first_name":"Eve","last_name":"Smith","email":"eve@example.com","password":"secret1",
+		"role":"ADMIN","favourite_genres":[{"genre_id":1,"genre_name":"Drama"}]}`, "")
+	api.expect(w, http.StatusCreated, nil)
+
+	var login models.UserResponse
+	api.expect(api.do(http.MethodPost, "/login", `{"email":"eve@example.com","password":"secret1"}`, ""), http.StatusOK, &login)
+
+	if login.Role != middleware.RoleUser {
+		t.Fatalf("registered with the role %q, want %q", login.Role, middleware.RoleUser)
+	}
+
+	api.expectAdmin(login.Token, http.StatusForbidden)
+}
+
+func TestSetUserRole(t *testing.T) {
+	api := newTestAPI(t)
+	path := "/users/" + api.other.id + "/role"
+
+	api.expect(api.do(http.MethodPut, path, `{"role":"ADMIN"}`, api.admin.token), http.StatusOK, nil)
+
+	// The tokens carrying the previous role are revoked
+	api.expect(api.do(http.MethodGet, "/movie/"+testMovieID, "", api.other.token), http.StatusUnauthorized, nil)
+
+	promoted := api.login(api.other.id)
+	api.expectAdmin(promoted.token, http.StatusOK)
+
+	api.expect(api.do(http.MethodPut, path, `{"role":"OWNER"}`, api.admin.token), http.StatusBadRequest, nil)
+	api.expect(api.do(http.MethodPut, "/users/"+api.admin.id+"/role", `{"role":"USER"}`, api.admin.token), http.StatusBadRequest, nil)
+	api.expect(api.do(http.MethodPut, "/users/unknown/role", `{"role":"ADMIN"}`, api.admin.token), http.StatusNotFound, nil)
+}
+
+func TestPromoteAdmin(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	api.check(controllers.PromoteAdmin(ctx, api.deps.Repos.Users, "other@example.com"))
+
+	// The tokens carrying the previous role are revoked
+	api.expect(api.do(http.MethodGet, "/movie/"+testMovieID, "", api.other.token), http.StatusUnauthorized, nil)
+
+	promoted := api.login(api.other.id)
+	api.expectAdmin(promoted.token, http.StatusOK)
+
+	// Promoting an admin again keeps their tokens
+	api.check(controllers.PromoteAdmin(ctx, api.deps.Repos.Users, "other@example.com"))
+	api.expectAdmin(promoted.token, http.StatusOK)
+
+	if err := controllers.PromoteAdmin(ctx, api.deps.Repos.Users, "unknown@example.com"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound for an email nobody registered with", err)
+	}
+}
+
+// insertRun stores a re-ranking run in the state and returns its id
+func (api *testAPI) insertRun(status string) string {
+	run, err := api.deps.Repos.Reranks.Insert(context.Background(), models.RerankRun{Status: status, Dry_run: true, Concurrency: 1})
+	api.check(err)
+
+	return run.ID.Hex()
+}
+
+// record stores an interaction of the caller with the test movie
+func (api *testAPI) record(caller testUser, kind string) {
+	api.check(api.deps.Repos.Interactions.Record(context.Background(), models.Interaction{User_id: caller.id, Imdb_id: testMovieID, Kind: kind}))
+}
+
+// insertReview stores a review of the test movie by the caller
+func (api *testAPI) insertReview(caller testUser) {
+	_, err := api.deps.Repos.UserReviews.Insert(context.Background(), models.UserReview{User_id: caller.id, Imdb_id: testMovieID, Rating: 7})
+	api.check(err)
+}
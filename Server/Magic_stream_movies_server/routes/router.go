@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/cache"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/jobs"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/recommend"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/search"
+	"github.com/gin-gonic/gin" // The Gin web framework
+)
+
+// Dependencies holds everything the handlers are built with.
+// main wires the Mongo implementations, the in-memory ones run the same API without a database.
+type Dependencies struct {
+	Config *config.Config
+	Repos  *repository.Repositories
+	Search search.Index
+	// Ranks the admin reviews in the background with the classifier chosen by LLM_PROVIDER
+	ReviewQueue *jobs.ReviewQueue
+	// Re-ranks every admin review after the rankings changed
+	Reranker *jobs.Reranker
+	// Hit and miss counters of the rankings and classification caches
+	CacheStats *cache.Stats
+	// Recommends movies from the interactions of the users and their favourite genres
+	Recommender *recommend.Recommender
+}
+
+// SetUpRouter builds the whole HTTP API on top of the given dependencies.
+func SetUpRouter(deps *Dependencies) *gin.Engine {
+	// Initialize the Gin router with default middleware (Logger and Recovery)
+	router := gin.Default()
+
+	// Define a GET route for the path "/hello"
+	// When a request hits this endpoint, the anonymous function (handler) is executed
+	router.GET("/hello", func(c *gin.Context) {
+		// c.String(200, "Hello, Magic_stream_movies!") sends a simple text response
+		// 200 is the HTTP Status Code for "OK"
+		c.String(200, "Hello, Magic_stream_movies!")
+	})
+
+	// Unprotected routes are registered first so the authentication middleware does not apply to them
+	SetUpUnprotectedRoutes(router, deps)
+	SetUpProtectedRoutes(router, deps)
+
+	return router
+}
@@ -7,22 +7,36 @@ import (
 	"github.com/gin-gonic/gin" // The Gin web framework
 )
 
-func SetUpUnprotectedRoutes(router *gin.Engine) {
+func SetUpUnprotectedRoutes(router *gin.Engine, deps *Dependencies) {
 
 	// Define a GET route for the path "/movies"
 	// This route is handled by the GetMovies function from the imported 'controller' package
 	// Retrieves a list of all movies by calling the database functions.
-	router.GET("/movies", controller.GetMovies())
+	router.GET("/movies", controller.GetMovies(deps.Repos.Movies))
 
 	// Define a POST route for the path "/register"
 	// This route is handled by the RegisterUser function from the 'controller' package
 	// Adds a user record to the users collection in the database functions.
-	router.POST("/register", controller.RegisterUser())
+	// The favourite genres must be in the genres collection.
+	router.POST("/register", controller.RegisterUser(deps.Repos.Users, deps.Repos.Genres))
 
 	// Define a POST route for the path "/login"
 	// This route is handled by the LoginUser function from the 'controller' package
 	// Logins a registered user using tokens to the application
-	router.POST("/login", controller.LoginUser())
+	router.POST("/login", controller.LoginUser(deps.Config, deps.Repos.Users))
 
-	
+	// Define a POST route for the path "/refresh"
+	// This route is handled by the RefreshToken function from the 'controller' package
+	// Exchanges the refresh token returned at login for a new pair of tokens
+	router.POST("/refresh", controller.RefreshToken(deps.Config, deps.Repos.Users))
+
+	// Define a GET route for the path "/search"
+	// This route is handled by the SearchMovies function from the 'controller' package
+	// Finds movies by words of their title and admin review, e.g. /search?q=matrix
+	router.GET("/search", controller.SearchMovies(deps.Search))
+
+	// Define a GET route for the path "/genres"
+	// This route is handled by the GetGenres function from the 'controller' package
+	// Lists the genres movies and users can have, public so the registration form can offer them
+	router.GET("/genres", controller.GetGenres(deps.Repos.Genres))
 }
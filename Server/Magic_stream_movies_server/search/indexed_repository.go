@@ -0,0 +1,223 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// IndexedMovieRepository wraps a MovieRepository and keeps the search index up to date
+// after every successful write, so the handlers do not have to know about the index.
+// The write is done once it is stored: an index failure is logged and the index is rebuilt at the next start.
+type IndexedMovieRepository struct {
+	repository.MovieRepository
+	index Index
+}
+
+func NewIndexedMovieRepository(movies repository.MovieRepository, index Index) *IndexedMovieRepository {
+	return &IndexedMovieRepository{MovieRepository: movies, index: index}
+}
+
+// reindex loads the stored version of the movie and indexes it
+func (r *IndexedMovieRepository) reindex(ctx context.Context, imdbID string) {
+	movie, err := r.MovieRepository.FindByImdbID(ctx, imdbID)
+
+	// Soft deleted movies are not in the index
+	if errors.Is(err, repository.ErrNotFound) {
+		return
+	}
+
+	if err != nil {
+		log.Printf("Failed to load the movie %s to index it: %v", imdbID, err)
+		return
+	}
+
+	r.indexMovie(ctx, movie)
+}
+
+// indexMovie indexes the stored movie, logging a failure
+func (r *IndexedMovieRepository) indexMovie(ctx context.Context, movie models.Movie) {
+	if err := r.index.Index(ctx, movie); err != nil {
+		log.Printf("Failed to index the movie %s: %v", movie.Imbd_id, err)
+	}
+}
+
+func (r *IndexedMovieRepository) Insert(ctx context.Context, movie models.Movie) (bson.ObjectID, error) {
+	id, err := r.MovieRepository.Insert(ctx, movie)
+
+	if err != nil {
+		return id, err
+	}
+
+	r.reindex(ctx, movie.Imbd_id)
+
+	return id, nil
+}
+
+func (r *IndexedMovieRepository) UpdateReview(ctx context.Context, imdbID string, review string, ranking models.Ranking, audit models.RankingAudit) error {
+	if err := r.MovieRepository.UpdateReview(ctx, imdbID, review, ranking, audit); err != nil {
+		return err
+	}
+
+	r.reindex(ctx, imdbID)
+
+	return nil
+}
+
+func (r *IndexedMovieRepository) SetPendingReview(ctx context.Context, imdbID string, review string) error {
+	if err := r.MovieRepository.SetPendingReview(ctx, imdbID, review); err != nil {
+		return err
+	}
+
+	r.reindex(ctx, imdbID)
+
+	return nil
+}
+
+func (r *IndexedMovieRepository) FailReview(ctx context.Context, imdbID string, review string) error {
+	if err := r.MovieRepository.FailReview(ctx, imdbID, review); err != nil {
+		return err
+	}
+
+	r.reindex(ctx, imdbID)
+
+	return nil
+}
+
+func (r *IndexedMovieRepository) Replace(ctx context.Context, movie models.Movie, expectedVersion int64) (models.Movie, error) {
+	updated, err := r.MovieRepository.Replace(ctx, movie, expectedVersion)
+
+	if err != nil {
+		return updated, err
+	}
+
+	r.indexMovie(ctx, updated)
+
+	return updated, nil
+}
+
+func (r *IndexedMovieRepository) SoftDelete(ctx context.Context, imdbID string, expectedVersion int64) error {
+	if err := r.MovieRepository.SoftDelete(ctx, imdbID, expectedVersion); err != nil {
+		return err
+	}
+
+	if err := r.index.Remove(ctx, imdbID); err != nil {
+		log.Printf("Failed to remove the movie %s from the index: %v", imdbID, err)
+	}
+
+	return nil
+}
+
+func (r *IndexedMovieRepository) Restore(ctx context.Context, imdbID string) (models.Movie, error) {
+	restored, err := r.MovieRepository.Restore(ctx, imdbID)
+
+	if err != nil {
+		return restored, err
+	}
+
+	r.indexMovie(ctx, restored)
+
+	return restored, nil
+}
+
+func (r *IndexedMovieRepository) UpsertMany(ctx context.Context, movies []models.Movie) ([]repository.UpsertResult, error) {
+	results, err := r.MovieRepository.UpsertMany(ctx, movies)
+
+	if err != nil {
+		return results, err
+	}
+
+	var upserted []string
+
+	for i, result := range results {
+		if result.Err == nil {
+			upserted = append(upserted, movies[i].Imbd_id)
+		}
+	}
+
+	r.reindexAll(ctx, upserted)
+
+	return results, nil
+}
+
+func (r *IndexedMovieRepository) RemapRanking(ctx context.Context, from string, ranking models.Ranking) ([]string, error) {
+	remapped, err := r.MovieRepository.RemapRanking(ctx, from, ranking)
+
+	if err != nil {
+		return remapped, err
+	}
+
+	r.reindexAll(ctx, remapped)
+
+	return remapped, nil
+}
+
+func (r *IndexedMovieRepository) RenameGenre(ctx context.Context, genre models.Genre) ([]string, error) {
+	renamed, err := r.MovieRepository.RenameGenre(ctx, genre)
+
+	if err != nil {
+		return renamed, err
+	}
+
+	r.reindexAll(ctx, renamed)
+
+	return renamed, nil
+}
+
+func (r *IndexedMovieRepository) MergeGenre(ctx context.Context, from int, into models.Genre) ([]string, error) {
+	merged, err := r.MovieRepository.MergeGenre(ctx, from, into)
+
+	if err != nil {
+		return merged, err
+	}
+
+	r.reindexAll(ctx, merged)
+
+	return merged, nil
+}
+
+func (r *IndexedMovieRepository) SetRatingStats(ctx context.Context, imdbID string, stats models.RatingStats, revision int64) error {
+	if err := r.MovieRepository.SetRatingStats(ctx, imdbID, stats, revision); err != nil {
+		return err
+	}
+
+	r.reindex(ctx, imdbID)
+
+	return nil
+}
+
+// reindexAll loads the movies changed by a bulk update in one query and indexes them,
+// the ones not found are soft deleted and removed from the index
+func (r *IndexedMovieRepository) reindexAll(ctx context.Context, imdbIDs []string) {
+	if len(imdbIDs) == 0 {
+		return
+	}
+
+	movies, err := r.MovieRepository.FindByImdbIDs(ctx, imdbIDs)
+
+	if err != nil {
+		log.Printf("Failed to load %d movies to index them: %v", len(imdbIDs), err)
+		return
+	}
+
+	found := make(map[string]bool, len(movies))
+
+	for _, movie := range movies {
+		found[movie.Imbd_id] = true
+		r.indexMovie(ctx, movie)
+	}
+
+	for _, imdbID := range imdbIDs {
+		if found[imdbID] {
+			continue
+		}
+
+		if err := r.index.Remove(ctx, imdbID); err != nil {
+			log.Printf("Failed to remove the movie %s from the index: %v", imdbID, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
+)
+
+// brokenIndex fails every call
+type brokenIndex struct{}
+
+func (brokenIndex) Search(ctx context.Context, query string, limit int) ([]models.SearchResult, error) {
+	return nil, errors.New("index unavailable")
+}
+
+func (brokenIndex) Index(ctx context.Context, movie models.Movie) error {
+	return errors.New("index unavailable")
+}
+
+func (brokenIndex) Remove(ctx context.Context, imdbID string) error {
+	return errors.New("index unavailable")
+}
+
+func TestIndexFailuresDoNotFailTheWrites(t *testing.T) {
+	ctx := context.Background()
+	movies := NewIndexedMovieRepository(repository.NewMemoryMovieRepository(), brokenIndex{})
+	movie := models.Movie{Imbd_id: "tt0000001", Title: "The First Movie", Ranking: models.Ranking{Ranking_value: 2, Ranking_name: "Good"}}
+
+	if _, err := movies.Insert(ctx, movie); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	stored, err := movies.FindByImdbID(ctx, "tt0000001")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := movies.SoftDelete(ctx, stored.Imbd_id, stored.Version); err != nil {
+		t.Fatalf("SoftDelete: %v", err)
+	}
+
+	if _, err := movies.Restore(ctx, stored.Imbd_id); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if _, err := movies.UpsertMany(ctx, []models.Movie{movie}); err != nil {
+		t.Fatalf("UpsertMany: %v", err)
+	}
+
+	if _, err := movies.RemapRanking(ctx, "Good", models.Ranking{Ranking_value: 1, Ranking_name: "Excellent"}); err != nil {
+		t.Fatalf("RemapRanking: %v", err)
+	}
+}
+
+// countingMovies counts the movie loads and reports the movie tt0000009, which is not stored, as remapped too
+type countingMovies struct {
+	*repository.MemoryMovieRepository
+	finds, bulkFinds int
+}
+
+func (m *countingMovies) FindByImdbID(ctx context.Context, imdbID string) (models.Movie, error) {
+	m.finds++
+	return m.MemoryMovieRepository.FindByImdbID(ctx, imdbID)
+}
+
+func (m *countingMovies) FindByImdbIDs(ctx context.Context, imdbIDs []string) ([]models.Movie, error) {
+	m.bulkFinds++
+	return m.MemoryMovieRepository.FindByImdbIDs(ctx, imdbIDs)
+}
+
+func (m *countingMovies) RemapRanking(ctx context.Context, from string, ranking models.Ranking) ([]string, error) {
+	remapped, err := m.MemoryMovieRepository.RemapRanking(ctx, from, ranking)
+	return append(remapped, "tt0000009"), err
+}
+
+func TestBulkUpdatesReindexInOneLoad(t *testing.T) {
+	ctx := context.Background()
+	good := models.Ranking{Ranking_value: 2, Ranking_name: "Good"}
+	stored := &countingMovies{MemoryMovieRepository: repository.NewMemoryMovieRepository(
+		models.Movie{Imbd_id: "tt0000001", Title: "The First Movie", Ranking: good},
+		models.Movie{Imbd_id: "tt0000002", Title: "The Second Movie", Ranking: good},
+	)}
+
+	index := NewMemoryIndex()
+	index.Index(ctx, models.Movie{Imbd_id: "tt0000009", Title: "The Deleted Movie", Ranking: good})
+
+	movies := NewIndexedMovieRepository(stored, index)
+
+	if _, err := movies.RemapRanking(ctx, "Good", models.Ranking{Ranking_value: 1, Ranking_name: "Excellent"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.bulkFinds != 1 || stored.finds != 0 {
+		t.Fatalf("got %d bulk loads and %d single loads, want 1 bulk load", stored.bulkFinds, stored.finds)
+	}
+
+	results, err := index.Search(ctx, "movie", 10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want the 2 stored movies and not the deleted one", len(results))
+	}
+
+	for _, result := range results {
+		if result.Movie.Ranking.Ranking_name != "Excellent" {
+			t.Fatalf("got the ranking %+v for %s, want the remapped ranking", result.Movie.Ranking, result.Movie.Imbd_id)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// A title word counts three times as much as an admin review word
+const (
+	titleWeight  = 3.0
+	reviewWeight = 1.0
+)
+
+// MemoryIndex is a pure Go inverted index of the movies, kept in the process memory.
+// It matches prefixes and tolerates typos (see matchWeight) without any external service.
+type MemoryIndex struct {
+	mu sync.RWMutex
+	// movies by imdb_id
+	movies map[string]models.Movie
+	// postings maps every word to the weighted number of occurrences per imdb_id
+	postings map[string]map[string]float64
+	// words of every indexed movie, to remove its postings
+	movieWords map[string][]string
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		movies:     map[string]models.Movie{},
+		postings:   map[string]map[string]float64{},
+		movieWords: map[string][]string{},
+	}
+}
+
+func (idx *MemoryIndex) Index(ctx context.Context, movie models.Movie) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(movie.Imbd_id)
+
+	frequencies := map[string]float64{}
+
+	for _, t := range tokenize(movie.Title) {
+		frequencies[t.word] += titleWeight
+	}
+
+	for _, t := range tokenize(movie.Admin_review) {
+		frequencies[t.word] += reviewWeight
+	}
+
+	for word, frequency := range frequencies {
+		if idx.postings[word] == nil {
+			idx.postings[word] = map[string]float64{}
+		}
+		idx.postings[word][movie.Imbd_id] = frequency
+		idx.movieWords[movie.Imbd_id] = append(idx.movieWords[movie.Imbd_id], word)
+	}
+
+	idx.movies[movie.Imbd_id] = movie
+
+	return nil
+}
+
+func (idx *MemoryIndex) Remove(ctx context.Context, imdbID string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(imdbID)
+
+	return nil
+}
+
+// remove must be called with the lock held
+func (idx *MemoryIndex) remove(imdbID string) {
+	for _, word := range idx.movieWords[imdbID] {
+		delete(idx.postings[word], imdbID)
+
+		if len(idx.postings[word]) == 0 {
+			delete(idx.postings, word)
+		}
+	}
+
+	delete(idx.movieWords, imdbID)
+	delete(idx.movies, imdbID)
+}
+
+// Search scores every movie with a TF-IDF sum over the query terms.
+// Each term contributes through the indexed words it matches, weighted by the kind of match,
+// and movies matching every term get a bonus so they come first.
+func (idx *MemoryIndex) Search(ctx context.Context, query string, limit int) ([]models.SearchResult, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	matcher := newQueryMatcher(query)
+
+	scores := map[string]float64{}
+	matchedTerms := map[string]int{}
+	movieCount := float64(len(idx.movies))
+
+	for i, term := range matcher.terms {
+		// Best score of this term per movie, a term matching several words only counts once
+		termScores := map[string]float64{}
+
+		for word, postings := range idx.postings {
+			weight := matchWeight(term, word, i == len(matcher.terms)-1)
+
+			if weight == 0 {
+				continue
+			}
+
+			idf := math.Log(1 + movieCount/float64(len(postings)))
+
+			for imdbID, frequency := range postings {
+				termScores[imdbID] = max(termScores[imdbID], weight*idf*(1+math.Log(frequency)))
+			}
+		}
+
+		for imdbID, score := range termScores {
+			scores[imdbID] += score
+			matchedTerms[imdbID]++
+		}
+	}
+
+	results := make([]models.SearchResult, 0, len(scores))
+
+	for imdbID, score := range scores {
+		if matchedTerms[imdbID] == len(matcher.terms) {
+			score *= 2
+		}
+
+		movie := idx.movies[imdbID]
+
+		results = append(results, models.SearchResult{
+			Movie:      movie,
+			Score:      math.Round(score*1000) / 1000,
+			Highlights: matcher.highlights(movie.Title, movie.Admin_review),
+		})
+	}
+
+	sortResults(results)
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// sortResults orders by score, then by title so equal scores come in a stable order
+func sortResults(results []models.SearchResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Movie.Title < results[j].Movie.Title
+	})
+}
@@ -0,0 +1,137 @@
+package search
+
+import (
+	"context"
+	"math"
+	"regexp"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoIndex searches the movies collection through a Mongo text index on title and admin_review.
+// The text index handles stemming, prefixes of the query words are found with a regular expression.
+// Typos are only tolerated among those candidates, use the memory backend for full typo tolerance.
+// Soft deleted movies are never returned.
+type MongoIndex struct {
+	collection *mongo.Collection
+}
+
+func NewMongoIndex(collection *mongo.Collection) *MongoIndex {
+	return &MongoIndex{collection: collection}
+}
+
+// EnsureIndexes creates the text index, a title word weighs three times an admin review word
+func (idx *MongoIndex) EnsureIndexes(ctx context.Context) error {
+	_, err := idx.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "title", Value: "text"}, {Key: "admin_review", Value: "text"}},
+		Options: options.Index().
+			SetName("movies_text").
+			SetWeights(bson.D{{Key: "title", Value: titleWeight}, {Key: "admin_review", Value: reviewWeight}}),
+	})
+
+	return err
+}
+
+// Index is a no-op, Mongo keeps the text index up to date
+func (idx *MongoIndex) Index(ctx context.Context, movie models.Movie) error {
+	return nil
+}
+
+// Remove is a no-op, Mongo keeps the text index up to date
+func (idx *MongoIndex) Remove(ctx context.Context, imdbID string) error {
+	return nil
+}
+
+// scoredMovie is a movie decoded with the text score projected by $meta
+type scoredMovie struct {
+	models.Movie `bson:",inline"`
+	Score        float64 `bson:"score"`
+}
+
+func (idx *MongoIndex) Search(ctx context.Context, query string, limit int) ([]models.SearchResult, error) {
+	matcher := newQueryMatcher(query)
+
+	if len(matcher.terms) == 0 {
+		return []models.SearchResult{}, nil
+	}
+
+	candidates := map[string]scoredMovie{}
+
+	// Full words, scored by the text index
+	textOptions := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetLimit(int64(limit))
+
+	if err := idx.collect(ctx, bson.M{"$text": bson.M{"$search": query}, "deleted_at": bson.M{"$exists": false}}, textOptions, candidates); err != nil {
+		return nil, err
+	}
+
+	// Words starting with one of the terms
+	var prefixes bson.A
+
+	for _, term := range matcher.terms {
+		pattern := bson.Regex{Pattern: `\b` + regexp.QuoteMeta(term), Options: "i"}
+		prefixes = append(prefixes, bson.M{"title": pattern}, bson.M{"admin_review": pattern})
+	}
+
+	if err := idx.collect(ctx, bson.M{"$or": prefixes, "deleted_at": bson.M{"$exists": false}}, options.Find().SetLimit(int64(limit)), candidates); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.SearchResult, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		// Same word matching as the memory index, on top of the text score
+		score := candidate.Score
+
+		for _, word := range words(candidate.Title) {
+			score += titleWeight * matcher.weight(word)
+		}
+
+		for _, word := range words(candidate.Admin_review) {
+			score += reviewWeight * matcher.weight(word)
+		}
+
+		results = append(results, models.SearchResult{
+			Movie:      candidate.Movie,
+			Score:      math.Round(score*1000) / 1000,
+			Highlights: matcher.highlights(candidate.Title, candidate.Admin_review),
+		})
+	}
+
+	sortResults(results)
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// collect adds the movies found by the filter to the candidates, keeping the best text score
+func (idx *MongoIndex) collect(ctx context.Context, filter bson.M, findOptions *options.FindOptionsBuilder, candidates map[string]scoredMovie) error {
+	cursor, err := idx.collection.Find(ctx, filter, findOptions)
+
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var found []scoredMovie
+
+	if err := cursor.All(ctx, &found); err != nil {
+		return err
+	}
+
+	for _, movie := range found {
+		if existing, ok := candidates[movie.Imbd_id]; !ok || movie.Score > existing.Score {
+			candidates[movie.Imbd_id] = movie
+		}
+	}
+
+	return nil
+}
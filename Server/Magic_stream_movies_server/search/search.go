@@ -0,0 +1,34 @@
+package search
+
+import (
+	"context"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+// Backends selected by the SEARCH_BACKEND setting
+const (
+	BackendMemory = "memory"
+	BackendMongo  = "mongo"
+)
+
+// Index finds movies by words of their title and admin review
+type Index interface {
+	// Search returns at most limit movies matching the query, most relevant first
+	Search(ctx context.Context, query string, limit int) ([]models.SearchResult, error)
+	// Index adds the movie to the index or replaces it
+	Index(ctx context.Context, movie models.Movie) error
+	// Remove drops the movie from the index
+	Remove(ctx context.Context, imdbID string) error
+}
+
+// Rebuild indexes every movie, used at startup by the backends that are not persisted
+func Rebuild(ctx context.Context, index Index, movies []models.Movie) error {
+	for _, movie := range movies {
+		if err := index.Index(ctx, movie); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
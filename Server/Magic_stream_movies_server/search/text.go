@@ -0,0 +1,249 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Weight of a word matching a query term, depending on how it matches
+const (
+	exactMatchWeight  = 1.0
+	prefixMatchWeight = 0.8
+	fuzzyMatchWeight  = 0.6
+)
+
+// Longest admin review excerpt returned in the highlights
+const snippetLength = 160
+
+// token is a word of a text with its byte offsets, used to highlight it
+type token struct {
+	word       string
+	start, end int
+}
+
+// tokenize splits a text on anything that is not a letter or a digit and lower-cases the words
+func tokenize(text string) []token {
+	var tokens []token
+
+	start := -1
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 {
+			tokens = append(tokens, token{word: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, token{word: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+
+	return tokens
+}
+
+// words returns the distinct lower-cased words of a text
+func words(text string) []string {
+	seen := map[string]bool{}
+
+	var result []string
+
+	for _, t := range tokenize(text) {
+		if !seen[t.word] {
+			seen[t.word] = true
+			result = append(result, t.word)
+		}
+	}
+
+	return result
+}
+
+// matchWeight tells how well a word of a document matches a query term, 0 if it does not.
+// The last query term is also matched as a prefix ("matr" finds "matrix") and words close
+// enough to the term are accepted as typos: one edit for terms of 4 letters or more, two from 8 letters.
+func matchWeight(term, word string, prefix bool) float64 {
+	if word == term {
+		return exactMatchWeight
+	}
+
+	termLength := utf8.RuneCountInString(term)
+
+	if prefix && termLength >= 2 && strings.HasPrefix(word, term) {
+		return prefixMatchWeight
+	}
+
+	maxEdits := 0
+
+	switch {
+	case termLength >= 8:
+		maxEdits = 2
+	case termLength >= 4:
+		maxEdits = 1
+	}
+
+	if maxEdits > 0 && editDistance(term, word, maxEdits) <= maxEdits {
+		return fuzzyMatchWeight
+	}
+
+	return 0
+}
+
+// editDistance is the Damerau-Levenshtein distance (optimal string alignment) between a and b:
+// insertions, deletions, substitutions and swaps of two adjacent letters count as one edit.
+// It stops counting past limit.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+
+	if abs(len(ra)-len(rb)) > limit {
+		return limit + 1
+	}
+
+	// Three rows of the distance matrix: two rows back is needed for the swaps
+	beforePrevious := make([]int, len(rb)+1)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		rowMin := current[0]
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				current[j] = min(current[j], beforePrevious[j-2]+1)
+			}
+
+			rowMin = min(rowMin, current[j])
+		}
+
+		if rowMin > limit {
+			return limit + 1
+		}
+
+		beforePrevious, previous, current = previous, current, beforePrevious
+	}
+
+	return previous[len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// queryMatcher matches the words of a document against the terms of a search query
+type queryMatcher struct {
+	terms []string
+}
+
+func newQueryMatcher(query string) queryMatcher {
+	return queryMatcher{terms: words(query)}
+}
+
+// weight returns the best weight of the word against any query term
+func (m queryMatcher) weight(word string) float64 {
+	best := 0.0
+
+	for i, term := range m.terms {
+		best = max(best, matchWeight(term, word, i == len(m.terms)-1))
+	}
+
+	return best
+}
+
+// highlight wraps the matching words of the text in <em></em>, the text itself is HTML escaped
+// so the snippet can be rendered as HTML. Long texts are cut to an excerpt starting a little before the first match.
+// It returns false when no word matches.
+func (m queryMatcher) highlight(text string, excerpt bool) (string, bool) {
+	var matched []token
+
+	for _, t := range tokenize(text) {
+		if m.weight(t.word) > 0 {
+			matched = append(matched, t)
+		}
+	}
+
+	if len(matched) == 0 {
+		return "", false
+	}
+
+	start, end := 0, len(text)
+
+	if excerpt && len(text) > snippetLength {
+		start = max(0, matched[0].start-snippetLength/4)
+
+		// Start and end on word boundaries
+		if i := strings.LastIndexByte(text[:start], ' '); start > 0 && i >= 0 {
+			start = i + 1
+		}
+
+		end = min(len(text), start+snippetLength)
+
+		if i := strings.LastIndexByte(text[:end], ' '); end < len(text) && i > matched[0].end {
+			end = i
+		}
+	}
+
+	var builder strings.Builder
+
+	if start > 0 {
+		builder.WriteString("…")
+	}
+
+	position := start
+
+	for _, t := range matched {
+		if t.start < start || t.end > end {
+			continue
+		}
+
+		builder.WriteString(html.EscapeString(text[position:t.start]))
+		builder.WriteString("<em>")
+		builder.WriteString(html.EscapeString(text[t.start:t.end]))
+		builder.WriteString("</em>")
+		position = t.end
+	}
+
+	builder.WriteString(html.EscapeString(text[position:end]))
+
+	if end < len(text) {
+		builder.WriteString("…")
+	}
+
+	return builder.String(), true
+}
+
+// highlights returns the highlighted title and admin review of a movie, only for the fields that match
+func (m queryMatcher) highlights(title, adminReview string) map[string]string {
+	result := map[string]string{}
+
+	if highlighted, ok := m.highlight(title, false); ok {
+		result["title"] = highlighted
+	}
+
+	if highlighted, ok := m.highlight(adminReview, true); ok {
+		result["admin_review"] = highlighted
+	}
+
+	return result
+}
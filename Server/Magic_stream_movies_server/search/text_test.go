@@ -0,0 +1,44 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/models"
+)
+
+func TestHighlightsEscapeTheMarkup(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+
+	movie := models.Movie{
+		Imbd_id:      "tt0000001",
+		Title:        `Matrix <script>alert("title")</script>`,
+		Admin_review: `The <img src=x onerror="alert(1)"> Matrix & more`,
+	}
+
+	if err := index.Index(ctx, movie); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := index.Search(ctx, "matrix", 10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	want := map[string]string{
+		"title":        `<em>Matrix</em> &lt;script&gt;alert(&#34;title&#34;)&lt;/script&gt;`,
+		"admin_review": `The &lt;img src=x onerror=&#34;alert(1)&#34;&gt; <em>Matrix</em> &amp; more`,
+	}
+
+	for field, highlighted := range want {
+		if got := results[0].Highlights[field]; got != highlighted {
+			t.Errorf("got the %s highlight %q, want %q", field, got, highlighted)
+		}
+	}
+}
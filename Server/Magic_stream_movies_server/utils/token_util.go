@@ -3,14 +3,14 @@ package utils
 import (
 	"context"
 	"errors"
-	"os"
+	"strings"
 	"time"
 
-	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/database"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/config"
+	"github.com/Marieid/magic_stream_movies/Server/Magic_stream_movies_server/repository"
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/v2/bson"
-	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 type SignedDetails struct {
@@ -22,10 +22,9 @@ type SignedDetails struct {
 	jwt.RegisteredClaims
 }
 
-var SECRET_KEY string = os.Getenv("SECRET_KEY")
-var SECRET_REFRESH_KEY string = os.Getenv("SECRET_REFRESH_KEY")
-
-func GenerateAllTokens(email, first_name, last_name, role, user_id string) (string, string, error) {
+// GenerateAllTokens returns an access token valid 24 hours signed with SECRET_KEY
+// and a refresh token valid 7 days signed with SECRET_REFRESH_KEY
+func GenerateAllTokens(cfg *config.Config, email, first_name, last_name, role, user_id string) (string, string, error) {
 	claims := &SignedDetails{
 		Email:      email,
 		First_name: first_name,
@@ -33,13 +32,15 @@ func GenerateAllTokens(email, first_name, last_name, role, user_id string) (stri
 		Role:       role,
 		User_id:    user_id,
 		RegisteredClaims: jwt.RegisteredClaims{
+			// A unique token id so two tokens issued in the same second never compare equal
+			ID:        bson.NewObjectID().Hex(),
 			Issuer:    "MagicStream",
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(SECRET_KEY))
+	signedToken, err := token.SignedString([]byte(cfg.Secret_key))
 
 	if err != nil {
 		return "", "", err
@@ -52,13 +53,14 @@ func GenerateAllTokens(email, first_name, last_name, role, user_id string) (stri
 		Role:       role,
 		User_id:    user_id,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        bson.NewObjectID().Hex(),
 			Issuer:    "MagicStream",
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 7 * time.Hour)),
 		},
 	}
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	signedRefreshToken, err := refreshToken.SignedString([]byte(SECRET_REFRESH_KEY))
+	signedRefreshToken, err := refreshToken.SignedString([]byte(cfg.Secret_refresh_key))
 
 	if err != nil {
 		return "", "", err
@@ -68,28 +70,35 @@ func GenerateAllTokens(email, first_name, last_name, role, user_id string) (stri
 
 }
 
-func UpdateAllTokens(userId, token, refreshToken string, client *mongo.Client) (err error) {
+func UpdateAllTokens(userId, token, refreshToken string, users repository.UserRepository) (err error) {
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	updateAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	return users.UpdateTokens(ctx, userId, token, refreshToken)
+}
 
-	updateData := bson.M{
-		"$set": bson.M{
-			"token":         token,
-			"refresh_token": refreshToken,
-			"update_at":     updateAt,
-		},
-	}
+// RevokeAllTokens clears both stored tokens of a user so that neither the access token
+// nor the refresh token can be exchanged again
+func RevokeAllTokens(userId string, users repository.UserRepository) error {
+	return UpdateAllTokens(userId, "", "", users)
+}
 
-	var userCollection *mongo.Collection = database.OpenCollection("users")
+// IsTokenActive reports whether the token is still the access token stored on the user document.
+// Tokens replaced by a later login/refresh or cleared by RevokeAllTokens are no longer active.
+func IsTokenActive(userId, token string, users repository.UserRepository) (bool, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
 
-	_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": userId}, updateData)
+	storedUser, err := users.FindByID(ctx, userId)
 
 	if err != nil {
-		return err
+		if errors.Is(err, repository.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
 	}
-	return nil
+
+	return storedUser.Token != "" && storedUser.Token == token, nil
 }
 
 func GetAccessToken(c *gin.Context) (string, error) {
@@ -100,7 +109,12 @@ func GetAccessToken(c *gin.Context) (string, error) {
 		return "", errors.New("authorization header is required")
 	}
 
-	tokenString := authHeader[len("Bearer "):]
+	// A header shorter than the prefix or with another scheme is rejected rather than sliced
+	tokenString, found := strings.CutPrefix(authHeader, "Bearer ")
+
+	if !found {
+		return "", errors.New("authorization header must be a bearer token")
+	}
 
 	if tokenString == "" {
 		return "", errors.New("bearer token is required")
@@ -109,18 +123,27 @@ func GetAccessToken(c *gin.Context) (string, error) {
 	return tokenString, nil
 }
 
-func ValidateToken(tokenString string) (*SignedDetails, error) {
+func ValidateToken(cfg *config.Config, tokenString string) (*SignedDetails, error) {
+	return validateSignedToken(tokenString, cfg.Secret_key)
+}
+
+// ValidateRefreshToken checks a refresh token against SECRET_REFRESH_KEY and returns its claims
+func ValidateRefreshToken(cfg *config.Config, tokenString string) (*SignedDetails, error) {
+	return validateSignedToken(tokenString, cfg.Secret_refresh_key)
+}
+
+func validateSignedToken(tokenString string, secretKey string) (*SignedDetails, error) {
 	claims := &SignedDetails{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
-		return []byte(SECRET_KEY), nil
+		return []byte(secretKey), nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-		return nil, err
+		return nil, errors.New("unexpected signing method")
 	}
 
 	if claims.ExpiresAt.Time.Before(time.Now()) {
@@ -146,3 +169,20 @@ func GetUserIdFromContext(c *gin.Context) (string, error) {
 
 	return id, nil
 }
+
+// GetRoleFromContext returns the role set by the authentication middleware
+func GetRoleFromContext(c *gin.Context) (string, error) {
+	role, exists := c.Get("role")
+
+	if !exists {
+		return "", errors.New("role does not exist in this context")
+	}
+
+	name, ok := role.(string)
+
+	if !ok {
+		return "", errors.New("unable to retrieve role")
+	}
+
+	return name, nil
+}